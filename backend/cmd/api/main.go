@@ -121,6 +121,8 @@ func main() {
 	// Inicializar servicios y processors
 	taskSvc := usecases.NewTaskService(taskRepo)
 	orchestrator := usecases.NewOrchestrator()
+	orchestrator.MaxAttempts = cfg.AgentMaxAttempts
+	orchestrator.AttemptRepo = repo.NewPostgresAgentAttemptRepository(db)
 	consensus := usecases.NewConsensusEngine()
 	
 	// Convertir config simple a internal/config
@@ -129,6 +131,26 @@ func main() {
 	internalConfig.TigerCloud.UseTigerCloud = cfg.UseTigerCloud
 	internalConfig.TigerCloud.MainService = cfg.TigerMainService
 	internalConfig.TigerCloud.MCPURL = cfg.TigerMCPURL
+	internalConfig.TigerCloud.ForkPool = cfg.TigerForkPool
+	internalConfig.TigerCloud.ServiceURLs = internalcfg.ServiceURLsFromEnv()
+	internalConfig.Forks.Provider = cfg.ForkProvider
+	internalConfig.Forks.LocalAdminURL = cfg.LocalForkAdminURL
+	internalConfig.Forks.LocalTemplate = cfg.LocalForkTemplate
+	internalConfig.Forks.MaxConnsPerService = cfg.ForkMaxConnsPerService
+	internalConfig.Forks.ConnIdleTimeoutS = cfg.ForkConnIdleTimeoutS
+	internalConfig.Forks.HealthCheckS = cfg.ForkConnHealthCheckS
+	internalConfig.Apply.Enabled = cfg.ApplyToMain
+	internalConfig.Apply.RequireApproval = cfg.ApplyRequireApproval
+	internalConfig.Apply.LockTimeoutMS = cfg.ApplyLockTimeoutMS
+	internalConfig.Apply.ConcurrentIndexMB = cfg.ApplyConcurrentIndexMB
+	internalConfig.Apply.RegressionTolerance = cfg.ApplyRegressionTolerance
+	internalConfig.Queue.Workers = cfg.TaskWorkers
+	internalConfig.Queue.LeaseS = cfg.TaskLeaseS
+	internalConfig.Queue.MaxAttempts = cfg.TaskMaxAttempts
+	internalConfig.Queue.PollMS = cfg.TaskPollMS
+	if cfg.ForkProvider == mcp.ProviderLocal && internalConfig.TigerCloud.MainService == "" {
+		internalConfig.TigerCloud.MainService = cfg.LocalForkTemplate
+	}
 	internalConfig.VertexAI.ProjectID = os.Getenv("GCP_PROJECT_ID")
 	internalConfig.VertexAI.Location = os.Getenv("GCP_REGION")
 	internalConfig.VertexAI.Credentials = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
@@ -136,8 +158,8 @@ func main() {
 	internalConfig.VertexAI.ModelOperativo = "gemini-2.5-flash"
 	internalConfig.VertexAI.ModelBulk = "gemini-2.0-flash"
 	
-	// Inicializar MCP Client para Tiger Cloud
-	mcpClient, err := mcp.New(internalConfig, nil)
+	// Inicializar proveedor de forks (Tiger Cloud o PostgreSQL local según FORK_PROVIDER)
+	mcpClient, err := mcp.NewProvider(internalConfig)
 	if err != nil {
 		applogger.Info("⚠️ MCP Client initialization failed: " + err.Error())
 		applogger.Info("Continuing without Tiger Cloud integration...")
@@ -157,13 +179,18 @@ func main() {
 	
 	// Inicializar Orchestrator con MCP Client
 	orchestrator.MCPClient = mcpClient
+	orchestrator.Apply = usecases.ApplyOptionsFromConfig(internalConfig)
 	
 	// Crear AgentFactory con MCP Client
 	agentFactory := usecases.NewAgentFactory(mcpClient, agentExecRepo, internalConfig)
 	
 	// Crear TaskProcessor con todas las dependencias
 	var taskProcessor *usecases.TaskProcessor
+	var taskQueue *usecases.WorkerPool
 	if mcpClient != nil {
+		orchestrator.Hygiene = usecases.NewForkHygiene(mcpClient)
+		orchestrator.Forks = usecases.NewForkPool(mcpClient, internalConfig.TigerCloud.ForkPool)
+		orchestrator.Forks.Reset = orchestrator.Hygiene.Restore
 		taskProcessor = usecases.NewTaskProcessor(
 			taskRepo,
 			agentExecRepo,
@@ -176,7 +203,17 @@ func main() {
 			agentFactory,
 			internalConfig.TigerCloud.MainService,
 		)
+		taskProcessor.Stages = repo.NewPostgresTaskStageRepository(db)
 		applogger.Info("✅ TaskProcessor initialized with full agent processing")
+
+		// Cola durable: recupera tareas huérfanas y las procesa con el pool de workers
+		taskQueue = usecases.NewWorkerPool(repo.NewPostgresTaskQueue(db), taskProcessor.ProcessTask, usecases.WorkerPoolOptionsFromConfig(internalConfig))
+		if err := taskQueue.Start(context.Background()); err != nil {
+			applogger.Error("Task queue failed to start, processing tasks in goroutines", err)
+			taskQueue = nil
+		} else {
+			applogger.Info("✅ Task queue started")
+		}
 	} else {
 		applogger.Info("⚠️ TaskProcessor disabled (MCP not available)")
 		taskProcessor = nil
@@ -184,10 +221,12 @@ func main() {
 	
 	// Handlers
 	taskHandler := handlers.NewTaskHandler(taskSvc, taskProcessor, hub)
+	taskHandler.Queue = taskQueue
 	resultsHandler := handlers.NewResultsHandler(agentExecRepo, optRepo, benchRepo, consRepo, hub)
 	authHandler := handlers.NewAuthHandler(authService)
 	metricsHandler := handlers.NewMetricsHandler(db)
-	routes.SetupRoutes(app, hub, taskHandler, resultsHandler, authHandler, authService, metricsHandler)
+	agentTypesHandler := handlers.NewAgentTypesHandler(internalConfig)
+	routes.SetupRoutes(app, hub, taskHandler, resultsHandler, authHandler, authService, metricsHandler, agentTypesHandler)
 
 	// ============================================
 	// 10. Graceful Shutdown
@@ -199,7 +238,13 @@ func main() {
 
 		applogger.Info("🛑 Shutting down gracefully...")
 
+		if taskQueue != nil {
+			taskQueue.Stop()
+		}
 		db.Close()
+		if mcpClient != nil {
+			mcpClient.Close()
+		}
 		// redisClient.Close()
 
 		if err := app.Shutdown(); err != nil {
@@ -57,9 +57,9 @@ func main() {
 	_ = consRepo
 
 	// 5) Initialize MCP client
-	mcpClient, err := mcp.New(cfg, nil)
+	mcpClient, err := mcp.NewProvider(cfg)
 	if err != nil { log.Fatalf("mcp init error: %v", err) }
-	if cfg.TigerCloud.UseTigerCloud {
+	if cfg.TigerCloud.UseTigerCloud || cfg.Forks.Provider != mcp.ProviderTiger {
 		if err := mcpClient.Connect(context.Background()); err != nil {
 			log.Fatalf("mcp connect error: %v", err)
 		}
@@ -85,6 +85,13 @@ func main() {
 	agentFactory := usecases.NewAgentFactory(mcpClient, agentExecRepo, cfg)
 	consEngine := usecases.NewConsensusEngine()
 	orch := usecases.NewOrchestrator()
+	orch.MaxAttempts = cfg.Agents.MaxAttempts
+	orch.AttemptRepo = repositories.NewPostgresAgentAttemptRepository(db)
+	orch.MCPClient = mcpClient
+	orch.Apply = usecases.ApplyOptionsFromConfig(cfg)
+	orch.Hygiene = usecases.NewForkHygiene(mcpClient)
+	orch.Forks = usecases.NewForkPool(mcpClient, cfg.TigerCloud.ForkPool)
+	orch.Forks.Reset = orch.Hygiene.Restore
 	taskProcessor := usecases.NewTaskProcessor(
 		taskRepo,
 		agentExecRepo,
@@ -97,10 +104,16 @@ func main() {
 		agentFactory,
 		cfg.TigerCloud.MainService,
 	)
+	taskProcessor.Stages = repositories.NewPostgresTaskStageRepository(db)
+
+	// Durable task queue: recovers orphaned tasks, then workers process them
+	taskQueue := usecases.NewWorkerPool(repositories.NewPostgresTaskQueue(db), taskProcessor.ProcessTask, usecases.WorkerPoolOptionsFromConfig(cfg))
+	if err := taskQueue.Start(context.Background()); err != nil { log.Fatalf("task queue error: %v", err) }
 
 	// 10) Initialize HTTP Handlers and Router
 	app := fiber.New()
 	taskHandler := httphandlers.NewTaskHandler(taskSvc, taskProcessor, hub)
+	taskHandler.Queue = taskQueue
 	resultsHandler := httphandlers.NewResultsHandler(agentExecRepo, optRepo, benchRepo, consRepo, hub)
 	
 	// Create AuthService with UserRepository
@@ -108,6 +121,7 @@ func main() {
 	authSvc := usecases.NewAuthService(userRepo, "afs-jwt-secret-2024")
 	authHandler := httphandlers.NewAuthHandler(authSvc)
 	metricsHandler := httphandlers.NewMetricsHandler(db)
+	agentTypesHandler := httphandlers.NewAgentTypesHandler(cfg)
 	
 	// CORS middleware - allow Vercel frontend
 	app.Use(func(c *fiber.Ctx) error {
@@ -122,7 +136,7 @@ func main() {
 		return c.Next()
 	})
 	
-	routes.SetupRoutes(app, hub, taskHandler, resultsHandler, authHandler, authSvc, metricsHandler)
+	routes.SetupRoutes(app, hub, taskHandler, resultsHandler, authHandler, authSvc, metricsHandler, agentTypesHandler)
 
 	// 11) Start HTTP/WebSocket server
 	addr := net.JoinHostPort(cfg.Server.Host, cfg.Server.Port)
@@ -140,6 +154,8 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	_ = app.Shutdown()
+	taskQueue.Stop()
+	_ = mcpClient.Close()
 	_ = db.Close()
 	_ = ctx
 }
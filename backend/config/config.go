@@ -3,6 +3,8 @@ package config
 import (
 	"errors"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -14,7 +16,24 @@ type Config struct {
 	UseTigerCloud   bool
 	TigerMainService string
 	TigerMCPURL      string
+	TigerForkPool    []string
+	ForkProvider      string
+	LocalForkAdminURL string
+	LocalForkTemplate string
+	ForkMaxConnsPerService int
+	ForkConnIdleTimeoutS   int
+	ForkConnHealthCheckS   int
 	JWTSecret       string
+	AgentMaxAttempts int
+	ApplyToMain              bool
+	ApplyRequireApproval     bool
+	ApplyLockTimeoutMS       int
+	ApplyConcurrentIndexMB   int
+	ApplyRegressionTolerance float64
+	TaskWorkers     int
+	TaskLeaseS      int
+	TaskMaxAttempts int
+	TaskPollMS      int
 }
 
 func buildTigerURLFromEnv() string {
@@ -43,7 +62,24 @@ func Load() *Config {
         UseTigerCloud:   getEnvBool("USE_TIGER_CLOUD", false),
         TigerMainService: getEnv("TIGER_MAIN_SERVICE", ""),
         TigerMCPURL:      getEnv("TIGER_MCP_URL", ""),
+        TigerForkPool:    getEnvList("TIGER_FORK_POOL"),
+        ForkProvider:      getEnv("FORK_PROVIDER", "tiger"),
+        LocalForkAdminURL: getEnv("LOCAL_FORK_ADMIN_URL", dsn),
+        LocalForkTemplate: getEnv("LOCAL_FORK_TEMPLATE", ""),
+        ForkMaxConnsPerService: getEnvInt("FORK_MAX_CONNS_PER_SERVICE", 4),
+        ForkConnIdleTimeoutS:   getEnvInt("FORK_CONN_IDLE_TIMEOUT_S", 300),
+        ForkConnHealthCheckS:   getEnvInt("FORK_CONN_HEALTH_CHECK_S", 30),
         JWTSecret:        getEnv("JWT_SECRET", "default-secret-change-in-production"),
+        AgentMaxAttempts: getEnvInt("AGENT_MAX_ATTEMPTS", 3),
+        ApplyToMain:              getEnvBool("APPLY_TO_MAIN", false),
+        ApplyRequireApproval:     getEnvBool("APPLY_REQUIRE_APPROVAL", true),
+        ApplyLockTimeoutMS:       getEnvInt("APPLY_LOCK_TIMEOUT_MS", 5000),
+        ApplyConcurrentIndexMB:   getEnvInt("APPLY_CONCURRENT_INDEX_MB", 100),
+        ApplyRegressionTolerance: getEnvFloat("APPLY_REGRESSION_TOLERANCE", 0.05),
+        TaskWorkers:     getEnvInt("TASK_WORKERS", 2),
+        TaskLeaseS:      getEnvInt("TASK_LEASE_S", 60),
+        TaskMaxAttempts: getEnvInt("TASK_MAX_ATTEMPTS", 3),
+        TaskPollMS:      getEnvInt("TASK_POLL_MS", 1000),
     }
 }
 
@@ -54,6 +90,30 @@ func getEnv(key, defaultVal string) string {
 	return defaultVal
 }
 
+func getEnvInt(key string, defaultVal int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil && n > 0 {
+		return n
+	}
+	return defaultVal
+}
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil && f >= 0 {
+		return f
+	}
+	return defaultVal
+}
+
+func getEnvList(key string) []string {
+	var out []string
+	for _, s := range strings.Split(os.Getenv(key), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func getEnvBool(key string, defaultVal bool) bool {
 	v := os.Getenv(key)
 	if v == "" {
@@ -38,11 +38,21 @@ type Config struct {
 		MainService   string
 		ForkAgent1    string
 		ForkAgent2    string
+		ForkPool      []string // pre-created fork service IDs leased one per agent; empty creates forks on demand
+		ServiceURLs   map[string]string // service ID -> connection URL; other services are discovered or derived
 		MCPURL        string
 		PublicKey     string
 		SecretKey     string
 		ProjectID     string
 	}
+	Forks struct {
+		Provider           string // tiger (default) or local
+		LocalAdminURL      string // local: server connection used for CREATE/DROP DATABASE; defaults to Database.URL
+		LocalTemplate      string // local: database forks are copied from; also the main service
+		MaxConnsPerService int    // pooled connections kept per fork/service
+		ConnIdleTimeoutS   int    // a service pool unused this long is closed
+		HealthCheckS       int    // a service pool idle this long is pinged before reuse
+	}
 	Timeouts struct {
 		LLMAnalysisMS  int
 		LLMProposalMS  int
@@ -50,6 +60,28 @@ type Config struct {
 		DBConnectMS    int
 		ContextMS      int
 	}
+	Benchmark struct {
+		WarmupRuns   int     // discarded runs before measuring each query
+		MeasuredRuns int     // runs feeding median/p95/stddev
+		Alpha        float64 // significance level for baseline comparisons
+		Timing       string  // client (default), explain or pg_stat_statements
+	}
+	Agents struct {
+		MaxAttempts int // proposal attempts per agent when a proposal fails or does not improve
+	}
+	Apply struct {
+		Enabled             bool    // apply winning proposals to the main service
+		RequireApproval     bool    // an admin approves each apply (POST /tasks/:id/approve); default true
+		LockTimeoutMS       int     // lock_timeout of apply statements; older conflicting transactions block the apply
+		ConcurrentIndexMB   int     // CREATE INDEX on tables at least this large runs CONCURRENTLY
+		RegressionTolerance float64 // significant median slowdown tolerated before rolling back, as a fraction
+	}
+	Queue struct {
+		Workers     int // tasks processed concurrently by this server
+		LeaseS      int // seconds without heartbeat before a running task is recovered
+		MaxAttempts int // attempts per task before it is left failed
+		PollMS      int // idle workers look for due jobs this often
+	}
 }
 
 // Load reads configuration from environment variables and validates required fields.
@@ -150,6 +182,45 @@ func Load() (*Config, error) {
 	} else {
 		cfg.TigerCloud.ForkAgent2 = os.Getenv("TIGER_FORK_AGENT_2")
 	}
+
+	// Fork provider: Tiger Cloud, or databases copied from a template on a local PostgreSQL
+	cfg.Forks.Provider = strings.ToLower(strings.TrimSpace(os.Getenv("FORK_PROVIDER")))
+	if cfg.Forks.Provider == "" {
+		cfg.Forks.Provider = "tiger"
+	}
+	cfg.Forks.LocalAdminURL = os.Getenv("LOCAL_FORK_ADMIN_URL")
+	if cfg.Forks.LocalAdminURL == "" {
+		cfg.Forks.LocalAdminURL = cfg.Database.URL
+	}
+	cfg.Forks.LocalTemplate = os.Getenv("LOCAL_FORK_TEMPLATE")
+	if cfg.Forks.Provider == "local" && cfg.TigerCloud.MainService == "" {
+		cfg.TigerCloud.MainService = cfg.Forks.LocalTemplate
+	}
+	cfg.Forks.MaxConnsPerService = 4
+	if v := os.Getenv("FORK_MAX_CONNS_PER_SERVICE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Forks.MaxConnsPerService = n
+		}
+	}
+	cfg.Forks.ConnIdleTimeoutS = 300
+	if v := os.Getenv("FORK_CONN_IDLE_TIMEOUT_S"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Forks.ConnIdleTimeoutS = n
+		}
+	}
+	cfg.Forks.HealthCheckS = 30
+	if v := os.Getenv("FORK_CONN_HEALTH_CHECK_S"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Forks.HealthCheckS = n
+		}
+	}
+
+	// Fork pool: explicit list, or the per-agent Tiger forks above
+	cfg.TigerCloud.ForkPool = splitList(os.Getenv("TIGER_FORK_POOL"))
+	if len(cfg.TigerCloud.ForkPool) == 0 && cfg.Forks.Provider == "tiger" {
+		cfg.TigerCloud.ForkPool = splitList(cfg.TigerCloud.ForkAgent1 + "," + cfg.TigerCloud.ForkAgent2)
+	}
+	cfg.TigerCloud.ServiceURLs = ServiceURLsFromEnv()
 	cfg.TigerCloud.MCPURL = os.Getenv("TIGER_MCP_URL")
 	cfg.TigerCloud.PublicKey = os.Getenv("TIGER_PUBLIC_KEY")
 	cfg.TigerCloud.SecretKey = os.Getenv("TIGER_SECRET_KEY")
@@ -197,6 +268,90 @@ func Load() (*Config, error) {
 		cfg.Timeouts.ContextMS = 600000 // 10m
 	}
 
+	// Benchmark
+	cfg.Benchmark.WarmupRuns = 1
+	if v := os.Getenv("BENCHMARK_WARMUP_RUNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.Benchmark.WarmupRuns = n
+		}
+	}
+	if v := os.Getenv("BENCHMARK_MEASURED_RUNS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Benchmark.MeasuredRuns = n
+		}
+	}
+	if cfg.Benchmark.MeasuredRuns == 0 {
+		cfg.Benchmark.MeasuredRuns = 5
+	}
+	if v := os.Getenv("BENCHMARK_ALPHA"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f > 0 && f < 1 {
+			cfg.Benchmark.Alpha = f
+		}
+	}
+	if cfg.Benchmark.Alpha == 0 {
+		cfg.Benchmark.Alpha = 0.05
+	}
+	cfg.Benchmark.Timing = os.Getenv("BENCHMARK_TIMING")
+	if cfg.Benchmark.Timing == "" {
+		cfg.Benchmark.Timing = "client"
+	}
+
+	// Agents
+	cfg.Agents.MaxAttempts = 3
+	if v := os.Getenv("AGENT_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Agents.MaxAttempts = n
+		}
+	}
+
+	// Apply to main
+	cfg.Apply.Enabled = os.Getenv("APPLY_TO_MAIN") == "true"
+	cfg.Apply.RequireApproval = os.Getenv("APPLY_REQUIRE_APPROVAL") != "false"
+	cfg.Apply.LockTimeoutMS = 5000
+	if v := os.Getenv("APPLY_LOCK_TIMEOUT_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Apply.LockTimeoutMS = n
+		}
+	}
+	cfg.Apply.ConcurrentIndexMB = 100
+	if v := os.Getenv("APPLY_CONCURRENT_INDEX_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Apply.ConcurrentIndexMB = n
+		}
+	}
+	cfg.Apply.RegressionTolerance = 0.05
+	if v := os.Getenv("APPLY_REGRESSION_TOLERANCE"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+			cfg.Apply.RegressionTolerance = f
+		}
+	}
+
+	// Task queue
+	cfg.Queue.Workers = 2
+	if v := os.Getenv("TASK_WORKERS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Queue.Workers = n
+		}
+	}
+	cfg.Queue.LeaseS = 60
+	if v := os.Getenv("TASK_LEASE_S"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Queue.LeaseS = n
+		}
+	}
+	cfg.Queue.MaxAttempts = 3
+	if v := os.Getenv("TASK_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Queue.MaxAttempts = n
+		}
+	}
+	cfg.Queue.PollMS = 1000
+	if v := os.Getenv("TASK_POLL_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.Queue.PollMS = n
+		}
+	}
+
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
@@ -221,9 +376,68 @@ func (c *Config) validate() error {
 			return errors.New("missing TIGER_FORK_AGENT_2 when USE_TIGER_CLOUD=true")
 		}
 	}
+	switch c.Forks.Provider {
+	case "tiger":
+	case "local":
+		if strings.TrimSpace(c.Forks.LocalTemplate) == "" {
+			return errors.New("missing LOCAL_FORK_TEMPLATE when FORK_PROVIDER=local")
+		}
+	case "mcp":
+		if strings.TrimSpace(c.TigerCloud.MCPURL) == "" {
+			return errors.New("missing TIGER_MCP_URL when FORK_PROVIDER=mcp")
+		}
+		if strings.TrimSpace(c.TigerCloud.MainService) == "" {
+			return errors.New("missing TIGER_MAIN_SERVICE when FORK_PROVIDER=mcp")
+		}
+	default:
+		return errors.New("invalid FORK_PROVIDER: " + c.Forks.Provider + " (supported: tiger, local, mcp)")
+	}
 	return nil
 }
 
+// ServiceURLsFromEnv reads service connection URLs from TIGER_SERVICE_URLS (id=url,id=url)
+// and from the per-service variables of the main service and the two agent forks.
+func ServiceURLsFromEnv() map[string]string {
+	urls := map[string]string{}
+	for _, pair := range splitList(os.Getenv("TIGER_SERVICE_URLS")) {
+		if k := strings.Index(pair, "="); k > 0 {
+			urls[strings.TrimSpace(pair[:k])] = strings.TrimSpace(pair[k+1:])
+		}
+	}
+	legacy := [][2]string{
+		{os.Getenv("TIGER_MAIN_SERVICE"), "TIGER_MAIN_SERVICE_URL"},
+		{firstEnv("TIGER_FORK_A1_SERVICE_ID", "TIGER_FORK_AGENT_1"), "TIGER_FORK_A1_SERVICE_URL"},
+		{firstEnv("TIGER_FORK_A2_SERVICE_ID", "TIGER_FORK_AGENT_2"), "TIGER_FORK_A2_SERVICE_URL"},
+	}
+	for _, l := range legacy {
+		id, u := strings.TrimSpace(l[0]), os.Getenv(l[1])
+		if _, set := urls[id]; id != "" && u != "" && !set {
+			urls[id] = u
+		}
+	}
+	return urls
+}
+
+func firstEnv(keys ...string) string {
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// splitList parses a comma-separated list, dropping blanks.
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
 func buildDatabaseURLFromEnv() string {
 	db := os.Getenv("POSTGRES_DB")
 	user := os.Getenv("POSTGRES_USER")
@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"errors"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+// AttemptOutcome describes how a single proposal attempt ended on the fork.
+type AttemptOutcome string
+
+const (
+	AttemptAccepted      AttemptOutcome = "accepted"       // benchmarked with a measurable improvement
+	AttemptNoImprovement AttemptOutcome = "no_improvement" // benchmarked, but not faster than the baseline
+	AttemptFailed        AttemptOutcome = "failed"         // proposal could not be generated, applied or benchmarked
+)
+
+// AgentAttempt records one proposal attempt made by an agent within an AgentExecution,
+// one record per candidate when the agent proposes several.
+// When an attempt fails or shows no improvement, its Feedback is handed back to the
+// agent for the next attempt, so the attempts of an execution form a revision history.
+type AgentAttempt struct {
+	ID               int64
+	AgentExecutionID int64
+	Attempt          int // 1-based, in execution order
+	Candidate        int // 1-based rank among the attempt's proposals; 0 when the agent produced none
+	ProposalType     values.ProposalType
+	SQLCommands      []string
+	Outcome          AttemptOutcome
+	Feedback         string  // error or benchmark summary given to the agent; empty when accepted
+	ImprovementPct   float64 // best significant improvement over the baseline, 0 when none
+	CreatedAt        time.Time
+}
+
+// Validate checks whether the attempt satisfies the domain business rules.
+func (a *AgentAttempt) Validate() error {
+	if a.AgentExecutionID <= 0 {
+		return errors.New("agent_execution_id must be positive")
+	}
+
+	if a.Attempt <= 0 {
+		return errors.New("attempt must be a positive integer")
+	}
+
+	if a.Candidate < 0 {
+		return errors.New("candidate cannot be negative")
+	}
+
+	switch a.Outcome {
+	case AttemptAccepted, AttemptNoImprovement, AttemptFailed:
+		// valid outcome
+	default:
+		return errors.New("invalid attempt outcome")
+	}
+
+	if a.Outcome != AttemptAccepted && a.Feedback == "" {
+		return errors.New("feedback must be provided for attempts that were not accepted")
+	}
+
+	return nil
+}
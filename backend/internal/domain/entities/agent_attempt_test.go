@@ -0,0 +1,22 @@
+package entities
+
+import "testing"
+
+func TestAgentAttempt_Validate(t *testing.T) {
+	ok := &AgentAttempt{AgentExecutionID: 1, Attempt: 1, Outcome: AttemptAccepted}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("expected valid attempt, got %v", err)
+	}
+
+	bad := []*AgentAttempt{
+		{AgentExecutionID: 0, Attempt: 1, Outcome: AttemptAccepted},
+		{AgentExecutionID: 1, Attempt: 0, Outcome: AttemptAccepted},
+		{AgentExecutionID: 1, Attempt: 1, Outcome: "retrying"},
+		{AgentExecutionID: 1, Attempt: 2, Outcome: AttemptFailed},
+	}
+	for i, a := range bad {
+		if err := a.Validate(); err == nil {
+			t.Errorf("case %d: expected validation error", i)
+		}
+	}
+}
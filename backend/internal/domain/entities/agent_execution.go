@@ -17,17 +17,30 @@ const (
 	ExecutionFailed    ExecutionStatus = "failed"
 )
 
+// ExecutionPhase is the last phase an agent entered: a failed execution failed in it.
+type ExecutionPhase string
+
+const (
+	PhaseAnalysis  ExecutionPhase = "analysis"  // analyzing the task on its fork
+	PhaseProposal  ExecutionPhase = "proposal"  // asking the LLM for candidates
+	PhaseBenchmark ExecutionPhase = "benchmark" // running candidates on its fork
+)
+
 // AgentExecution represents a single execution instance of an AI agent
 // operating on a given task.
 type AgentExecution struct {
-	ID          int64
-	TaskID      int64
-	AgentType   values.AgentType
-	ForkID      string
-	Status      ExecutionStatus
-	StartedAt   time.Time
-	CompletedAt *time.Time
-	ErrorMsg    string
+	ID           int64
+	TaskID       int64
+	AgentType    values.AgentType
+	ForkID       string
+	Status       ExecutionStatus
+	StartedAt    time.Time
+	CompletedAt  *time.Time
+	ErrorMsg     string
+	Phase        ExecutionPhase // empty until the agent starts analyzing
+	DurationMS   int64          // time spent analyzing and proposing, summed over retries
+	InputTokens  int            // LLM tokens, summed over retries
+	OutputTokens int
 }
 
 // Validate checks whether the entity satisfies the domain business rules.
@@ -37,10 +50,7 @@ func (ae *AgentExecution) Validate() error {
 		return errors.New("task_id must be a positive integer")
 	}
 
-	switch ae.AgentType {
-	case values.AgentCerebro, values.AgentOperativo, values.AgentBulk:
-		// valid type
-	default:
+	if !ae.AgentType.IsValid() {
 		return errors.New("invalid agent type")
 	}
 
@@ -0,0 +1,40 @@
+package entities
+
+import "time"
+
+// ApplyStatus is the outcome of applying a winning proposal to the main database.
+type ApplyStatus string
+
+const (
+	ApplyApplied    ApplyStatus = "applied"     // statements ran and the post-apply benchmark did not regress
+	ApplyRolledBack ApplyStatus = "rolled_back" // statements ran, then were undone (failure or regression)
+	ApplyFailed     ApplyStatus = "failed"      // the apply or its rollback failed; main may need the rollback script
+	ApplyBlocked    ApplyStatus = "blocked"     // the pre-flight check refused to start
+)
+
+// RelationCheck is the pre-flight view of one relation an apply statement locks.
+type RelationCheck struct {
+	Relation      string   `json:"relation"`
+	Exists        bool     `json:"exists"`
+	SizeBytes     int64    `json:"size_bytes"`
+	EstimatedRows int64    `json:"estimated_rows"`
+	Locks         []string `json:"locks,omitempty"` // conflicting locks other sessions held, e.g. "pid 412 RowExclusiveLock (active, 0.2s)"
+}
+
+// ApplyReport records how a winning proposal was applied to the main database:
+// the pre-flight checks, the statements as executed, the rollback script generated
+// before anything ran, and the benchmark of the task query before and after.
+type ApplyReport struct {
+	Status         ApplyStatus     `json:"status"`
+	Preflight      []RelationCheck `json:"preflight,omitempty"`
+	Statements     []string        `json:"statements"`               // after rewrites such as CREATE INDEX CONCURRENTLY
+	Transactional  bool            `json:"transactional"`            // every statement ran in one transaction
+	RollbackScript []string        `json:"rollback_script,omitempty"`
+	Before         *BenchmarkStats `json:"before,omitempty"` // task query on main before the apply
+	After          *BenchmarkStats `json:"after,omitempty"`
+	Significance   *Significance   `json:"significance,omitempty"` // After compared with Before
+	Regressed      bool            `json:"regressed"`
+	Error          string          `json:"error,omitempty"`
+	StartedAt      time.Time       `json:"started_at"`
+	FinishedAt     time.Time       `json:"finished_at"`
+}
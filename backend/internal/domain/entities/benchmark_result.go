@@ -12,7 +12,7 @@ type BenchmarkQueryName string
 const (
 	QueryNameBaseline  BenchmarkQueryName = "baseline"
 	QueryNameTestLimit   BenchmarkQueryName = "test_limit"
-	QueryNameTestFilter  BenchmarkQueryName = "test_filter"
+	QueryNameTestFilter  BenchmarkQueryName = "test_filter" // older results only; the suite no longer runs it
 	QueryNameTestSort    BenchmarkQueryName = "test_sort"
 )
 
@@ -37,16 +37,60 @@ type ExplainPlan struct {
 	FullPlan          string  `json:"full_plan"` // Storing the full JSON plan as a string
 }
 
+// Significance reports whether a measured sample differs from the baseline sample.
+type Significance struct {
+	Method      string  `json:"method"` // e.g. "mann_whitney_u"
+	PValue      float64 `json:"p_value"`
+	Alpha       float64 `json:"alpha"`
+	Significant bool    `json:"significant"`
+}
+
+// StorageDelta records relation sizes on the fork before and after applying a proposal.
+// Total sizes come from pg_total_relation_size (heap + indexes + TOAST), index sizes from pg_indexes_size.
+type StorageDelta struct {
+	Relations        []string `json:"relations"`
+	TotalBytesBefore int64    `json:"total_bytes_before"`
+	TotalBytesAfter  int64    `json:"total_bytes_after"`
+	IndexBytesBefore int64    `json:"index_bytes_before"`
+	IndexBytesAfter  int64    `json:"index_bytes_after"`
+}
+
+// DeltaMB returns the change in total size in megabytes; negative when the proposal freed space.
+func (d *StorageDelta) DeltaMB() float64 {
+	if d == nil {
+		return 0
+	}
+	return float64(d.TotalBytesAfter-d.TotalBytesBefore) / (1024 * 1024)
+}
+
+// BenchmarkStats summarizes the measured iterations behind a BenchmarkResult.
+// Stored as JSONB alongside the result; Significance is nil for the baseline itself.
+type BenchmarkStats struct {
+	WarmupRuns   int           `json:"warmup_runs"`
+	Iterations   int           `json:"iterations"`
+	MedianMS     float64       `json:"median_ms"`
+	P95MS        float64       `json:"p95_ms"`
+	StdDevMS     float64       `json:"stddev_ms"`
+	MinMS        float64       `json:"min_ms"`
+	MaxMS        float64       `json:"max_ms"`
+	SamplesMS    []float64     `json:"samples_ms,omitempty"`
+	Significance *Significance `json:"significance,omitempty"`
+	Storage      *StorageDelta `json:"storage,omitempty"` // measured on post-apply results only
+	Timing       string        `json:"timing,omitempty"`      // where samples come from: client, explain or pg_stat_statements
+	PlanningMS   float64       `json:"planning_ms,omitempty"` // median server planning time; server-side timing only
+}
+
 // BenchmarkResult represents a single performance benchmark of an optimization proposal.
 type BenchmarkResult struct {
 	ID              int64
 	ProposalID      int64
 	QueryName       BenchmarkQueryName
 	QueryExecuted   string
-	ExecutionTimeMS float64 // measured execution time in milliseconds
+	ExecutionTimeMS float64 // measured execution time in milliseconds (mean of measured iterations)
 	RowsReturned    int64
 	ExplainPlan     ExplainPlan
 	StorageImpactMB float64 // in MB
+	Stats           BenchmarkStats
 	CreatedAt       time.Time
 }
 
@@ -84,9 +128,40 @@ func (b *BenchmarkResult) Validate() error {
 		return errors.New("explain_plan must have a plan_type")
 	}
 
+	if b.Stats.Iterations < 0 || b.Stats.WarmupRuns < 0 {
+		return errors.New("stats iterations cannot be negative")
+	}
+
 	return nil
 }
 
+// RepresentativeTimeMS returns the median when iteration statistics are available,
+// falling back to ExecutionTimeMS for results recorded without them.
+func (b *BenchmarkResult) RepresentativeTimeMS() float64 {
+	if b.Stats.Iterations > 0 && b.Stats.MedianMS > 0 {
+		return b.Stats.MedianMS
+	}
+	return b.ExecutionTimeMS
+}
+
+// MeasuredStorageMB returns the measured storage overhead and whether a measurement exists.
+// Space freed by a proposal counts as zero overhead.
+func (b *BenchmarkResult) MeasuredStorageMB() (float64, bool) {
+	if b.Stats.Storage == nil {
+		return 0, false
+	}
+	mb := b.Stats.Storage.DeltaMB()
+	if mb < 0 {
+		mb = 0
+	}
+	return mb, true
+}
+
+// IsNoise reports whether the result carries a significance test that found no real difference from the baseline.
+func (b *BenchmarkResult) IsNoise() bool {
+	return b.Stats.Significance != nil && !b.Stats.Significance.Significant
+}
+
 // IsFasterThan compares two benchmarks by execution time.
 func (b *BenchmarkResult) IsFasterThan(other *BenchmarkResult) bool {
 	if other == nil {
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"math"
 	"time"
-
-	"github.com/tuusuario/afs-challenge/internal/domain/values"
 )
 
 // ConsensusDecision represents the final decision made by the consensus engine.
@@ -14,24 +12,29 @@ import (
 type ConsensusDecision struct {
 	ID                int64
 	TaskID            int64
-	WinningProposalID *int64 // nullable
-	AllScores         map[values.AgentType]ProposalScore
+	WinningProposalID *int64                  // nullable
+	ProposalScores    map[int64]ProposalScore // every scored proposal by proposal ID
 	DecisionRationale string
 	AppliedToMain     bool
+	ApplyReport       *ApplyReport // set once the winner went through the apply pipeline
 	CreatedAt         time.Time
 }
 
 // ProposalScore holds individual proposal scoring results across multiple criteria.
+// The agent behind the proposal is its AgentExecutionID; one agent can have several scored proposals.
 type ProposalScore struct {
-	ProposalID         int64
-	Performance        float64 // 0–100 scale
-	Storage            float64 // 0–100 scale
-	Complexity         float64 // 0–100 scale
-	Risk               float64 // 0–100 scale
-	WeightedTotal      float64 // computed using ScoringCriteria
-	Rank               int
-	ImprovementPct     float64
-	StorageOverheadMB  float64
+	ProposalID        int64   `json:"proposal_id"`
+	AgentExecutionID  int64   `json:"agent_execution_id"`
+	Performance       float64 `json:"performance_score"` // 0–100 scale
+	Storage           float64 `json:"storage_score"`     // 0–100 scale
+	Complexity        float64 `json:"complexity_score"`  // 0–100 scale
+	Risk              float64 `json:"risk_score"`        // 0–100 scale
+	WeightedTotal     float64 `json:"weighted_total"`    // computed using ScoringCriteria
+	Rank              int     `json:"rank"`
+	ImprovementPct    float64 `json:"improvement_pct"`
+	StorageOverheadMB float64 `json:"storage_overhead_mb"`
+	Disqualified      bool    `json:"disqualified"` // excluded from winning, e.g. a rewrite that changes results
+	DisqualifyReason  string  `json:"disqualify_reason,omitempty"`
 }
 
 // ScoringCriteria defines configurable weights for scoring categories.
@@ -71,9 +74,9 @@ func (cd *ConsensusDecision) ApplyScores(criteria ScoringCriteria) error {
 	if err := criteria.Validate(); err != nil {
 		return err
 	}
-	for agentType, s := range cd.AllScores {
+	for proposalID, s := range cd.ProposalScores {
 		s.WeightedTotal = criteria.CalculateWeightedTotal(s)
-		cd.AllScores[agentType] = s
+		cd.ProposalScores[proposalID] = s
 	}
 	return nil
 }
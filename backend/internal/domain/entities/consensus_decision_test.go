@@ -2,8 +2,6 @@ package entities
 
 import (
 	"testing"
-
-	"github.com/tuusuario/afs-challenge/internal/domain/values"
 )
 
 func TestScoringCriteriaValidation(t *testing.T) {
@@ -50,9 +48,9 @@ func TestCalculateWeightedTotal(t *testing.T) {
 
 func TestApplyScores(t *testing.T) {
 	cd := &ConsensusDecision{
-		AllScores: map[values.AgentType]ProposalScore{
-			values.AgentCerebro:  {ProposalID: 1, Performance: 100, Storage: 90, Complexity: 80, Risk: 70},
-			values.AgentOperativo: {ProposalID: 2, Performance: 80, Storage: 100, Complexity: 70, Risk: 60},
+		ProposalScores: map[int64]ProposalScore{
+			1: {ProposalID: 1, AgentExecutionID: 10, Performance: 100, Storage: 90, Complexity: 80, Risk: 70},
+			2: {ProposalID: 2, AgentExecutionID: 10, Performance: 80, Storage: 100, Complexity: 70, Risk: 60},
 		},
 	}
 
@@ -67,7 +65,7 @@ func TestApplyScores(t *testing.T) {
 		t.Fatalf("unexpected validation error: %v", err)
 	}
 
-	if cd.AllScores[values.AgentCerebro].WeightedTotal == 0 || cd.AllScores[values.AgentOperativo].WeightedTotal == 0 {
+	if cd.ProposalScores[1].WeightedTotal == 0 || cd.ProposalScores[2].WeightedTotal == 0 {
 		t.Errorf("weighted totals not computed properly")
 	}
 }
@@ -17,6 +17,19 @@ type EstimatedImpact struct {
 	Risk                 string             // qualitative value: low, medium, high
 	AdditionalNotes      string             // optional descriptive text
 	ScoreBreakdown       map[string]float64 `json:"score_breakdown,omitempty"` // Scores calculados por consenso
+	Equivalence          *ResultEquivalence `json:"equivalence,omitempty"`     // Set by the benchmark stage for query_rewrite proposals
+	AgentRank            int                `json:"agent_rank,omitempty"`      // Position in the agent's ranked candidates (1 = first choice)
+}
+
+// ResultEquivalence records whether a rewritten query returns the same data as the original.
+// Checksums are order-insensitive digests of the result sets computed on the fork.
+type ResultEquivalence struct {
+	Equivalent        bool   `json:"equivalent"`
+	OriginalRows      int64  `json:"original_rows"`
+	RewrittenRows     int64  `json:"rewritten_rows"`
+	OriginalChecksum  string `json:"original_checksum,omitempty"`
+	RewrittenChecksum string `json:"rewritten_checksum,omitempty"`
+	Reason            string `json:"reason,omitempty"`
 }
 
 // OptimizationProposal represents a single optimization suggestion made by an agent.
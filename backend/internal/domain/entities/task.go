@@ -21,10 +21,12 @@ const (
 type TaskStatus string
 
 const (
-	TaskStatusPending    TaskStatus = "pending"
-	TaskStatusInProgress TaskStatus = "in_progress"
-	TaskStatusCompleted  TaskStatus = "completed"
-	TaskStatusFailed     TaskStatus = "failed"
+	TaskStatusPending          TaskStatus = "pending"
+	TaskStatusInProgress       TaskStatus = "in_progress"
+	TaskStatusAwaitingApproval TaskStatus = "awaiting_approval" // consensus reached; the winner waits for an admin before touching main
+	TaskStatusCompleted        TaskStatus = "completed"
+	TaskStatusFailed           TaskStatus = "failed"
+	TaskStatusCancelled        TaskStatus = "cancelled" // stopped by POST /tasks/:id/cancel
 )
 
 // Task represents a single optimization or analysis request in the system.
@@ -62,12 +64,16 @@ func (t *Task) Validate() error {
 		return errors.New("description exceeds 500 characters")
 	}
 
+	if _, _, err := t.ForkAt(); err != nil {
+		return err
+	}
+
 	if t.Status == "" {
 		t.Status = TaskStatusPending
 	}
 
 	switch t.Status {
-	case TaskStatusPending, TaskStatusInProgress, TaskStatusCompleted, TaskStatusFailed:
+	case TaskStatusPending, TaskStatusInProgress, TaskStatusAwaitingApproval, TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled:
 		// valid status
 	default:
 		return errors.New("invalid task status")
@@ -76,14 +82,38 @@ func (t *Task) Validate() error {
 	return nil
 }
 
+// ForkAt returns the moment set in metadata.fork_at (RFC3339): the task's forks are then
+// created from the main database as it was at that time instead of its current state.
+// ok is false when the option is not set.
+func (t *Task) ForkAt() (at time.Time, ok bool, err error) {
+	v, set := t.Metadata["fork_at"]
+	if !set || v == nil {
+		return time.Time{}, false, nil
+	}
+	s, isString := v.(string)
+	if !isString || strings.TrimSpace(s) == "" {
+		return time.Time{}, false, errors.New("metadata.fork_at must be an RFC3339 timestamp")
+	}
+	at, err = time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}, false, errors.New("metadata.fork_at must be an RFC3339 timestamp")
+	}
+	if at.After(time.Now()) {
+		return time.Time{}, false, errors.New("metadata.fork_at cannot be in the future")
+	}
+	return at.UTC(), true, nil
+}
+
 // CanTransitionTo validates whether the task can move to a given new status
 // according to the defined business rules.
 func (t *Task) CanTransitionTo(newStatus TaskStatus) bool {
 	validTransitions := map[TaskStatus][]TaskStatus{
-		TaskStatusPending:    {TaskStatusInProgress, TaskStatusFailed},
-		TaskStatusInProgress: {TaskStatusCompleted, TaskStatusFailed},
-		TaskStatusCompleted:  {},
-		TaskStatusFailed:     {},
+		TaskStatusPending:          {TaskStatusInProgress, TaskStatusFailed, TaskStatusCancelled},
+		TaskStatusInProgress:       {TaskStatusAwaitingApproval, TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled},
+		TaskStatusAwaitingApproval: {TaskStatusInProgress, TaskStatusCompleted, TaskStatusFailed, TaskStatusCancelled}, // approved (applying), rejected
+		TaskStatusCompleted:        {},
+		TaskStatusFailed:           {TaskStatusPending}, // retried from its last completed stage
+		TaskStatusCancelled:        {},
 	}
 
 	next, ok := validTransitions[t.Status]
@@ -0,0 +1,40 @@
+package entities
+
+import (
+	"errors"
+	"time"
+)
+
+// JobStatus is the state of a task's entry in the durable task queue.
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"  // waiting for a worker, not before RunAfter
+	JobRunning JobStatus = "running" // leased by LockedBy until LeaseUntil; the worker heartbeats to extend it
+	JobDone    JobStatus = "done"
+	JobFailed  JobStatus = "failed" // every attempt failed or its lease expired on the last one
+)
+
+var (
+	// ErrJobLeaseLost: the job is no longer leased to the worker (its lease expired and it was taken over).
+	ErrJobLeaseLost = errors.New("task job lease lost")
+	// ErrJobCancelled: the job's task was cancelled; the lease was extended so the worker can wind down.
+	ErrJobCancelled = errors.New("task of the job was cancelled")
+)
+
+// TaskJob is the queue entry that gets a task processed: one per task. A job whose lease
+// expires (the worker crashed or lost the database) is queued again while attempts remain.
+type TaskJob struct {
+	ID          int64
+	TaskID      int64
+	Status      JobStatus
+	Attempts    int // attempts started so far, including the running one
+	MaxAttempts int
+	RunAfter    time.Time
+	LockedBy    string
+	LeaseUntil  *time.Time
+	HeartbeatAt *time.Time
+	LastError   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
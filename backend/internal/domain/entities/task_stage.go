@@ -0,0 +1,47 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// PipelineStage is one persisted step of the task pipeline.
+type PipelineStage string
+
+const (
+	StageRouting   PipelineStage = "routing"   // agents selected for the task
+	StageForks     PipelineStage = "forks"     // one exclusive fork and agent_execution per agent
+	StageAnalysis  PipelineStage = "analysis"  // each agent's plan analysis
+	StageProposal  PipelineStage = "proposal"  // candidates proposed, benchmarked on the forks and revised
+	StageBenchmark PipelineStage = "benchmark" // proposals and their benchmark results stored
+	StageConsensus PipelineStage = "consensus" // winner decided and stored
+	StageApply     PipelineStage = "apply"     // winner applied to main, gated or skipped
+	StageCleanup   PipelineStage = "cleanup"   // forks released, task completed
+)
+
+// PipelineStages lists the stages in execution order.
+var PipelineStages = []PipelineStage{StageRouting, StageForks, StageAnalysis, StageProposal, StageBenchmark, StageConsensus, StageApply, StageCleanup}
+
+// StageStatus is the state of a stage checkpoint.
+type StageStatus string
+
+const (
+	StageRunning StageStatus = "running"
+	StageDone    StageStatus = "done"    // Output holds what later stages need; a retry skips the stage
+	StageFailed  StageStatus = "failed"  // a retry runs the stage again from Output, if any progress was kept
+	StageWaiting StageStatus = "waiting" // apply waits for an admin approval
+)
+
+// TaskStage is the checkpoint of one stage of a task: a retry resumes from the first stage
+// that is not done, rebuilding the earlier stages' results from their Output.
+type TaskStage struct {
+	ID         int64
+	TaskID     int64
+	Stage      PipelineStage
+	Status     StageStatus
+	Attempts   int
+	Output     json.RawMessage
+	Error      string
+	StartedAt  time.Time
+	FinishedAt *time.Time
+}
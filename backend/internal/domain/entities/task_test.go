@@ -75,6 +75,30 @@ func TestCanTransitionTo(t *testing.T) {
 	if !task.CanTransitionTo(TaskStatusFailed) {
 		t.Error("expected transition from in_progress → failed to be valid")
 	}
+	if !task.CanTransitionTo(TaskStatusAwaitingApproval) {
+		t.Error("expected transition from in_progress → awaiting_approval to be valid")
+	}
+
+	task.Status = TaskStatusAwaitingApproval
+	if !task.CanTransitionTo(TaskStatusInProgress) || !task.CanTransitionTo(TaskStatusCompleted) {
+		t.Error("expected approval (→ in_progress) and rejection (→ completed) to be valid")
+	}
+	if task.CanTransitionTo(TaskStatusPending) {
+		t.Error("expected transition from awaiting_approval → pending to be invalid")
+	}
+	if !task.CanTransitionTo(TaskStatusCancelled) {
+		t.Error("expected an unfinished task to be cancellable")
+	}
+
+	task.Status = TaskStatusCompleted
+	if task.CanTransitionTo(TaskStatusCancelled) {
+		t.Error("expected a completed task not to be cancellable")
+	}
+
+	task.Status = TaskStatusFailed
+	if !task.CanTransitionTo(TaskStatusPending) || task.CanTransitionTo(TaskStatusInProgress) {
+		t.Error("expected a failed task to be retryable only through pending")
+	}
 }
 
 func TestIsComplete(t *testing.T) {
@@ -125,3 +149,23 @@ func TestTask_Timestamps(t *testing.T) {
 		t.Error("expected CompletedAt to be set")
 	}
 }
+
+func TestTask_ForkAt(t *testing.T) {
+	task := &Task{Type: TaskTypeQueryOptimization, TargetQuery: "SELECT 1;"}
+	if _, ok, err := task.ForkAt(); ok || err != nil {
+		t.Fatalf("expected no fork_at, got ok=%v err=%v", ok, err)
+	}
+
+	task.Metadata = map[string]interface{}{"fork_at": "2025-11-05T18:30:00+02:00"}
+	at, ok, err := task.ForkAt()
+	if err != nil || !ok || !at.Equal(time.Date(2025, 11, 5, 16, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected fork_at: %v ok=%v err=%v", at, ok, err)
+	}
+
+	for _, bad := range []interface{}{"yesterday", 1730000000, time.Now().Add(time.Hour).Format(time.RFC3339)} {
+		task.Metadata["fork_at"] = bad
+		if err := task.Validate(); err == nil {
+			t.Errorf("expected fork_at %v to be rejected", bad)
+		}
+	}
+}
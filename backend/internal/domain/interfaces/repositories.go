@@ -2,6 +2,8 @@ package interfaces
 
 import (
 	"context"
+	"time"
+
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
 )
 
@@ -38,3 +40,33 @@ type ConsensusRepository interface {
 	GetByTaskID(ctx context.Context, taskID int) (*entities.ConsensusDecision, error)
 	Update(ctx context.Context, decision *entities.ConsensusDecision) error
 }
+
+type AgentAttemptRepository interface {
+	Create(ctx context.Context, attempt *entities.AgentAttempt) error
+	GetByAgentExecutionID(ctx context.Context, execID int) ([]*entities.AgentAttempt, error)
+}
+
+// TaskStageRepository stores the pipeline checkpoints of tasks, one per task and stage.
+type TaskStageRepository interface {
+	// Save inserts the checkpoint of the stage or replaces the previous one.
+	Save(ctx context.Context, stage *entities.TaskStage) error
+	// GetByTaskID returns the task's checkpoints in pipeline order.
+	GetByTaskID(ctx context.Context, taskID int) ([]*entities.TaskStage, error)
+}
+
+// TaskQueue is the durable queue of task jobs: at most one worker holds a job's lease at a time.
+type TaskQueue interface {
+	// Enqueue queues the task; a task whose job already finished or failed is queued again.
+	Enqueue(ctx context.Context, taskID int64, maxAttempts int) error
+	// Claim leases the next due job to workerID, or returns nil when none is due.
+	Claim(ctx context.Context, workerID string, lease time.Duration) (*entities.TaskJob, error)
+	// Heartbeat extends the lease; it fails with ErrJobLeaseLost once another worker has taken the
+	// job over, and with ErrJobCancelled once its task was cancelled.
+	Heartbeat(ctx context.Context, jobID int64, workerID string, lease time.Duration) error
+	Complete(ctx context.Context, jobID int64, workerID string) error
+	// Fail queues the job again after retryAfter while attempts remain, otherwise fails it and its task.
+	Fail(ctx context.Context, jobID int64, workerID, reason string, retryAfter time.Duration) error
+	// Recover queues jobs whose lease expired, tasks left pending or in progress without a job, and
+	// approved in-progress tasks whose job already finished.
+	Recover(ctx context.Context, maxAttempts int) (int, error)
+}
@@ -1,5 +1,10 @@
 package values
 
+import (
+	"strings"
+	"sync"
+)
+
 // AgentType defines the specific type of LLM agent used in the execution.
 type AgentType string
 
@@ -18,30 +23,68 @@ const (
 	SpecializationBulk        AgentSpecialization = "Boilerplate/Refactors"
 )
 
+// agentTypes holds the known agent types, their specializations and the aliases
+// ParseAgentType accepts. The built-in roles are registered here; additional kinds
+// are registered by the agents registry (see agents.RegisterKind).
+var agentTypes = struct {
+	sync.RWMutex
+	order   []AgentType
+	spec    map[AgentType]AgentSpecialization
+	aliases map[string]AgentType
+}{spec: map[AgentType]AgentSpecialization{}, aliases: map[string]AgentType{}}
+
+func init() {
+	RegisterAgentType(AgentCerebro, SpecializationPlanningQA, "gemini-2.5-pro", "gemini25pro")
+	RegisterAgentType(AgentOperativo, SpecializationOperational, "gemini-2.5-flash", "gemini25flash")
+	RegisterAgentType(AgentBulk, SpecializationBulk, "gemini-2.0-flash", "gemini20flash")
+}
+
+// RegisterAgentType declares an agent type as valid. The type name itself is always an alias.
+// Registering an existing type replaces its specialization and adds the new aliases.
+func RegisterAgentType(t AgentType, spec AgentSpecialization, aliases ...string) {
+	agentTypes.Lock()
+	defer agentTypes.Unlock()
+	if _, ok := agentTypes.spec[t]; !ok {
+		agentTypes.order = append(agentTypes.order, t)
+	}
+	agentTypes.spec[t] = spec
+	agentTypes.aliases[string(t)] = t
+	for _, a := range aliases {
+		if a = strings.TrimSpace(a); a != "" {
+			agentTypes.aliases[a] = t
+		}
+	}
+}
+
+// RegisteredAgentTypes returns the known agent types in registration order.
+func RegisteredAgentTypes() []AgentType {
+	agentTypes.RLock()
+	defer agentTypes.RUnlock()
+	return append([]AgentType(nil), agentTypes.order...)
+}
+
+// IsValid reports whether the agent type has been registered.
+func (a AgentType) IsValid() bool {
+	agentTypes.RLock()
+	defer agentTypes.RUnlock()
+	_, ok := agentTypes.spec[a]
+	return ok
+}
+
 // GetSpecialization returns the specific expertise for a given agent type.
 func (a AgentType) GetSpecialization() AgentSpecialization {
-	switch a {
-	case AgentCerebro:
-		return SpecializationPlanningQA
-	case AgentOperativo:
-		return SpecializationOperational
-	case AgentBulk:
-		return SpecializationBulk
-	default:
-		return ""
-	}
+	agentTypes.RLock()
+	defer agentTypes.RUnlock()
+	return agentTypes.spec[a]
 }
 
 // ParseAgentType maps a string to the AgentType using role/model aliases.
+// Unknown values fall back to AgentOperativo.
 func ParseAgentType(s string) AgentType {
-	switch s {
-	case "cerebro", "gemini-2.5-pro", "gemini25pro":
-		return AgentCerebro
-	case "operativo", "gemini-2.5-flash", "gemini25flash":
-		return AgentOperativo
-	case "bulk", "gemini-2.0-flash", "gemini20flash":
-		return AgentBulk
-	default:
-		return AgentOperativo
+	agentTypes.RLock()
+	defer agentTypes.RUnlock()
+	if t, ok := agentTypes.aliases[s]; ok {
+		return t
 	}
+	return AgentOperativo
 }
@@ -34,4 +34,17 @@ func TestParseAgentType_Aliases(t *testing.T) {
 			t.Errorf("ParseAgentType(%q)=%q, want %q", c.in, got, c.want)
 		}
 	}
-}
\ No newline at end of file
+}
+func TestRegisterAgentType(t *testing.T) {
+	vacuum := AgentType("vacuum")
+	if vacuum.IsValid() { t.Fatalf("vacuum should not be registered yet") }
+	RegisterAgentType(vacuum, "Maintenance/Statistics", "vacuum-stats")
+	if !vacuum.IsValid() { t.Fatalf("expected vacuum to be valid after registration") }
+	if got := ParseAgentType("vacuum-stats"); got != vacuum { t.Errorf("alias not resolved, got %q", got) }
+	if got := vacuum.GetSpecialization(); got != "Maintenance/Statistics" { t.Errorf("unexpected specialization %q", got) }
+	types := RegisteredAgentTypes()
+	if len(types) < 4 || types[0] != AgentCerebro || types[len(types)-1] != vacuum {
+		t.Errorf("unexpected registration order: %v", types)
+	}
+	if ParseAgentType("nope") != AgentOperativo { t.Errorf("unknown values should fall back to operativo") }
+}
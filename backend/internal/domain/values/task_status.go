@@ -3,15 +3,17 @@ package values
 type TaskStatus string
 
 const (
-	TaskPending    TaskStatus = "pending"
-	TaskInProgress TaskStatus = "in_progress"
-	TaskCompleted  TaskStatus = "completed"
-	TaskFailed     TaskStatus = "failed"
+	TaskPending          TaskStatus = "pending"
+	TaskInProgress       TaskStatus = "in_progress"
+	TaskAwaitingApproval TaskStatus = "awaiting_approval"
+	TaskCompleted        TaskStatus = "completed"
+	TaskFailed           TaskStatus = "failed"
+	TaskCancelled        TaskStatus = "cancelled"
 )
 
 func (s TaskStatus) IsValid() bool {
 	switch s {
-	case TaskPending, TaskInProgress, TaskCompleted, TaskFailed:
+	case TaskPending, TaskInProgress, TaskAwaitingApproval, TaskCompleted, TaskFailed, TaskCancelled:
 		return true
 	default:
 		return false
@@ -0,0 +1,216 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+// ApplyStatement is one proposal statement prepared for the main database.
+type ApplyStatement struct {
+	SQL           string // as it will run, after rewrites
+	Relation      string // relation the statement locks; empty when none is checked
+	Lock          string // lock mode taken on Relation
+	Transactional bool   // false for statements PostgreSQL refuses inside a transaction block
+	Rewritten     bool   // CREATE INDEX turned into CREATE INDEX CONCURRENTLY
+}
+
+// ApplyPlan is the result of the pre-flight check of a proposal against the main database.
+type ApplyPlan struct {
+	Statements []ApplyStatement
+	Checks     []entities.RelationCheck
+	Blocked    string // why the apply must not start; empty when it may
+}
+
+// Transactional reports whether every statement can run in one transaction.
+func (p *ApplyPlan) Transactional() bool {
+	for _, s := range p.Statements {
+		if !s.Transactional { return false }
+	}
+	return true
+}
+
+// PreflightOptions tune the pre-flight check.
+type PreflightOptions struct {
+	ConcurrentIndexBytes int64   // CREATE INDEX on tables at least this large runs CONCURRENTLY
+	MaxLockWaitS         float64 // a conflicting lock held by a transaction open longer than this blocks the apply
+}
+
+// lockConflicts is PostgreSQL's table-level lock conflict table.
+var lockConflicts = map[string][]string{
+	"AccessShareLock":          {"AccessExclusiveLock"},
+	"RowShareLock":             {"ExclusiveLock", "AccessExclusiveLock"},
+	"RowExclusiveLock":         {"ShareLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+	"ShareUpdateExclusiveLock": {"ShareUpdateExclusiveLock", "ShareLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+	"ShareLock":                {"RowExclusiveLock", "ShareUpdateExclusiveLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+	"ShareRowExclusiveLock":    {"RowExclusiveLock", "ShareUpdateExclusiveLock", "ShareLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+	"ExclusiveLock":            {"RowShareLock", "RowExclusiveLock", "ShareUpdateExclusiveLock", "ShareLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+	"AccessExclusiveLock":      {"AccessShareLock", "RowShareLock", "RowExclusiveLock", "ShareUpdateExclusiveLock", "ShareLock", "ShareRowExclusiveLock", "ExclusiveLock", "AccessExclusiveLock"},
+}
+
+func locksConflict(a, b string) bool {
+	for _, m := range lockConflicts[a] {
+		if m == b { return true }
+	}
+	return false
+}
+
+var (
+	createIndexRe  = regexp.MustCompile(`(?i)^\s*CREATE\s+(UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:[\w."]+\s+)?ON\s+(?:ONLY\s+)?([\w."]+)`)
+	alterTableRe   = regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)`)
+	maintenanceRe  = regexp.MustCompile(`(?i)^\s*(ANALYZE|VACUUM)\b(?:\s*\([^)]*\))?(?:\s+VERBOSE)?\s+([\w."]+)`)
+	refreshRe      = regexp.MustCompile(`(?i)^\s*REFRESH\s+MATERIALIZED\s+VIEW\s+(CONCURRENTLY\s+)?([\w."]+)`)
+	statisticsRe   = regexp.MustCompile(`(?i)^\s*CREATE\s+STATISTICS\b.*\bFROM\s+([\w."]+)`)
+	indexKeywordRe = regexp.MustCompile(`(?i)\bINDEX\s+`)
+	outsideTxRe    = regexp.MustCompile(`(?i)^\s*(VACUUM\b|(CREATE|DROP)\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\b|REINDEX\b.*\bCONCURRENTLY\b|CREATE\s+DATABASE\b|DROP\s+DATABASE\b|ALTER\s+SYSTEM\b)`)
+)
+
+// classifyStatement returns the relation a statement locks and the lock mode it takes.
+func classifyStatement(sql string) ApplyStatement {
+	st := ApplyStatement{SQL: strings.TrimRight(strings.TrimSpace(sql), "; \n\t"), Transactional: !outsideTxRe.MatchString(sql)}
+	switch {
+	case createIndexRe.MatchString(sql):
+		m := createIndexRe.FindStringSubmatch(sql)
+		st.Relation, st.Lock = m[3], "ShareLock"
+		if m[2] != "" { st.Lock = "ShareUpdateExclusiveLock" }
+	case alterTableRe.MatchString(sql):
+		st.Relation, st.Lock = alterTableRe.FindStringSubmatch(sql)[1], "AccessExclusiveLock"
+	case maintenanceRe.MatchString(sql):
+		st.Relation, st.Lock = maintenanceRe.FindStringSubmatch(sql)[2], "ShareUpdateExclusiveLock"
+	case refreshRe.MatchString(sql):
+		m := refreshRe.FindStringSubmatch(sql)
+		st.Relation, st.Lock = m[2], "AccessExclusiveLock"
+		if m[1] != "" { st.Lock = "ExclusiveLock" }
+	case statisticsRe.MatchString(sql):
+		st.Relation, st.Lock = statisticsRe.FindStringSubmatch(sql)[1], "ShareUpdateExclusiveLock"
+	}
+	return st
+}
+
+// concurrently rewrites a plain CREATE [UNIQUE] INDEX into CREATE [UNIQUE] INDEX CONCURRENTLY.
+func concurrently(sql string) string {
+	loc := indexKeywordRe.FindStringIndex(sql)
+	if loc == nil { return sql }
+	return sql[:loc[1]] + "CONCURRENTLY " + sql[loc[1]:]
+}
+
+type relationLock struct {
+	mode    string
+	pid     int64
+	state   string
+	xactAge float64
+}
+
+// PlanApply runs the pre-flight check of stmts against serviceID. It sizes every locked relation
+// and lists the locks other sessions hold on it. A plain CREATE INDEX is rewritten to CONCURRENTLY
+// when its table is large or has concurrent writers, so it does not block them; the apply is blocked
+// when a conflicting lock belongs to a transaction that has been open longer than MaxLockWaitS
+// (or sits idle in transaction), since the statement would only queue behind it.
+func PlanApply(ctx context.Context, q mcpQueryPort, serviceID string, stmts []string, opts PreflightOptions) (*ApplyPlan, error) {
+	plan := &ApplyPlan{}
+	checks := map[string]int{}
+	locks := map[string][]relationLock{}
+	relkinds := map[string]string{}
+	for _, raw := range stmts {
+		if strings.TrimSpace(raw) == "" { continue }
+		st := classifyStatement(raw)
+		if st.Relation != "" {
+			key := strings.ToLower(st.Relation)
+			if _, ok := checks[key]; !ok {
+				check, kind, held, err := inspectRelation(ctx, q, serviceID, st.Relation)
+				if err != nil { return nil, fmt.Errorf("preflight %s: %w", st.Relation, err) }
+				checks[key] = len(plan.Checks)
+				plan.Checks = append(plan.Checks, check)
+				locks[key], relkinds[key] = held, kind
+			}
+			check := &plan.Checks[checks[key]]
+			writers := false
+			for _, l := range locks[key] {
+				if locksConflict(l.mode, "ShareLock") { writers = true }
+			}
+			if st.Lock == "ShareLock" && relkinds[key] == "r" && (check.SizeBytes >= opts.ConcurrentIndexBytes || writers) {
+				st.SQL, st.Lock, st.Transactional, st.Rewritten = concurrently(st.SQL), "ShareUpdateExclusiveLock", false, true
+			}
+			for _, l := range locks[key] {
+				if !locksConflict(st.Lock, l.mode) { continue }
+				desc := fmt.Sprintf("pid %d %s (%s, %.1fs)", l.pid, l.mode, l.state, l.xactAge)
+				if !containsString(check.Locks, desc) { check.Locks = append(check.Locks, desc) }
+				if plan.Blocked == "" && (l.state == "idle in transaction" || l.xactAge > opts.MaxLockWaitS) {
+					plan.Blocked = fmt.Sprintf("%s needs %s on %s, held against it by %s", firstWord(st.SQL), st.Lock, st.Relation, desc)
+				}
+			}
+		}
+		plan.Statements = append(plan.Statements, st)
+	}
+	return plan, nil
+}
+
+// inspectRelation reads the size and kind of a relation and the locks other sessions hold on it.
+func inspectRelation(ctx context.Context, q mcpQueryPort, serviceID, relation string) (entities.RelationCheck, string, []relationLock, error) {
+	check := entities.RelationCheck{Relation: relation}
+	lit := quoteLiteral(relation)
+	res, err := q.ExecuteQuery(ctx, serviceID, `SELECT pg_total_relation_size(c.oid)::float8 AS bytes, GREATEST(c.reltuples, 0)::float8 AS est_rows,
+		c.relkind::text AS relkind FROM pg_class c WHERE c.oid = to_regclass(`+lit+`)`, catalogTimeoutMs)
+	if err != nil { return check, "", nil, err }
+	if len(res.Rows) == 0 { return check, "", nil, nil }
+	check.Exists = true
+	check.SizeBytes = int64(rowFloat(res.Rows[0], "bytes"))
+	check.EstimatedRows = int64(rowFloat(res.Rows[0], "est_rows"))
+	kind := rowString(res.Rows[0], "relkind")
+
+	res, err = q.ExecuteQuery(ctx, serviceID, `SELECT l.mode, l.pid::float8 AS pid, COALESCE(a.state, '') AS state,
+		COALESCE(EXTRACT(EPOCH FROM now() - a.xact_start), 0)::float8 AS xact_age_s
+		FROM pg_locks l LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'relation' AND l.granted AND l.relation = to_regclass(`+lit+`) AND l.pid <> pg_backend_pid()`, catalogTimeoutMs)
+	if err != nil { return check, kind, nil, err }
+	held := make([]relationLock, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		held = append(held, relationLock{mode: rowString(row, "mode"), pid: int64(rowFloat(row, "pid")), state: rowString(row, "state"), xactAge: rowFloat(row, "xact_age_s")})
+	}
+	return check, kind, held, nil
+}
+
+// ProposalStatements returns the proposal SQL to apply outside a fork: every non-empty statement,
+// minus the rewritten query of a query_rewrite proposal, which replaces the task query in the
+// application rather than running against the database.
+func ProposalStatements(proposal *entities.OptimizationProposal) []string {
+	if proposal == nil { return nil }
+	rewrite := ""
+	if proposal.ProposalType == values.ProposalQueryRewrite { rewrite = rewrittenQuery(proposal) }
+	var out []string
+	for _, stmt := range proposal.SQLCommands {
+		if strings.TrimSpace(stmt) == "" { continue }
+		if rewrite != "" && strings.TrimRight(strings.TrimSpace(stmt), "; \n\t") == rewrite { continue }
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// RollbackScript returns the statements undoing applied on a live database, newest first.
+// It is CompensatingStatements with index drops made CONCURRENTLY, so rolling back an index
+// does not block writers; every statement runs on its own, outside a transaction.
+func RollbackScript(applied []string, before *SchemaSnapshot) []string {
+	out := CompensatingStatements(applied, before)
+	for i, s := range out {
+		if rest, ok := strings.CutPrefix(s, "DROP INDEX IF EXISTS "); ok { out[i] = "DROP INDEX CONCURRENTLY IF EXISTS " + rest }
+	}
+	return out
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s { return true }
+	}
+	return false
+}
+
+func firstWord(sql string) string {
+	f := strings.Fields(sql)
+	if len(f) == 0 { return "" }
+	if len(f) > 1 && (strings.EqualFold(f[0], "CREATE") || strings.EqualFold(f[0], "ALTER")) { return strings.ToUpper(f[0] + " " + f[1]) }
+	return strings.ToUpper(f[0])
+}
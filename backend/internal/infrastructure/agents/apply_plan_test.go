@@ -0,0 +1,77 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// preflightMCP answers the pre-flight catalog queries from fixed relation sizes and held locks.
+type preflightMCP struct {
+	bytes map[string]float64
+	locks map[string][]map[string]any
+}
+
+func (m *preflightMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	for rel, size := range m.bytes {
+		if !strings.Contains(sql, "to_regclass('"+rel+"')") { continue }
+		if strings.Contains(sql, "pg_locks") { return mcp.QueryResult{Rows: m.locks[rel]}, nil }
+		return mcp.QueryResult{Rows: []map[string]any{{"bytes": size, "est_rows": size / 100, "relkind": "r"}}}, nil
+	}
+	return mcp.QueryResult{}, nil
+}
+
+func TestPlanApply_RewritesLargeIndexesAndBlocksOnOldLocks(t *testing.T) {
+	q := &preflightMCP{
+		bytes: map[string]float64{"orders": 500 << 20, "users": 1 << 20},
+		locks: map[string][]map[string]any{
+			"users": {{"mode": "RowExclusiveLock", "pid": float64(7), "state": "active", "xact_age_s": 0.1}},
+		},
+	}
+	opts := PreflightOptions{ConcurrentIndexBytes: 100 << 20, MaxLockWaitS: 5}
+
+	plan, err := PlanApply(context.Background(), q, "main", []string{"CREATE INDEX idx_o ON orders(status);", "ANALYZE orders"}, opts)
+	if err != nil { t.Fatal(err) }
+	if plan.Blocked != "" { t.Fatalf("unexpected block: %s", plan.Blocked) }
+	if st := plan.Statements[0]; !st.Rewritten || st.SQL != "CREATE INDEX CONCURRENTLY idx_o ON orders(status)" || st.Transactional {
+		t.Errorf("expected the index on the large table to run CONCURRENTLY outside a transaction, got %+v", st)
+	}
+	if plan.Transactional() { t.Error("a plan with CREATE INDEX CONCURRENTLY cannot be transactional") }
+	if len(plan.Checks) != 1 || plan.Checks[0].SizeBytes != 500<<20 || !plan.Checks[0].Exists {
+		t.Errorf("expected one relation check for orders, got %+v", plan.Checks)
+	}
+
+	// A small table with an active writer is still rewritten, so the index does not block it.
+	plan, err = PlanApply(context.Background(), q, "main", []string{"CREATE INDEX idx_u ON users(email)"}, opts)
+	if err != nil { t.Fatal(err) }
+	if !plan.Statements[0].Rewritten || plan.Blocked != "" {
+		t.Errorf("expected a CONCURRENTLY rewrite on a table with writers, got %+v blocked=%q", plan.Statements[0], plan.Blocked)
+	}
+
+	// ALTER TABLE conflicts with every lock; an old writer transaction blocks the apply.
+	q.locks["users"][0]["xact_age_s"] = 30.0
+	plan, err = PlanApply(context.Background(), q, "main", []string{"ALTER TABLE users ADD COLUMN nickname text"}, opts)
+	if err != nil { t.Fatal(err) }
+	if !strings.Contains(plan.Blocked, "AccessExclusiveLock on users") || len(plan.Checks[0].Locks) != 1 {
+		t.Errorf("expected the old transaction to block the ALTER, got %q %+v", plan.Blocked, plan.Checks)
+	}
+}
+
+func TestRollbackScriptAndProposalStatements(t *testing.T) {
+	before := &SchemaSnapshot{Objects: []SchemaObject{{Kind: "table", Name: "public.orders"}}}
+	got := RollbackScript([]string{"CREATE INDEX CONCURRENTLY idx_o ON orders(status)", "ALTER TABLE orders ADD COLUMN note text"}, before)
+	want := []string{"ALTER TABLE IF EXISTS orders DROP COLUMN IF EXISTS note", "DROP INDEX CONCURRENTLY IF EXISTS idx_o"}
+	if strings.Join(got, "|") != strings.Join(want, "|") { t.Errorf("RollbackScript = %v, want %v", got, want) }
+
+	p := &entities.OptimizationProposal{
+		ProposalType: values.ProposalQueryRewrite,
+		SQLCommands:  []string{"CREATE INDEX idx_o ON orders(status)", " ", "SELECT id FROM orders WHERE status = 'x';"},
+	}
+	if stmts := ProposalStatements(p); len(stmts) != 1 || stmts[0] != "CREATE INDEX idx_o ON orders(status)" {
+		t.Errorf("expected the rewritten query to stay off main, got %v", stmts)
+	}
+}
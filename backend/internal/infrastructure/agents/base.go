@@ -27,6 +27,7 @@ type BaseAgent struct {
 	Cfg       *cfgpkg.Config
 	Repo      domainif.AgentExecutionRepository
 	AgentType values.AgentType
+	Prompts   PromptProfile
 }
 
 // CreateFork creates a fork with standard naming and registers an AgentExecution.
@@ -71,6 +72,18 @@ func (a *BaseAgent) forkName(taskID int64) string {
 	return fmt.Sprintf("afs-fork-%s-task%d-%d", string(a.AgentType), taskID, ts)
 }
 
+// benchmarkOptions returns the configured benchmark settings; safe on a nil BaseAgent.
+func (a *BaseAgent) benchmarkOptions() BenchmarkOptions {
+	if a == nil { return DefaultBenchmarkOptions() }
+	return BenchmarkOptionsFromConfig(a.Cfg)
+}
+
+// prompts returns the configured prompt profile, completed from the agent's defaults; safe on a nil BaseAgent.
+func (a *BaseAgent) prompts(def PromptProfile) PromptProfile {
+	if a == nil { return def }
+	return a.Prompts.withDefaults(def)
+}
+
 // IsValidForkName validates the naming convention.
 func IsValidForkName(name string) bool {
 	re := regexp.MustCompile(`^afs-fork-[a-z0-9]+-task[0-9]+-[0-9]{10}$`)
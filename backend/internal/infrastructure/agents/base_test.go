@@ -39,6 +39,9 @@ func (r *mockAgentExecutionRepo) GetByID(ctx context.Context, id int) (*entities
 func (r *mockAgentExecutionRepo) GetByTaskID(ctx context.Context, taskID int) ([]*entities.AgentExecution, error) {
 	return nil, nil
 }
+func (r *mockAgentExecutionRepo) List(ctx context.Context) ([]*entities.AgentExecution, error) {
+	return nil, nil
+}
 func (r *mockAgentExecutionRepo) Update(ctx context.Context, exec *entities.AgentExecution) error { return nil }
 
 // Now test BaseAgent
@@ -0,0 +1,208 @@
+package agents
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+const (
+	benchmarkTimeoutMs = 120000
+	applyTimeoutMs     = 600000
+)
+
+// BenchmarkOptions controls how many times each benchmark query runs and how runs are timed.
+// Warmup runs are executed and discarded; only measured runs feed the statistics.
+type BenchmarkOptions struct {
+	WarmupRuns   int
+	MeasuredRuns int
+	Alpha        float64        // significance level for the baseline comparison
+	Timing       mcp.TimingMode // client by default; explain or pg_stat_statements keep network and row scanning out of the samples
+}
+
+// DefaultBenchmarkOptions: one warmup and five measured runs, timed in the client. Four runs
+// already reach p < 0.05 with the exact Mann-Whitney test, but only when no sample overlaps
+// (2/70 ≈ 0.029); five stay significant with up to two out-of-order pairs (8/252 ≈ 0.032),
+// so slightly overlapping samples still show a real change.
+func DefaultBenchmarkOptions() BenchmarkOptions {
+	return BenchmarkOptions{WarmupRuns: 1, MeasuredRuns: 5, Alpha: 0.05, Timing: mcp.TimingClient}
+}
+
+// BenchmarkOptionsFromConfig reads the benchmark settings, falling back to defaults.
+func BenchmarkOptionsFromConfig(cfg *cfgpkg.Config) BenchmarkOptions {
+	if cfg == nil { return DefaultBenchmarkOptions() }
+	opts := BenchmarkOptions{WarmupRuns: cfg.Benchmark.WarmupRuns, MeasuredRuns: cfg.Benchmark.MeasuredRuns, Alpha: cfg.Benchmark.Alpha}
+	if t := strings.TrimSpace(cfg.Benchmark.Timing); t != "" { opts.Timing = mcp.ParseTimingMode(t) }
+	return opts.normalized()
+}
+
+func (o BenchmarkOptions) normalized() BenchmarkOptions {
+	def := DefaultBenchmarkOptions()
+	if o.WarmupRuns < 0 { o.WarmupRuns = def.WarmupRuns }
+	if o.MeasuredRuns <= 0 { o.MeasuredRuns = def.MeasuredRuns }
+	if o.Alpha <= 0 || o.Alpha >= 1 { o.Alpha = def.Alpha }
+	if o.Timing == "" { o.Timing = def.Timing }
+	return o
+}
+
+// BenchmarkProposal is the single benchmark path shared by every agent and by usecases.BenchmarkRunner.
+// It measures the task query on the fork, applies the proposal SQL, then measures the
+// suite again, so baseline and optimized numbers come from the same query on the same fork.
+// Every post-apply result carries a Mann-Whitney comparison against the baseline samples,
+// and every result stores the EXPLAIN ANALYZE plan of one extra run after the measured ones.
+// Relation sizes are snapshotted around the apply step; post-apply results carry the measured delta.
+// For query_rewrite proposals the rewrite is verified against the original (see VerifyEquivalence)
+// and then measured in its place.
+func BenchmarkProposal(ctx context.Context, q mcpQueryPort, proposal *entities.OptimizationProposal, forkID, query string, opts BenchmarkOptions) ([]*entities.BenchmarkResult, error) {
+	if q == nil { return nil, errors.New("benchmark: mcp client not initialized") }
+	if proposal == nil || proposal.ID == 0 { return nil, errors.New("benchmark: proposal is required with valid ID") }
+	if forkID == "" { return nil, errors.New("benchmark: forkID is required") }
+	base := strings.TrimRight(strings.TrimSpace(query), "; \n\t")
+	if base == "" { return nil, errors.New("benchmark: target query is required") }
+	opts = opts.normalized()
+
+	// Baseline runs before the proposal touches the fork; the variants wrap the query
+	// as a subquery so they stay valid whatever LIMIT/ORDER BY the original carries.
+	suite := []struct {
+		name  entities.BenchmarkQueryName
+		build func(q string) string
+	}{
+		{entities.QueryNameBaseline, func(q string) string { return q }},
+		{entities.QueryNameTestLimit, func(q string) string { return fmt.Sprintf("SELECT * FROM (%s) AS afs_bench LIMIT 10", q) }},
+		{entities.QueryNameTestSort, func(q string) string { return fmt.Sprintf("SELECT * FROM (%s) AS afs_bench ORDER BY 1", q) }},
+	}
+
+	// A query_rewrite proposal replaces the measured query after the apply step,
+	// once the rewrite is shown to return the same data as the original.
+	rewrite := ""
+	if proposal.ProposalType == values.ProposalQueryRewrite { rewrite = rewrittenQuery(proposal) }
+	measured := base
+
+	results := make([]*entities.BenchmarkResult, 0, len(suite))
+	var baseline []float64
+	var storage *entities.StorageDelta
+	for i, t := range suite {
+		sql := t.build(measured)
+		run, err := sampleRuns(ctx, q, forkID, sql, opts)
+		if err != nil { return nil, fmt.Errorf("benchmark %s: %w", t.name, err) }
+		samples, rows := run.samples, run.rows
+		plan, err := ExplainAnalyze(ctx, q, forkID, sql)
+		if err != nil { return nil, fmt.Errorf("benchmark %s explain: %w", t.name, err) }
+		mean, stats := summarizeSamples(samples, opts.WarmupRuns)
+		stats.Timing, stats.PlanningMS = run.timing, run.planningMS
+		if i == 0 {
+			baseline = samples
+		} else {
+			stats.Significance = mannWhitney(baseline, samples, opts.Alpha)
+			stats.Storage = storage
+		}
+		br := &entities.BenchmarkResult{
+			ProposalID:      proposal.ID,
+			QueryName:       t.name,
+			QueryExecuted:   sql,
+			ExecutionTimeMS: mean,
+			RowsReturned:    rows,
+			ExplainPlan:     plan.ToExplainPlan(),
+			Stats:           stats,
+			CreatedAt:       time.Now().UTC(),
+		}
+		if mb, ok := br.MeasuredStorageMB(); ok { br.StorageImpactMB = math.Round(mb*100) / 100 }
+		if err := br.Validate(); err != nil { return nil, err }
+		results = append(results, br)
+
+		if i == 0 {
+			snap := snapshotStorage(ctx, q, forkID, affectedRelations(plan, proposal))
+			if err := applyProposal(ctx, q, forkID, proposal, rewrite); err != nil { return nil, err }
+			storage = snap.delta(ctx, q, forkID)
+			if proposal.ProposalType == values.ProposalQueryRewrite {
+				eq := VerifyEquivalence(ctx, q, forkID, base, rewrite)
+				proposal.EstimatedImpact.Equivalence = eq
+				// A rewrite that changes results is disqualified in consensus; don't time it.
+				if !eq.Equivalent { return results, nil }
+				measured = rewrite
+			}
+		}
+	}
+	return results, nil
+}
+
+// applyProposal executes the proposal SQL on the fork, in order, skipping the rewritten
+// query of a query_rewrite proposal, which is measured rather than applied.
+func applyProposal(ctx context.Context, q mcpQueryPort, forkID string, proposal *entities.OptimizationProposal, rewrite string) error {
+	for _, stmt := range proposal.SQLCommands {
+		if strings.TrimSpace(stmt) == "" { continue }
+		if rewrite != "" && strings.TrimRight(strings.TrimSpace(stmt), "; \n\t") == rewrite { continue }
+		if _, err := q.ExecuteQuery(ctx, forkID, stmt, applyTimeoutMs); err != nil {
+			return fmt.Errorf("apply proposal on fork %s: %w", forkID, err)
+		}
+	}
+	return nil
+}
+
+// MeasureQuery times sql on serviceID the way BenchmarkProposal times each suite query and
+// summarizes the measured runs. The apply pipeline uses it on the main database, before and after.
+func MeasureQuery(ctx context.Context, q mcpQueryPort, serviceID, sql string, opts BenchmarkOptions) (entities.BenchmarkStats, error) {
+	if q == nil { return entities.BenchmarkStats{}, errors.New("benchmark: mcp client not initialized") }
+	opts = opts.normalized()
+	run, err := sampleRuns(ctx, q, serviceID, sql, opts)
+	if err != nil { return entities.BenchmarkStats{}, err }
+	_, stats := summarizeSamples(run.samples, opts.WarmupRuns)
+	stats.Timing, stats.PlanningMS = run.timing, run.planningMS
+	return stats, nil
+}
+
+// CompareRuns tests the samples of after against before with the benchmark's Mann-Whitney U test.
+func CompareRuns(before, after entities.BenchmarkStats, alpha float64) *entities.Significance {
+	return mannWhitney(before.SamplesMS, after.SamplesMS, alpha)
+}
+
+// sampledRuns are the measured runs of one benchmark query.
+type sampledRuns struct {
+	samples    []float64
+	rows       int64
+	timing     string  // timing source of the measured runs; mixed sources are reported as client
+	planningMS float64 // median server planning time, 0 when not reported
+}
+
+// sampleRuns executes the warmup runs, then returns one timing per measured run.
+// Runs are timed in opts.Timing; MCP falls back to client timing where the mode does not apply.
+func sampleRuns(ctx context.Context, q mcpQueryPort, forkID, sql string, opts BenchmarkOptions) (sampledRuns, error) {
+	if opts.Timing != "" { ctx = mcp.WithTimingMode(ctx, opts.Timing) }
+	for i := 0; i < opts.WarmupRuns; i++ {
+		if _, err := q.ExecuteQuery(ctx, forkID, sql, benchmarkTimeoutMs); err != nil { return sampledRuns{}, err }
+	}
+	out := sampledRuns{samples: make([]float64, 0, opts.MeasuredRuns)}
+	var planning []float64
+	for i := 0; i < opts.MeasuredRuns; i++ {
+		qr, err := q.ExecuteQuery(ctx, forkID, sql, benchmarkTimeoutMs)
+		if err != nil { return sampledRuns{}, err }
+		execTime := qr.ExecutionTimeMs
+		if execTime <= 0 {
+			execTime = 1.0 // fallback si MCP no devuelve tiempo
+		}
+		out.samples = append(out.samples, execTime)
+		out.rows = int64(qr.RowCount)
+		if qr.PlanningTimeMs > 0 { planning = append(planning, qr.PlanningTimeMs) }
+		source := qr.TimingSource
+		if source == "" { source = string(mcp.TimingClient) }
+		if i == 0 {
+			out.timing = source
+		} else if out.timing != source {
+			out.timing = string(mcp.TimingClient)
+		}
+	}
+	if len(planning) == len(out.samples) && len(planning) > 0 {
+		sort.Float64s(planning)
+		out.planningMS = round3(percentile(planning, 50))
+	}
+	return out, nil
+}
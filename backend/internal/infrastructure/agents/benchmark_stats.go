@@ -0,0 +1,125 @@
+package agents
+
+import (
+	"math"
+	"sort"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+// exactMannWhitneyMax bounds the combined sample size for which the exact U
+// distribution is enumerated; larger or tied samples use the normal approximation.
+const exactMannWhitneyMax = 40
+
+// summarizeSamples computes the descriptive statistics stored with each BenchmarkResult.
+func summarizeSamples(samples []float64, warmup int) (mean float64, st entities.BenchmarkStats) {
+	st = entities.BenchmarkStats{WarmupRuns: warmup, Iterations: len(samples), SamplesMS: append([]float64(nil), samples...)}
+	if len(samples) == 0 { return 0, st }
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	for _, v := range sorted { mean += v }
+	mean /= float64(len(sorted))
+	var sq float64
+	for _, v := range sorted { sq += (v - mean) * (v - mean) }
+	if len(sorted) > 1 { st.StdDevMS = round3(math.Sqrt(sq / float64(len(sorted)-1))) }
+	st.MinMS = sorted[0]
+	st.MaxMS = sorted[len(sorted)-1]
+	st.MedianMS = round3(percentile(sorted, 50))
+	st.P95MS = round3(percentile(sorted, 95))
+	return mean, st
+}
+
+// percentile uses linear interpolation between closest ranks on an already sorted slice.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 { return 0 }
+	if len(sorted) == 1 { return sorted[0] }
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// mannWhitney runs a two-sided Mann-Whitney U test of sample against baseline.
+// Returns nil when either sample has fewer than two observations.
+func mannWhitney(baseline, sample []float64, alpha float64) *entities.Significance {
+	n1, n2 := len(baseline), len(sample)
+	if n1 < 2 || n2 < 2 { return nil }
+
+	type obs struct {
+		v    float64
+		base bool
+	}
+	all := make([]obs, 0, n1+n2)
+	for _, v := range baseline { all = append(all, obs{v, true}) }
+	for _, v := range sample { all = append(all, obs{v, false}) }
+	sort.Slice(all, func(i, j int) bool { return all[i].v < all[j].v })
+
+	// Average ranks for ties, accumulating the tie correction term.
+	var rankSum, tieTerm float64
+	ties := false
+	for i := 0; i < len(all); {
+		j := i
+		for j < len(all) && all[j].v == all[i].v { j++ }
+		avg := float64(i+j+1) / 2 // ranks are 1-based: (i+1 .. j) averaged
+		for k := i; k < j; k++ {
+			if all[k].base { rankSum += avg }
+		}
+		if t := float64(j - i); t > 1 {
+			ties = true
+			tieTerm += t*t*t - t
+		}
+		i = j
+	}
+	u := rankSum - float64(n1*(n1+1))/2
+
+	var p float64
+	method := "mann_whitney_u_exact"
+	if !ties && n1+n2 <= exactMannWhitneyMax {
+		p = exactMannWhitneyP(n1, n2, u)
+	} else {
+		method = "mann_whitney_u_normal"
+		n := float64(n1 + n2)
+		mu := float64(n1*n2) / 2
+		sigma := math.Sqrt(float64(n1*n2) / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+		if sigma == 0 {
+			p = 1
+		} else {
+			z := (math.Abs(u-mu) - 0.5) / sigma // continuity correction
+			if z < 0 { z = 0 }
+			p = math.Erfc(z / math.Sqrt2)
+		}
+	}
+	if p > 1 { p = 1 }
+	return &entities.Significance{Method: method, PValue: round3(p), Alpha: alpha, Significant: p < alpha}
+}
+
+// exactMannWhitneyP returns the two-sided p-value of U under the null hypothesis,
+// enumerating the U distribution for sample sizes n1 and n2.
+func exactMannWhitneyP(n1, n2 int, u float64) float64 {
+	maxU := n1 * n2
+	// counts[a][b][k]: number of arrangements of a and b observations with U == k
+	memo := map[[3]int]float64{}
+	var count func(a, b, k int) float64
+	count = func(a, b, k int) float64 {
+		if k < 0 || k > a*b { return 0 }
+		if a == 0 || b == 0 {
+			if k == 0 { return 1 }
+			return 0
+		}
+		key := [3]int{a, b, k}
+		if v, ok := memo[key]; ok { return v }
+		v := count(a-1, b, k-b) + count(a, b-1, k)
+		memo[key] = v
+		return v
+	}
+	var total, lower, upper float64
+	for k := 0; k <= maxU; k++ {
+		c := count(n1, n2, k)
+		total += c
+		if float64(k) <= u { lower += c }
+		if float64(k) >= u { upper += c }
+	}
+	return 2 * math.Min(lower, upper) / total
+}
+
+func round3(f float64) float64 { return math.Round(f*1000) / 1000 }
@@ -0,0 +1,134 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+func TestRunBenchmark_MeasuresTargetQueryAroundProposal(t *testing.T) {
+	query := "SELECT id FROM orders WHERE status='completed' ORDER BY created_at DESC LIMIT 5;"
+	task := &entities.Task{TargetQuery: query}
+	prop := &entities.OptimizationProposal{ID: 7, SQLCommands: []string{"CREATE INDEX idx_orders_status ON orders(status)"}}
+	for _, ag := range []Agent{&OperativoAgent{}, &CerebroAgent{}, &OperativoCompatAgent{}} {
+		m := &scriptedMCP{}
+		switch a := ag.(type) {
+		case *OperativoAgent: a.MCPQ = m
+		case *CerebroAgent: a.MCPQ = m
+		case *OperativoCompatAgent: a.MCPQ = m
+		}
+		res, err := ag.RunBenchmark(context.Background(), task, prop, "fork-1")
+		if err != nil { t.Fatalf("RunBenchmark err: %v", err) }
+		if len(res) != 3 { t.Fatalf("expected 3 results, got %d", len(res)) }
+		// 1 warmup + 5 measured baseline runs + EXPLAIN, then size snapshot, proposal and size snapshot,
+		// then 7 statements per remaining query
+		if len(m.seen) != 24 { t.Fatalf("expected 24 statements, got %d: %v", len(m.seen), m.seen) }
+		if !strings.HasPrefix(m.seen[6], "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) ") { t.Fatalf("expected EXPLAIN after baseline runs: %s", m.seen[6]) }
+		if m.seen[8] != prop.SQLCommands[0] { t.Fatalf("proposal not applied after baseline: %v", m.seen) }
+		if !strings.Contains(m.seen[7], "pg_total_relation_size") || m.seen[9] != m.seen[7] { t.Fatalf("expected size snapshots around the proposal: %v", m.seen[7:10]) }
+		for i, sql := range m.seen {
+			if i >= 7 && i <= 9 { continue }
+			if strings.Contains(sql, "SELECT 1") || !strings.Contains(sql, "status='completed' ORDER BY created_at DESC LIMIT 5") || strings.Contains(sql, ";") {
+				t.Fatalf("statement %d does not benchmark the target query: %s", i, sql)
+			}
+		}
+		for _, r := range res {
+			if r.ProposalID != prop.ID { t.Fatalf("unexpected proposal id: %d", r.ProposalID) }
+		}
+	}
+}
+
+func TestBenchmarkProposal_Validation(t *testing.T) {
+	m := &scriptedMCP{}
+	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"ANALYZE orders"}}
+	if _, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", "  ; ", DefaultBenchmarkOptions()); err == nil { t.Fatalf("expected error for empty query") }
+	if _, err := BenchmarkProposal(context.Background(), m, &entities.OptimizationProposal{}, "fork-1", "SELECT 1", DefaultBenchmarkOptions()); err == nil { t.Fatalf("expected error for proposal without ID") }
+	if _, err := BenchmarkProposal(context.Background(), m, prop, "", "SELECT 1", DefaultBenchmarkOptions()); err == nil { t.Fatalf("expected error for empty fork") }
+	if len(m.seen) != 0 { t.Fatalf("no statements expected on invalid input, got %v", m.seen) }
+}
+
+// timedMCP returns the timings of the slice in order, one per ExecuteQuery call.
+type timedMCP struct {
+	calls int
+	times []float64
+}
+
+func (m *timedMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	val := 1.0
+	if m.calls < len(m.times) { val = m.times[m.calls] }
+	m.calls++
+	return mcp.QueryResult{ExecutionTimeMs: val}, nil
+}
+
+func TestBenchmarkProposal_StatsAndSignificance(t *testing.T) {
+	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX idx ON orders(status)"}}
+	opts := BenchmarkOptions{WarmupRuns: 1, MeasuredRuns: 5, Alpha: 0.05}
+	times := []float64{
+		500, 100, 104, 98, 102, 150, 0, // baseline: warmup 500 discarded, then EXPLAIN
+		0, 0, 0,                        // size snapshot, apply, size snapshot
+		90, 10, 11, 12, 9, 10, 0,       // test_limit: clearly faster
+		90, 99, 101, 103, 97, 100, 0,   // test_sort: indistinguishable from baseline
+	}
+	res, err := BenchmarkProposal(context.Background(), &timedMCP{times: times}, prop, "fork-1", "SELECT * FROM orders", opts)
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	base := res[0].Stats
+	if base.Iterations != 5 || base.WarmupRuns != 1 || base.MinMS != 98 || base.MaxMS != 150 || base.MedianMS != 102 {
+		t.Fatalf("unexpected baseline stats: %+v", base)
+	}
+	if base.P95MS <= 104 || base.P95MS > 150 || base.StdDevMS <= 0 || base.Significance != nil {
+		t.Fatalf("unexpected baseline spread: %+v", base)
+	}
+	if res[0].ExecutionTimeMS != 110.8 { t.Fatalf("expected mean of measured runs, got %v", res[0].ExecutionTimeMS) }
+	if s := res[1].Stats.Significance; s == nil || !s.Significant || s.PValue >= 0.05 {
+		t.Fatalf("expected significant improvement for test_limit, got %+v", s)
+	}
+	if s := res[2].Stats.Significance; s == nil || s.Significant {
+		t.Fatalf("expected no significant difference for test_sort, got %+v", s)
+	}
+}
+
+func TestMannWhitney(t *testing.T) {
+	// Completely separated samples of 5: exact two-sided p = 2/252
+	s := mannWhitney([]float64{10, 11, 12, 13, 14}, []float64{1, 2, 3, 4, 5}, 0.05)
+	if s == nil || s.Method != "mann_whitney_u_exact" || s.PValue != 0.008 || !s.Significant { t.Fatalf("unexpected result: %+v", s) }
+	// Ties fall back to the normal approximation
+	s = mannWhitney([]float64{5, 5, 5, 5}, []float64{5, 5, 5, 5}, 0.05)
+	if s == nil || s.Method != "mann_whitney_u_normal" || s.Significant { t.Fatalf("unexpected tied result: %+v", s) }
+	if mannWhitney([]float64{1}, []float64{2, 3}, 0.05) != nil { t.Fatalf("expected nil for tiny samples") }
+}
+
+// serverTimedMCP reports server-side timings when asked for EXPLAIN timing.
+type serverTimedMCP struct{ modes []mcp.TimingMode }
+
+func (m *serverTimedMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	mode := mcp.TimingModeFrom(ctx)
+	m.modes = append(m.modes, mode)
+	if mode == mcp.TimingExplain {
+		return mcp.QueryResult{ExecutionTimeMs: 4, PlanningTimeMs: 0.5, RowCount: 7, TimingSource: string(mcp.TimingExplain)}, nil
+	}
+	return mcp.QueryResult{ExecutionTimeMs: 40}, nil
+}
+
+func TestBenchmarkProposal_ServerSideTiming(t *testing.T) {
+	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX idx ON orders(status)"}}
+	m := &serverTimedMCP{}
+	opts := DefaultBenchmarkOptions()
+	opts.Timing = mcp.TimingExplain
+	res, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", "SELECT * FROM orders", opts)
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	base := res[0]
+	if base.Stats.Timing != "explain" || base.Stats.PlanningMS != 0.5 || base.Stats.MedianMS != 4 || base.RowsReturned != 7 {
+		t.Fatalf("expected server-side samples, got %+v rows=%d", base.Stats, base.RowsReturned)
+	}
+	explained := 0
+	for _, mode := range m.modes { if mode == mcp.TimingExplain { explained++ } }
+	// 3 queries x (1 warmup + 5 measured); plan capture, storage snapshots and apply stay client-timed.
+	if explained != 18 { t.Errorf("expected only sample runs to request EXPLAIN timing, got %d of %d", explained, len(m.modes)) }
+
+	res, err = BenchmarkProposal(context.Background(), &serverTimedMCP{}, prop, "fork-1", "SELECT * FROM orders", DefaultBenchmarkOptions())
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	if res[0].Stats.Timing != "client" || res[0].Stats.MedianMS != 40 { t.Errorf("expected client timing by default, got %+v", res[0].Stats) }
+}
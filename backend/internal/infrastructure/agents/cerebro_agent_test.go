@@ -10,8 +10,8 @@ import (
 )
 
 type mockLLM_G struct { jsonResp map[string]interface{} }
-func (m *mockLLM_G) SendMessage(prompt, system string) (string, error) { return "", nil }
-func (m *mockLLM_G) SendMessageWithJSON(prompt, system string) (map[string]interface{}, error) {
+func (m *mockLLM_G) SendMessage(ctx context.Context, prompt, system string) (string, error) { return "", nil }
+func (m *mockLLM_G) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) {
 	b, _ := json.Marshal(m.jsonResp)
 	var out map[string]interface{}
 	_ = json.Unmarshal(b, &out)
@@ -46,11 +46,13 @@ func TestAgentCerebro(t *testing.T) {
 		"sql_commands": []interface{}{"CREATE MATERIALIZED VIEW mv AS SELECT * FROM orders;"},
 		"rationale": "precompute",
 	}
-	prop, err := ag.ProposeOptimization(context.Background(), ar, "fork-1")
+	props, err := ag.ProposeOptimization(context.Background(), ar, "fork-1")
 	if err != nil { t.Fatalf("ProposeOptimization err: %v", err) }
+	if len(props) != 1 { t.Fatalf("expected 1 candidate, got %d", len(props)) }
+	prop := props[0]
 	prop.ID = 1
 
-	res, err := ag.RunBenchmark(context.Background(), prop, "fork-1")
+	res, err := ag.RunBenchmark(context.Background(), task, prop, "fork-1")
 	if err != nil { t.Fatalf("RunBenchmark err: %v", err) }
-	if len(res) != 4 { t.Fatalf("expected 4 results, got %d", len(res)) }
+	if len(res) != 3 { t.Fatalf("expected 3 results, got %d", len(res)) }
 }
@@ -0,0 +1,60 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+// rewrittenQuery returns the last SELECT/WITH statement of the proposal, which is the rewrite to run
+// in place of the original; preceding statements (e.g. supporting indexes) are applied as usual.
+func rewrittenQuery(proposal *entities.OptimizationProposal) string {
+	if proposal == nil { return "" }
+	for i := len(proposal.SQLCommands) - 1; i >= 0; i-- {
+		stmt := strings.TrimRight(strings.TrimSpace(proposal.SQLCommands[i]), "; \n\t")
+		upper := strings.ToUpper(stmt)
+		if strings.HasPrefix(upper, "SELECT") || strings.HasPrefix(upper, "WITH") { return stmt }
+	}
+	return ""
+}
+
+// resultDigest returns the row count and an order-insensitive checksum of the query's result set:
+// each row is hashed, the hashes are sorted and hashed again.
+func resultDigest(ctx context.Context, q mcpQueryPort, forkID, query string) (int64, string, error) {
+	digestSQL := fmt.Sprintf(`SELECT count(*)::bigint AS row_count, md5(COALESCE(string_agg(h, '' ORDER BY h), '')) AS checksum
+		FROM (SELECT md5(t::text) AS h FROM (%s) AS t) AS hashed`, query)
+	res, err := q.ExecuteQuery(ctx, forkID, digestSQL, benchmarkTimeoutMs)
+	if err != nil { return 0, "", err }
+	if len(res.Rows) == 0 { return 0, "", fmt.Errorf("no digest returned") }
+	return int64(rowFloat(res.Rows[0], "row_count")), rowString(res.Rows[0], "checksum"), nil
+}
+
+// VerifyEquivalence runs the original and rewritten queries on the fork and compares row counts
+// and checksums. Execution failures are reported as non-equivalent with the error as reason.
+func VerifyEquivalence(ctx context.Context, q mcpQueryPort, forkID, original, rewritten string) *entities.ResultEquivalence {
+	eq := &entities.ResultEquivalence{}
+	if strings.TrimSpace(rewritten) == "" {
+		eq.Reason = "no rewritten SELECT found in sql_commands"
+		return eq
+	}
+	var err error
+	if eq.OriginalRows, eq.OriginalChecksum, err = resultDigest(ctx, q, forkID, original); err != nil {
+		eq.Reason = fmt.Sprintf("original query failed: %v", err)
+		return eq
+	}
+	if eq.RewrittenRows, eq.RewrittenChecksum, err = resultDigest(ctx, q, forkID, rewritten); err != nil {
+		eq.Reason = fmt.Sprintf("rewritten query failed: %v", err)
+		return eq
+	}
+	switch {
+	case eq.OriginalRows != eq.RewrittenRows:
+		eq.Reason = fmt.Sprintf("row count mismatch: original %d, rewritten %d", eq.OriginalRows, eq.RewrittenRows)
+	case eq.OriginalChecksum != eq.RewrittenChecksum:
+		eq.Reason = "result checksum mismatch"
+	default:
+		eq.Equivalent = true
+	}
+	return eq
+}
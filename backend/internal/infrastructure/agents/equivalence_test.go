@@ -0,0 +1,78 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// digestMCP answers result digests per query fragment and records every statement.
+type digestMCP struct {
+	digests map[string]map[string]any
+	seen    []string
+}
+
+func (m *digestMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	m.seen = append(m.seen, sql)
+	if strings.Contains(sql, "string_agg(h") {
+		for frag, row := range m.digests {
+			if strings.Contains(sql, frag) { return mcp.QueryResult{Rows: []map[string]any{row}, RowCount: 1}, nil }
+		}
+	}
+	return mcp.QueryResult{ExecutionTimeMs: 2}, nil
+}
+
+func rewriteProposal() *entities.OptimizationProposal {
+	return &entities.OptimizationProposal{ID: 3, ProposalType: values.ProposalQueryRewrite, SQLCommands: []string{
+		"CREATE INDEX idx_orders_user ON orders(user_id)",
+		"SELECT o.id FROM orders o WHERE EXISTS (SELECT 1 FROM users u WHERE u.id = o.user_id);",
+	}}
+}
+
+const originalQuery = "SELECT id FROM orders WHERE user_id IN (SELECT id FROM users)"
+
+func TestBenchmarkProposal_EquivalentRewriteIsMeasured(t *testing.T) {
+	m := &digestMCP{digests: map[string]map[string]any{
+		"FROM (SELECT id FROM orders WHERE user_id IN": {"row_count": int64(42), "checksum": []byte("abc")},
+		"FROM (SELECT o.id FROM orders o WHERE EXISTS": {"row_count": int64(42), "checksum": []byte("abc")},
+	}}
+	prop := rewriteProposal()
+	res, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", originalQuery, DefaultBenchmarkOptions())
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	eq := prop.EstimatedImpact.Equivalence
+	if eq == nil || !eq.Equivalent || eq.OriginalRows != 42 { t.Fatalf("expected equivalent rewrite, got %+v", eq) }
+	if len(res) != 3 || res[0].QueryExecuted != originalQuery { t.Fatalf("unexpected results: %d", len(res)) }
+	for _, r := range res[1:] {
+		if !strings.Contains(r.QueryExecuted, "WHERE EXISTS") { t.Fatalf("post-apply result should time the rewrite: %s", r.QueryExecuted) }
+	}
+	for _, sql := range m.seen {
+		if sql == prop.SQLCommands[1] { t.Fatalf("rewritten SELECT must not be executed as an apply statement") }
+	}
+}
+
+func TestBenchmarkProposal_MismatchedRewriteStopsAfterBaseline(t *testing.T) {
+	m := &digestMCP{digests: map[string]map[string]any{
+		"FROM (SELECT id FROM orders WHERE user_id IN": {"row_count": int64(42), "checksum": []byte("abc")},
+		"FROM (SELECT o.id FROM orders o WHERE EXISTS": {"row_count": int64(42), "checksum": []byte("def")},
+	}}
+	prop := rewriteProposal()
+	res, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", originalQuery, DefaultBenchmarkOptions())
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	eq := prop.EstimatedImpact.Equivalence
+	if eq == nil || eq.Equivalent || eq.Reason != "result checksum mismatch" { t.Fatalf("expected checksum mismatch, got %+v", eq) }
+	if len(res) != 1 || res[0].QueryName != entities.QueryNameBaseline { t.Fatalf("expected baseline only, got %d results", len(res)) }
+}
+
+func TestVerifyEquivalence_RowCountAndMissingRewrite(t *testing.T) {
+	m := &digestMCP{digests: map[string]map[string]any{
+		"FROM (SELECT 1) AS t": {"row_count": int64(1), "checksum": "x"},
+		"FROM (SELECT 1 UNION ALL SELECT 1) AS t": {"row_count": int64(2), "checksum": "x"},
+	}}
+	eq := VerifyEquivalence(context.Background(), m, "fork-1", "SELECT 1", "SELECT 1 UNION ALL SELECT 1")
+	if eq.Equivalent || !strings.HasPrefix(eq.Reason, "row count mismatch") { t.Fatalf("expected row count mismatch, got %+v", eq) }
+	if eq := VerifyEquivalence(context.Background(), m, "fork-1", "SELECT 1", ""); eq.Equivalent || eq.Reason == "" { t.Fatalf("expected missing rewrite to fail, got %+v", eq) }
+}
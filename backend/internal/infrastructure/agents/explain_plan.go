@@ -0,0 +1,68 @@
+package agents
+
+import (
+	"strings"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+// planTypeUnknown is recorded when no plan could be captured (e.g. the transport returned no rows).
+const planTypeUnknown = "Unknown"
+
+// isIndexNode reports whether the node reads through an index.
+func isIndexNode(n *PlanNode) bool {
+	switch n.NodeType {
+	case "Index Scan", "Index Only Scan", "Bitmap Index Scan":
+		return true
+	}
+	return false
+}
+
+// ToExplainPlan summarizes the plan tree into the stored entities.ExplainPlan.
+//   - PlanType is the first index access node if any index was used, otherwise the first scan node,
+//     otherwise the root node type, so it answers "did the query use an index?".
+//   - IndexName lists every distinct index used, in plan order.
+//   - FilterRemovedRows sums Rows Removed by Filter across nodes, weighted by loops.
+//   - Buffers come from the root node, which PostgreSQL reports inclusive of its children.
+func (e *ExplainOutput) ToExplainPlan() entities.ExplainPlan {
+	if e == nil { return entities.ExplainPlan{PlanType: planTypeUnknown} }
+	ep := entities.ExplainPlan{
+		PlanningTimeMS:  e.PlanningTime,
+		ExecutionTimeMS: e.ExecutionTime,
+		TotalCost:       e.Plan.TotalCost,
+		ActualRows:      int64(e.Plan.ActualRows),
+		Buffers:         entities.Buffers{SharedHit: e.Plan.SharedHitBlocks, SharedRead: e.Plan.SharedReadBlocks},
+		FullPlan:        e.Raw,
+	}
+	var firstIndex, firstScan string
+	indexes := []string{}
+	seen := map[string]bool{}
+	var removed float64
+	e.Walk(func(n *PlanNode, _ int) {
+		loops := n.ActualLoops
+		if loops <= 0 { loops = 1 }
+		removed += n.RowsRemovedByFilter * loops
+		if isIndexNode(n) {
+			if firstIndex == "" { firstIndex = n.NodeType }
+			if n.IndexName != "" && !seen[n.IndexName] {
+				seen[n.IndexName] = true
+				indexes = append(indexes, n.IndexName)
+			}
+		}
+		if firstScan == "" && strings.HasSuffix(n.NodeType, "Scan") { firstScan = n.NodeType }
+		if ep.SortMethod == "" && n.SortMethod != "" { ep.SortMethod = n.SortMethod }
+	})
+	ep.FilterRemovedRows = int64(removed)
+	ep.IndexName = strings.Join(indexes, ", ")
+	switch {
+	case firstIndex != "":
+		ep.PlanType = firstIndex
+	case firstScan != "":
+		ep.PlanType = firstScan
+	case e.Plan.NodeType != "":
+		ep.PlanType = e.Plan.NodeType
+	default:
+		ep.PlanType = planTypeUnknown
+	}
+	return ep
+}
@@ -0,0 +1,49 @@
+package agents
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+const indexPlan = `[{"Plan": {"Node Type": "Limit", "Total Cost": 8.5, "Plan Rows": 10, "Actual Rows": 10, "Actual Loops": 1,
+ "Shared Hit Blocks": 12, "Shared Read Blocks": 3,
+ "Plans": [{"Node Type": "Nested Loop", "Join Type": "Inner", "Total Cost": 8.4, "Actual Rows": 10, "Actual Loops": 1,
+  "Plans": [
+   {"Node Type": "Index Scan", "Relation Name": "orders", "Index Name": "idx_orders_status", "Total Cost": 4.2, "Actual Rows": 10, "Actual Loops": 1,
+    "Index Cond": "(status = 'completed'::text)", "Filter": "(total > 10)", "Rows Removed by Filter": 4},
+   {"Node Type": "Index Only Scan", "Relation Name": "users", "Index Name": "users_pkey", "Total Cost": 0.3, "Actual Rows": 1, "Actual Loops": 10,
+    "Filter": "(active)", "Rows Removed by Filter": 1}]}]},
+ "Planning Time": 0.12, "Execution Time": 0.9}]`
+
+func TestToExplainPlan(t *testing.T) {
+	out, err := ParseExplainJSON([]byte(indexPlan))
+	if err != nil { t.Fatalf("parse err: %v", err) }
+	ep := out.ToExplainPlan()
+	if ep.PlanType != "Index Scan" || ep.IndexName != "idx_orders_status, users_pkey" { t.Fatalf("unexpected access path: %+v", ep) }
+	if ep.PlanningTimeMS != 0.12 || ep.ExecutionTimeMS != 0.9 || ep.TotalCost != 8.5 || ep.ActualRows != 10 { t.Fatalf("unexpected totals: %+v", ep) }
+	// 4 removed once + 1 removed on each of 10 loops
+	if ep.FilterRemovedRows != 14 { t.Fatalf("unexpected removed rows: %d", ep.FilterRemovedRows) }
+	if ep.Buffers.SharedHit != 12 || ep.Buffers.SharedRead != 3 { t.Fatalf("unexpected buffers: %+v", ep.Buffers) }
+	if ep.FullPlan != indexPlan { t.Fatalf("full plan not preserved") }
+
+	seq, _ := ParseExplainJSON([]byte(samplePlan))
+	sp := seq.ToExplainPlan()
+	if sp.PlanType != "Seq Scan" || sp.IndexName != "" || sp.SortMethod != "quicksort" || sp.FilterRemovedRows != 952 { t.Fatalf("unexpected seq plan: %+v", sp) }
+
+	var none *ExplainOutput
+	if none.ToExplainPlan().PlanType != planTypeUnknown { t.Fatalf("expected unknown plan type for missing plan") }
+}
+
+func TestBenchmarkProposal_StoresExecutedPlan(t *testing.T) {
+	m := &scriptedMCP{answers: map[string][]map[string]any{
+		"EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) SELECT * FROM (": {{"QUERY PLAN": []byte(indexPlan)}},
+		"EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) SELECT * FROM orders": {{"QUERY PLAN": []byte(samplePlan)}},
+	}}
+	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX idx_orders_status ON orders(status)"}}
+	res, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", "SELECT * FROM orders WHERE status='completed'", DefaultBenchmarkOptions())
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	if res[0].ExplainPlan.PlanType != "Seq Scan" || res[0].ExplainPlan.FullPlan != samplePlan { t.Fatalf("baseline plan not stored: %+v", res[0].ExplainPlan) }
+	if res[1].ExplainPlan.IndexName != "idx_orders_status, users_pkey" { t.Fatalf("optimized plan not stored: %+v", res[1].ExplainPlan) }
+}
@@ -13,32 +13,50 @@ import (
 
 // Agent is the common contract that all concrete agents implement.
 // It matches the Analyze/Propose/Benchmark trio used throughout the system.
+// ProposeOptimization returns the agent's ranked candidates, best first; each one is benchmarked.
 type Agent interface {
 	AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (AnalysisResult, error)
-	ProposeOptimization(ctx context.Context, analysis AnalysisResult, forkID string) (*entities.OptimizationProposal, error)
-	RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error)
+	ProposeOptimization(ctx context.Context, analysis AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error)
+	RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error)
 }
 
-// NewAgent constructs an agent instance for the given type, wiring dependencies.
-// Supported types:
+// UsageReporter is implemented by agents that can tell how many LLM tokens they used so far.
+type UsageReporter interface {
+	TokenUsage() (inputTokens, outputTokens int)
+}
+
+// TokenUsage returns the LLM tokens the agent used so far; zero when it does not report them.
+func TokenUsage(a Agent) (inputTokens, outputTokens int) {
+	if u, ok := a.(UsageReporter); ok {
+		return u.TokenUsage()
+	}
+	return 0, 0
+}
+
+func llmUsage(c llm.LLMClient) (int, int) {
+	if c == nil {
+		return 0, 0
+	}
+	return c.GetUsage()
+}
+
+// NewAgent constructs an agent instance for the given type from its registered Kind,
+// wiring dependencies and the kind's prompt profile.
+// Built-in kinds (see registry.go):
 // - AgentCerebro (gemini-2.5-pro): Planner/QA
 // - AgentOperativo (gemini-2.5-flash): Generación/Ejecución
 // - AgentBulk (gemini-2.0-flash): Bajo costo/masivas
-func NewAgent(agentType values.AgentType, mcpClient *mcp.MCPClient, llmClient llm.LLMClient, cfg *cfgpkg.Config) (Agent, error) {
+func NewAgent(agentType values.AgentType, mcpClient mcp.ForkProvider, llmClient llm.LLMClient, cfg *cfgpkg.Config) (Agent, error) {
+	kind, ok := LookupKind(agentType)
+	if !ok {
+		return nil, fmt.Errorf("unknown agent type: %s (supported: %s)", agentType, supportedKinds())
+	}
 	base := &BaseAgent{
 		MCP:       mcpClient,
 		LLM:       llmClient,
 		Cfg:       cfg,
 		AgentType: agentType,
+		Prompts:   kind.Prompts,
 	}
-	switch agentType {
-	case values.AgentCerebro:
-		return &CerebroAgent{Base: base, MCPQ: mcpClient, LLM: llmClient}, nil
-	case values.AgentOperativo:
-		return &OperativoAgent{Base: base, MCPQ: mcpClient, LLM: llmClient}, nil
-	case values.AgentBulk:
-		return &CerebroAgent{Base: base, MCPQ: mcpClient, LLM: llmClient}, nil
-	default:
-		return nil, fmt.Errorf("unknown agent type: %s (supported: cerebro, operativo, bulk)", agentType)
-	}
+	return kind.New(base, mcpClient, llmClient), nil
 }
@@ -1,6 +1,7 @@
 package agents
 
 import (
+	"context"
 	"testing"
 
 	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
@@ -9,8 +10,8 @@ import (
 )
 
 type dummyLLM struct{}
-func (d *dummyLLM) SendMessage(prompt, system string) (string, error) { return "", nil }
-func (d *dummyLLM) SendMessageWithJSON(prompt, system string) (map[string]interface{}, error) { return map[string]interface{}{}, nil }
+func (d *dummyLLM) SendMessage(ctx context.Context, prompt, system string) (string, error) { return "", nil }
+func (d *dummyLLM) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) { return map[string]interface{}{}, nil }
 func (d *dummyLLM) GetUsage() (int, int) { return 0, 0 }
 
 func TestFactory(t *testing.T) {
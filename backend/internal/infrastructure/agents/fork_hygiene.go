@@ -0,0 +1,199 @@
+package agents
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SchemaObject is one user-schema catalog entry: a relation, a column or a constraint.
+type SchemaObject struct {
+	Kind string // table, partitioned_table, index, materialized_view, view, sequence, column, constraint
+	Name string // schema-qualified; columns are schema.table.column
+	Def  string // definition text that changes when the object does
+}
+
+// SchemaSnapshot is the fingerprinted user schema of a fork at one point in time.
+type SchemaSnapshot struct {
+	Fingerprint string
+	Objects     []SchemaObject
+}
+
+const schemaCatalogSQL = `SELECT kind, name, def FROM (
+	SELECT CASE c.relkind WHEN 'r' THEN 'table' WHEN 'p' THEN 'partitioned_table' WHEN 'i' THEN 'index' WHEN 'I' THEN 'index'
+			WHEN 'm' THEN 'materialized_view' WHEN 'v' THEN 'view' ELSE 'sequence' END AS kind,
+		quote_ident(n.nspname) || '.' || quote_ident(c.relname) AS name,
+		CASE WHEN c.relkind IN ('i','I') THEN pg_get_indexdef(c.oid)
+			WHEN c.relkind IN ('m','v') THEN pg_get_viewdef(c.oid)
+			ELSE COALESCE(pg_get_expr(c.relpartbound, c.oid), '') END AS def
+	FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE n.nspname NOT IN ('pg_catalog','information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+		AND c.relkind IN ('r','p','i','I','m','v','S')
+	UNION ALL
+	SELECT 'column', quote_ident(table_schema) || '.' || quote_ident(table_name) || '.' || quote_ident(column_name),
+		data_type || ' ' || is_nullable || ' ' || COALESCE(column_default, '')
+	FROM information_schema.columns
+	WHERE table_schema NOT IN ('pg_catalog','information_schema')
+	UNION ALL
+	SELECT 'constraint', quote_ident(n.nspname) || '.' || quote_ident(con.conname), pg_get_constraintdef(con.oid)
+	FROM pg_constraint con JOIN pg_namespace n ON n.oid = con.connamespace
+	WHERE n.nspname NOT IN ('pg_catalog','information_schema')
+) s ORDER BY kind, name`
+
+// TakeSchemaSnapshot reads the user schema of the fork and fingerprints it.
+// Only the schema is covered; row data changed by a proposal is not detected.
+func TakeSchemaSnapshot(ctx context.Context, q mcpQueryPort, forkID string) (*SchemaSnapshot, error) {
+	if q == nil { return nil, fmt.Errorf("schema snapshot: mcp client not initialized") }
+	res, err := q.ExecuteQuery(ctx, forkID, schemaCatalogSQL, catalogTimeoutMs)
+	if err != nil { return nil, fmt.Errorf("schema snapshot of %s: %w", forkID, err) }
+	snap := &SchemaSnapshot{Objects: make([]SchemaObject, 0, len(res.Rows))}
+	h := sha256.New()
+	for _, row := range res.Rows {
+		o := SchemaObject{Kind: rowString(row, "kind"), Name: rowString(row, "name"), Def: rowString(row, "def")}
+		snap.Objects = append(snap.Objects, o)
+		fmt.Fprintf(h, "%s|%s|%s\n", o.Kind, o.Name, o.Def)
+	}
+	snap.Fingerprint = hex.EncodeToString(h.Sum(nil))
+	return snap, nil
+}
+
+// ddlCompensations map statements a proposal may apply to the statement that undoes them.
+var ddlCompensations = []struct {
+	re   *regexp.Regexp
+	undo func(m []string) string
+}{
+	{regexp.MustCompile(`(?i)^\s*CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)\s+ON\b`),
+		func(m []string) string { return "DROP INDEX IF EXISTS " + m[1] }},
+	{regexp.MustCompile(`(?i)^\s*CREATE\s+MATERIALIZED\s+VIEW\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`),
+		func(m []string) string { return "DROP MATERIALIZED VIEW IF EXISTS " + m[1] }},
+	{regexp.MustCompile(`(?i)^\s*CREATE\s+(?:OR\s+REPLACE\s+)?VIEW\s+([\w."]+)`),
+		func(m []string) string { return "DROP VIEW IF EXISTS " + m[1] }},
+	{regexp.MustCompile(`(?i)^\s*CREATE\s+(?:UNLOGGED\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`),
+		func(m []string) string { return "DROP TABLE IF EXISTS " + m[1] + " CASCADE" }},
+	{regexp.MustCompile(`(?i)^\s*ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)\s+ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w"]+)`),
+		func(m []string) string {
+			if strings.EqualFold(m[2], "CONSTRAINT") || strings.EqualFold(m[2], "PRIMARY") || strings.EqualFold(m[2], "UNIQUE") { return "" }
+			return "ALTER TABLE IF EXISTS " + m[1] + " DROP COLUMN IF EXISTS " + m[2]
+		}},
+}
+
+// CompensatingStatements returns the statements undoing the recorded DDL, newest first.
+// Objects present in before are never dropped, so a CREATE ... IF NOT EXISTS or
+// CREATE OR REPLACE that hit an existing object leaves it alone.
+// Statements without a known inverse are skipped; the fingerprint check reports what they left behind.
+func CompensatingStatements(applied []string, before *SchemaSnapshot) []string {
+	existing := map[string]bool{}
+	if before != nil {
+		for _, o := range before.Objects {
+			if parts := strings.Split(o.Name, "."); o.Kind == "column" && len(parts) == 3 {
+				existing[bareName(parts[1])+"."+bareName(parts[2])] = true
+			} else {
+				existing[bareName(o.Name)] = true
+			}
+		}
+	}
+	var out []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		for _, c := range ddlCompensations {
+			m := c.re.FindStringSubmatch(applied[i])
+			if m == nil { continue }
+			target := bareName(m[1])
+			if len(m) > 2 { target += "." + bareName(m[2]) }
+			if existing[target] { break }
+			if undo := c.undo(m); undo != "" { out = append(out, undo) }
+			break
+		}
+	}
+	return out
+}
+
+// RestoreFork rolls the fork back to before: it runs the compensations for the applied
+// statements, drops relations and columns that appeared since the snapshot (unnamed
+// indexes, statements without a known inverse), then verifies the schema fingerprint.
+// Individual drop failures are tolerated; the fingerprint decides whether the fork is clean.
+func RestoreFork(ctx context.Context, q mcpQueryPort, forkID string, before *SchemaSnapshot, applied []string) error {
+	if before == nil { return fmt.Errorf("restore %s: no pre-task schema snapshot", forkID) }
+	for _, stmt := range CompensatingStatements(applied, before) {
+		_, _ = q.ExecuteQuery(ctx, forkID, stmt, applyTimeoutMs)
+	}
+	now, err := TakeSchemaSnapshot(ctx, q, forkID)
+	if err != nil { return err }
+	if now.Fingerprint == before.Fingerprint { return nil }
+	for _, stmt := range sweepStatements(before, now) {
+		_, _ = q.ExecuteQuery(ctx, forkID, stmt, applyTimeoutMs)
+	}
+	after, err := TakeSchemaSnapshot(ctx, q, forkID)
+	if err != nil { return err }
+	if after.Fingerprint != before.Fingerprint {
+		return fmt.Errorf("fork %s schema differs from its pre-task fingerprint: %s", forkID, describeDrift(before, after))
+	}
+	return nil
+}
+
+// sweepStatements drops what exists in now but not in before: views first, then indexes,
+// tables and sequences, then new columns of pre-existing tables.
+func sweepStatements(before, now *SchemaSnapshot) []string {
+	had := map[string]bool{}
+	for _, o := range before.Objects { had[o.Kind+" "+o.Name] = true }
+	order := map[string]int{"materialized_view": 0, "view": 1, "index": 2, "table": 3, "partitioned_table": 3, "sequence": 4, "column": 5}
+	var added []SchemaObject
+	for _, o := range now.Objects {
+		if _, ok := order[o.Kind]; ok && !had[o.Kind+" "+o.Name] { added = append(added, o) }
+	}
+	sort.SliceStable(added, func(i, j int) bool { return order[added[i].Kind] < order[added[j].Kind] })
+	out := make([]string, 0, len(added))
+	for _, o := range added {
+		switch o.Kind {
+		case "materialized_view":
+			out = append(out, "DROP MATERIALIZED VIEW IF EXISTS "+o.Name+" CASCADE")
+		case "view":
+			out = append(out, "DROP VIEW IF EXISTS "+o.Name+" CASCADE")
+		case "index":
+			out = append(out, "DROP INDEX IF EXISTS "+o.Name)
+		case "table", "partitioned_table":
+			out = append(out, "DROP TABLE IF EXISTS "+o.Name+" CASCADE")
+		case "sequence":
+			out = append(out, "DROP SEQUENCE IF EXISTS "+o.Name)
+		case "column":
+			if k := strings.LastIndex(o.Name, "."); k > 0 {
+				out = append(out, "ALTER TABLE IF EXISTS "+o.Name[:k]+" DROP COLUMN IF EXISTS "+o.Name[k+1:])
+			}
+		}
+	}
+	return out
+}
+
+// describeDrift lists a few added, removed and changed objects between two snapshots.
+func describeDrift(before, after *SchemaSnapshot) string {
+	const max = 5
+	defs := map[string]string{}
+	for _, o := range before.Objects { defs[o.Kind+" "+o.Name] = o.Def }
+	var parts []string
+	seen := map[string]bool{}
+	for _, o := range after.Objects {
+		k := o.Kind + " " + o.Name
+		seen[k] = true
+		if d, ok := defs[k]; !ok {
+			parts = append(parts, "added "+k)
+		} else if d != o.Def {
+			parts = append(parts, "changed "+k)
+		}
+	}
+	for _, o := range before.Objects {
+		if k := o.Kind + " " + o.Name; !seen[k] { parts = append(parts, "removed "+k) }
+	}
+	if len(parts) > max { parts = append(parts[:max], fmt.Sprintf("and %d more", len(parts)-max)) }
+	return strings.Join(parts, ", ")
+}
+
+// bareName strips schema qualification and quotes, lowercasing unquoted identifiers.
+func bareName(name string) string {
+	name = strings.TrimSpace(name)
+	if k := strings.LastIndex(name, "."); k >= 0 { name = name[k+1:] }
+	if strings.HasPrefix(name, `"`) && strings.HasSuffix(name, `"`) && len(name) > 1 { return name[1 : len(name)-1] }
+	return strings.ToLower(name)
+}
@@ -0,0 +1,112 @@
+package agents
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// catalogMCP keeps a fake user schema: CREATE/DROP of indexes and ADD/DROP COLUMN change it,
+// and the catalog query returns it.
+type catalogMCP struct {
+	objects map[string]SchemaObject // kind+" "+name
+	locked  map[string]bool         // objects that refuse to be dropped
+	seen    []string
+}
+
+var (
+	fakeCreateIndex = regexp.MustCompile(`(?i)^CREATE INDEX (\w+ )?ON (\w+)\((\w+)\)`)
+	fakeDropIndex   = regexp.MustCompile(`(?i)^DROP INDEX IF EXISTS ([\w.]+)`)
+	fakeAddColumn   = regexp.MustCompile(`(?i)^ALTER TABLE (\w+) ADD COLUMN (\w+)`)
+	fakeDropColumn  = regexp.MustCompile(`(?i)^ALTER TABLE IF EXISTS ([\w.]+) DROP COLUMN IF EXISTS (\w+)`)
+)
+
+func newCatalogMCP() *catalogMCP {
+	m := &catalogMCP{objects: map[string]SchemaObject{}, locked: map[string]bool{}}
+	m.put(SchemaObject{Kind: "table", Name: "public.orders"})
+	m.put(SchemaObject{Kind: "column", Name: "public.orders.id", Def: "integer NO "})
+	m.put(SchemaObject{Kind: "index", Name: "public.orders_pkey", Def: "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)"})
+	return m
+}
+
+func (m *catalogMCP) put(o SchemaObject) { m.objects[o.Kind+" "+o.Name] = o }
+
+func (m *catalogMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	m.seen = append(m.seen, sql)
+	if sql == schemaCatalogSQL {
+		rows := []map[string]any{}
+		for _, o := range m.objects { rows = append(rows, map[string]any{"kind": o.Kind, "name": o.Name, "def": o.Def}) }
+		// ORDER BY kind, name
+		for i := 1; i < len(rows); i++ {
+			for j := i; j > 0 && rows[j]["kind"].(string)+rows[j]["name"].(string) < rows[j-1]["kind"].(string)+rows[j-1]["name"].(string); j-- {
+				rows[j], rows[j-1] = rows[j-1], rows[j]
+			}
+		}
+		return mcp.QueryResult{Rows: rows, RowCount: len(rows)}, nil
+	}
+	if mm := fakeCreateIndex.FindStringSubmatch(sql); mm != nil {
+		name := strings.TrimSpace(mm[1])
+		if name == "" { name = mm[2] + "_" + mm[3] + "_idx" }
+		m.put(SchemaObject{Kind: "index", Name: "public." + name, Def: sql})
+	} else if mm := fakeDropIndex.FindStringSubmatch(sql); mm != nil {
+		name := mm[1]
+		if !strings.Contains(name, ".") { name = "public." + name }
+		if !m.locked["index "+name] { delete(m.objects, "index "+name) }
+	} else if mm := fakeAddColumn.FindStringSubmatch(sql); mm != nil {
+		m.put(SchemaObject{Kind: "column", Name: "public." + mm[1] + "." + mm[2], Def: "text YES "})
+	} else if mm := fakeDropColumn.FindStringSubmatch(sql); mm != nil {
+		table := mm[1]
+		if !strings.Contains(table, ".") { table = "public." + table }
+		delete(m.objects, "column "+table+"."+mm[2])
+	}
+	return mcp.QueryResult{}, nil
+}
+
+func TestCompensatingStatements(t *testing.T) {
+	before := &SchemaSnapshot{Objects: []SchemaObject{
+		{Kind: "index", Name: "public.idx_existing"},
+		{Kind: "column", Name: "public.orders.status"},
+	}}
+	applied := []string{
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_orders_status ON orders(status)",
+		"CREATE INDEX IF NOT EXISTS idx_existing ON orders(id)",
+		"CREATE MATERIALIZED VIEW mv_orders AS SELECT * FROM orders",
+		"ALTER TABLE orders ADD COLUMN total_cents bigint",
+		"ALTER TABLE orders ADD COLUMN status text",
+		"ALTER TABLE orders ADD CONSTRAINT c CHECK (id > 0)",
+		"ANALYZE orders",
+	}
+	got := CompensatingStatements(applied, before)
+	want := []string{
+		"ALTER TABLE IF EXISTS orders DROP COLUMN IF EXISTS total_cents",
+		"DROP MATERIALIZED VIEW IF EXISTS mv_orders",
+		"DROP INDEX IF EXISTS idx_orders_status",
+	}
+	if strings.Join(got, ";") != strings.Join(want, ";") { t.Fatalf("unexpected compensations:\n got %q\nwant %q", got, want) }
+}
+
+func TestRestoreFork_UndoesDDLAndVerifiesFingerprint(t *testing.T) {
+	ctx := context.Background()
+	m := newCatalogMCP()
+	before, err := TakeSchemaSnapshot(ctx, m, "fork-1")
+	if err != nil || before.Fingerprint == "" { t.Fatalf("snapshot err: %v", err) }
+
+	applied := []string{"CREATE INDEX idx_orders_status ON orders(status)", "CREATE INDEX ON orders(created_at)", "ALTER TABLE orders ADD COLUMN note"}
+	for _, s := range applied { m.ExecuteQuery(ctx, "fork-1", s, 0) }
+	if dirty, _ := TakeSchemaSnapshot(ctx, m, "fork-1"); dirty.Fingerprint == before.Fingerprint { t.Fatalf("fingerprint should change after DDL") }
+
+	if err := RestoreFork(ctx, m, "fork-1", before, applied); err != nil { t.Fatalf("restore err: %v", err) }
+	joined := strings.Join(m.seen, "\n")
+	for _, want := range []string{"DROP INDEX IF EXISTS idx_orders_status", "DROP INDEX IF EXISTS public.orders_created_at_idx"} {
+		if !strings.Contains(joined, want) { t.Fatalf("expected %q to run:\n%s", want, joined) }
+	}
+
+	// Objects that cannot be dropped leave the fork dirty
+	m.ExecuteQuery(ctx, "fork-1", "CREATE INDEX idx_stuck ON orders(id)", 0)
+	m.locked["index public.idx_stuck"] = true
+	err = RestoreFork(ctx, m, "fork-1", before, []string{"CREATE INDEX idx_stuck ON orders(id)"})
+	if err == nil || !strings.Contains(err.Error(), "added index public.idx_stuck") { t.Fatalf("expected drift error, got %v", err) }
+}
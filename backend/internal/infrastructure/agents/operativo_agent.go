@@ -3,12 +3,9 @@ package agents
 import (
 	"context"
 	"errors"
-	"fmt"
 	"strings"
-	"time"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
-	"github.com/tuusuario/afs-challenge/internal/domain/values"
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/llm"
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
 )
@@ -26,43 +23,57 @@ type OperativoAgent struct {
 }
 
 // AnalysisResult is a lightweight struct parsed from LLM JSON.
+// Task and Plan carry the analyzed input forward into the proposal phase;
+// PriorAttempts carries the outcome of earlier proposals when the agent is asked to revise.
 type AnalysisResult struct {
-	Insights []string `json:"insights"`
-	Issues   []string `json:"issues"`
-	Focus    []string `json:"focus_areas"`
+	Insights      []string       `json:"insights"`
+	Issues        []string       `json:"issues"`
+	Focus         []string       `json:"focus_areas"`
+	Task          *entities.Task `json:"-"`
+	Plan          *PlanContext   `json:"-"`
+	PriorAttempts []PriorAttempt `json:"-"`
 }
 
+// PriorAttempt is a previous proposal of the same agent and the feedback it produced on the fork.
+type PriorAttempt struct {
+	Attempt     int
+	Candidate   int // 0 when the attempt produced no valid proposal
+	SQLCommands []string
+	Feedback    string
+}
+
+// operativoPrompts is the default prompt profile of OperativoAgent.
+var operativoPrompts = PromptProfile{
+	AnalysisSystem:      "You are an expert PostgreSQL query optimizer (Operativo role). Respond ONLY with a valid JSON object.",
+	AnalysisInstruction: "Analyze the query and plan.",
+	ProposalSystem:      "You are an Operativo (Gemini) agent. Propose an index optimization. Respond ONLY JSON.",
+	ProposalInstruction: "Based on the analysis, propose an index or similar optimization.",
+}
+
+// NewOperativoAgent is the registry Constructor for OperativoAgent.
+func NewOperativoAgent(base *BaseAgent, mcpClient mcp.ForkProvider, llmClient llm.LLMClient) Agent {
+	return &OperativoAgent{Base: base, MCPQ: mcpClient, LLM: llmClient}
+}
+
+// TokenUsage reports the tokens used by the agent's LLM client.
+func (a *OperativoAgent) TokenUsage() (int, int) { return llmUsage(a.LLM) }
+
 func (a *OperativoAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (AnalysisResult, error) {
 	if a == nil || a.MCPQ == nil || a.LLM == nil || task == nil {
 		return AnalysisResult{}, errors.New("agent not initialized")
 	}
-	// 1) Run EXPLAIN ANALYZE (FORMAT JSON)
-	explainSQL := fmt.Sprintf("EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) %s", task.TargetQuery)
-	res, err := a.MCPQ.ExecuteQuery(ctx, forkID, explainSQL, 60000)
+	// 1) Run EXPLAIN ANALYZE (FORMAT JSON) and collect schema facts for the referenced tables
+	pc, err := BuildPlanContext(ctx, a.MCPQ, forkID, task.TargetQuery)
 	if err != nil { return AnalysisResult{}, err }
 
-	// 2) Build prompt (simplified): include summarized text
-	var explainText string
-	if len(res.Rows) > 0 {
-		// serialize minimal for prompt; in real impl we'd pass full JSON
-		explainText = "EXPLAIN available"
-	}
-	system := "You are an expert PostgreSQL query optimizer (Operativo role). Respond ONLY with a valid JSON object."
-	prompt := strings.Join([]string{
-		"Analyze the query and plan.",
-		"Return fields: insights[], issues[], focus_areas[].",
-		"Query:", task.TargetQuery,
-		"Explain:", explainText,
-	}, "\n")
-
-	obj, err := a.LLM.SendMessageWithJSON(prompt, system)
+	// 2) Build prompt from the real plan, DDL, indexes and statistics
+	pp := a.Base.prompts(operativoPrompts)
+	system := pp.AnalysisSystem
+	prompt := buildAnalysisPrompt(pp.AnalysisInstruction, pc)
+
+	obj, err := a.LLM.SendMessageWithJSON(ctx, prompt, system)
 	if err != nil { return AnalysisResult{}, err }
-	ar := AnalysisResult{}
-	// Map generic map into struct fields defensively
-	if v, ok := obj["insights"].([]interface{}); ok { ar.Insights = toStringSlice(v) }
-	if v, ok := obj["issues"].([]interface{}); ok { ar.Issues = toStringSlice(v) }
-	if v, ok := obj["focus_areas"].([]interface{}); ok { ar.Focus = toStringSlice(v) }
-	return ar, nil
+	return parseAnalysis(obj, task, pc), nil
 }
 
 // NormalizeProposalType convierte variantes comunes del LLM a valores válidos
@@ -90,71 +101,20 @@ func NormalizeProposalType(s string) string {
 	}
 }
 
-func (a *OperativoAgent) ProposeOptimization(ctx context.Context, analysis AnalysisResult, forkID string) (*entities.OptimizationProposal, error) {
+func (a *OperativoAgent) ProposeOptimization(ctx context.Context, analysis AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
 	if a == nil || a.LLM == nil { return nil, errors.New("agent not initialized") }
-	system := "You are an Operativo (Gemini) agent. Propose an index optimization. Respond ONLY JSON."
-	prompt := strings.Join([]string{
-		"Based on the analysis, propose an index or similar optimization.",
-		"Output JSON fields:",
-		"proposal_type (index|partial_index|composite_index|materialized_view|partitioning|denormalization|query_rewrite)",
-		"sql_commands (array of SQL strings)",
-		"rationale (string)",
-	}, "\n")
-	obj, err := a.LLM.SendMessageWithJSON(prompt, system)
+	pp := a.Base.prompts(operativoPrompts)
+	system := pp.ProposalSystem
+	prompt := buildProposalPrompt(pp.ProposalInstruction, analysis)
+	obj, err := a.LLM.SendMessageWithJSON(ctx, prompt, system)
 	if err != nil { return nil, err }
-	// Parse essentials
-	typeStr := NormalizeProposalType(getString(obj, "proposal_type"))
-	cmds := getStringSlice(obj, "sql_commands")
-	rat := getString(obj, "rationale")
-	// Minimal estimation
 	est := entities.EstimatedImpact{QueryTimeImprovement: 10, StorageOverheadMB: 1, Complexity: "low", Risk: "low"}
-	p := &entities.OptimizationProposal{
-		AgentExecutionID: 1,
-		ProposalType:     values.ProposalType(typeStr),
-		SQLCommands:      cmds,
-		Rationale:        rat,
-		EstimatedImpact:  est,
-		CreatedAt:        time.Now().UTC(),
-	}
-	if err := p.Validate(); err != nil { return nil, err }
-	return p, nil
+	return parseProposals(obj, est)
 }
 
-func (a *OperativoAgent) RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
-	if a == nil || a.MCPQ == nil || proposal == nil { return nil, errors.New("agent not initialized") }
-	queries := []struct{ name entities.BenchmarkQueryName; sql string }{
-		{entities.QueryNameBaseline, "SELECT 1"},
-		{entities.QueryNameTestLimit, "SELECT * FROM orders LIMIT 10"},
-		{entities.QueryNameTestFilter, "SELECT * FROM orders WHERE status='completed'"},
-		{entities.QueryNameTestSort, "SELECT * FROM orders ORDER BY created_at DESC LIMIT 10"},
-	}
-	results := make([]*entities.BenchmarkResult, 0, len(queries))
-	for _, q := range queries {
-		var total float64
-		for i := 0; i < 3; i++ {
-			qr, err := a.MCPQ.ExecuteQuery(ctx, forkID, q.sql, 60000)
-			if err != nil { return nil, err }
-			execTime := qr.ExecutionTimeMs
-			if execTime <= 0 {
-				execTime = 1.0 // fallback si MCP no devuelve tiempo
-			}
-			total += execTime
-		}
-		avg := total / 3
-		br := &entities.BenchmarkResult{
-			ProposalID:      proposal.ID,
-			QueryName:       q.name,
-			QueryExecuted:   q.sql,
-			ExecutionTimeMS: avg,
-			RowsReturned:    0,
-			ExplainPlan:     entities.ExplainPlan{PlanType: "Seq Scan"},
-			StorageImpactMB: 0,
-			CreatedAt:       time.Now().UTC(),
-		}
-		if err := br.Validate(); err != nil { return nil, err }
-		results = append(results, br)
-	}
-	return results, nil
+func (a *OperativoAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	if a == nil || a.MCPQ == nil || task == nil || proposal == nil { return nil, errors.New("agent not initialized") }
+	return BenchmarkProposal(ctx, a.MCPQ, proposal, forkID, task.TargetQuery, a.Base.benchmarkOptions())
 }
 
 // Helpers
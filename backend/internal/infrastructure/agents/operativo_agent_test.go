@@ -16,10 +16,10 @@ type mockLLM struct {
 	text    string
 }
 
-func (m *mockLLM) SendMessage(prompt, system string) (string, error) {
+func (m *mockLLM) SendMessage(ctx context.Context, prompt, system string) (string, error) {
 	return m.text, nil
 }
-func (m *mockLLM) SendMessageWithJSON(prompt, system string) (map[string]interface{}, error) {
+func (m *mockLLM) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) {
 	// Return a deep copy to avoid mutation between calls
 	b, _ := json.Marshal(m.jsonResp)
 	var out map[string]interface{}
@@ -60,16 +60,18 @@ func TestAgentOperativo(t *testing.T) {
 		"sql_commands": []interface{}{"CREATE INDEX idx_orders_status ON orders(status)"},
 		"rationale": "common filter",
 	}
-	prop, err := ag.ProposeOptimization(context.Background(), ar, "fork-1")
+	props, err := ag.ProposeOptimization(context.Background(), ar, "fork-1")
 	if err != nil { t.Fatalf("ProposeOptimization err: %v", err) }
+	if len(props) != 1 { t.Fatalf("expected 1 candidate, got %d", len(props)) }
+	prop := props[0]
 	if len(prop.SQLCommands) == 0 { t.Fatalf("expected sql commands") }
 	// Ensure ProposalID is positive for benchmark validation
 	prop.ID = 1
 
-	// RunBenchmark: should return 4 results with averaged times (based on mock sequence)
-	res, err := ag.RunBenchmark(context.Background(), prop, "fork-1")
+	// RunBenchmark: should return 3 results with averaged times (based on mock sequence)
+	res, err := ag.RunBenchmark(context.Background(), task, prop, "fork-1")
 	if err != nil { t.Fatalf("RunBenchmark err: %v", err) }
-	if len(res) != 4 { t.Fatalf("expected 4 results, got %d", len(res)) }
+	if len(res) != 3 { t.Fatalf("expected 3 results, got %d", len(res)) }
 	// First avg should be (12+9+15)/3 = 12
 	if res[0].ExecutionTimeMS <= 0 { t.Fatalf("unexpected avg: %v", res[0].ExecutionTimeMS) }
 }
@@ -0,0 +1,369 @@
+package agents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// explainTimeoutMs bounds the EXPLAIN ANALYZE run; catalogTimeoutMs bounds catalog lookups.
+const (
+	explainTimeoutMs = 60000
+	catalogTimeoutMs = 30000
+)
+
+// PlanNode mirrors a node of PostgreSQL's EXPLAIN (FORMAT JSON) output.
+type PlanNode struct {
+	NodeType            string     `json:"Node Type"`
+	RelationName        string     `json:"Relation Name,omitempty"`
+	Alias               string     `json:"Alias,omitempty"`
+	IndexName           string     `json:"Index Name,omitempty"`
+	JoinType            string     `json:"Join Type,omitempty"`
+	StartupCost         float64    `json:"Startup Cost"`
+	TotalCost           float64    `json:"Total Cost"`
+	PlanRows            float64    `json:"Plan Rows"`
+	ActualTotalTime     float64    `json:"Actual Total Time"`
+	ActualRows          float64    `json:"Actual Rows"`
+	ActualLoops         float64    `json:"Actual Loops"`
+	Filter              string     `json:"Filter,omitempty"`
+	IndexCond           string     `json:"Index Cond,omitempty"`
+	HashCond            string     `json:"Hash Cond,omitempty"`
+	JoinFilter          string     `json:"Join Filter,omitempty"`
+	RowsRemovedByFilter float64    `json:"Rows Removed by Filter"`
+	SortKey             []string   `json:"Sort Key,omitempty"`
+	SortMethod          string     `json:"Sort Method,omitempty"`
+	GroupKey            []string   `json:"Group Key,omitempty"`
+	SharedHitBlocks     int64      `json:"Shared Hit Blocks"`
+	SharedReadBlocks    int64      `json:"Shared Read Blocks"`
+	Plans               []PlanNode `json:"Plans,omitempty"`
+}
+
+// ExplainOutput is the top-level element of EXPLAIN (FORMAT JSON).
+type ExplainOutput struct {
+	Plan          PlanNode `json:"Plan"`
+	PlanningTime  float64  `json:"Planning Time"`
+	ExecutionTime float64  `json:"Execution Time"`
+	Raw           string   `json:"-"`
+}
+
+// ParseExplainJSON decodes the JSON document produced by EXPLAIN (FORMAT JSON).
+func ParseExplainJSON(raw []byte) (*ExplainOutput, error) {
+	var outs []ExplainOutput
+	if err := json.Unmarshal(raw, &outs); err != nil {
+		return nil, fmt.Errorf("explain: invalid JSON plan: %w", err)
+	}
+	if len(outs) == 0 {
+		return nil, errors.New("explain: empty plan")
+	}
+	out := outs[0]
+	out.Raw = string(raw)
+	return &out, nil
+}
+
+// ParseExplainResult extracts the plan from an EXPLAIN query result.
+// It returns nil without error when the result carries no rows.
+func ParseExplainResult(res mcp.QueryResult) (*ExplainOutput, error) {
+	if len(res.Rows) == 0 {
+		return nil, nil
+	}
+	row := res.Rows[0]
+	v, ok := row["QUERY PLAN"]
+	if !ok {
+		for _, val := range row { v = val; break }
+	}
+	var raw []byte
+	switch t := v.(type) {
+	case []byte:
+		raw = t
+	case string:
+		raw = []byte(t)
+	case nil:
+		return nil, nil
+	default:
+		// Transports that already decoded the JSON document
+		b, err := json.Marshal(t)
+		if err != nil { return nil, fmt.Errorf("explain: cannot encode plan: %w", err) }
+		raw = b
+	}
+	return ParseExplainJSON(raw)
+}
+
+// ExplainAnalyze runs EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) for query on the fork and parses the result.
+func ExplainAnalyze(ctx context.Context, q mcpQueryPort, forkID, query string) (*ExplainOutput, error) {
+	explainSQL := fmt.Sprintf("EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) %s", query)
+	res, err := q.ExecuteQuery(ctx, forkID, explainSQL, explainTimeoutMs)
+	if err != nil { return nil, err }
+	return ParseExplainResult(res)
+}
+
+// Walk visits every plan node depth-first.
+func (e *ExplainOutput) Walk(fn func(n *PlanNode, depth int)) {
+	if e == nil { return }
+	walkPlan(&e.Plan, 0, fn)
+}
+
+func walkPlan(n *PlanNode, depth int, fn func(n *PlanNode, depth int)) {
+	fn(n, depth)
+	for i := range n.Plans {
+		walkPlan(&n.Plans[i], depth+1, fn)
+	}
+}
+
+// Relations returns the distinct relation names scanned by the plan, in plan order.
+func (e *ExplainOutput) Relations() []string {
+	seen := map[string]bool{}
+	out := []string{}
+	e.Walk(func(n *PlanNode, _ int) {
+		if n.RelationName != "" && !seen[n.RelationName] {
+			seen[n.RelationName] = true
+			out = append(out, n.RelationName)
+		}
+	})
+	return out
+}
+
+// ColumnStats holds the pg_stats figures for one column.
+type ColumnStats struct {
+	Column         string
+	NullFrac       float64
+	NDistinct      float64
+	Correlation    float64
+	MostCommonVals string
+}
+
+// TableContext describes a relation referenced by the plan.
+type TableContext struct {
+	Name          string
+	DDL           string
+	Indexes       []string
+	EstimatedRows float64
+	Stats         []ColumnStats
+}
+
+// PlanContext bundles the execution plan with the schema facts the LLM needs to reason about it.
+type PlanContext struct {
+	Query   string
+	Explain *ExplainOutput
+	Tables  []TableContext
+}
+
+// BuildPlanContext runs EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) on the fork and enriches the
+// parsed plan with table DDL, existing indexes, row estimates and pg_stats of referenced columns.
+// EXPLAIN failures are returned; catalog lookups are best-effort so a restricted role still gets a plan.
+func BuildPlanContext(ctx context.Context, q mcpQueryPort, forkID, query string) (*PlanContext, error) {
+	if q == nil { return nil, errors.New("query port required") }
+	plan, err := ExplainAnalyze(ctx, q, forkID, query)
+	if err != nil { return nil, err }
+	pc := &PlanContext{Query: query, Explain: plan}
+	rels := plan.Relations()
+	if len(rels) == 0 { return pc, nil }
+
+	tables := map[string]*TableContext{}
+	for _, r := range rels {
+		pc.Tables = append(pc.Tables, TableContext{Name: r})
+	}
+	for i := range pc.Tables {
+		tables[pc.Tables[i].Name] = &pc.Tables[i]
+	}
+	relList := sqlLiteralList(rels)
+
+	// 1) Columns → DDL and referenced columns
+	referenced := []string{}
+	colSQL := `SELECT table_name::text AS table_name, column_name::text AS column_name, data_type::text AS data_type,
+		is_nullable::text AS is_nullable, column_default::text AS column_default
+		FROM information_schema.columns
+		WHERE table_schema NOT IN ('pg_catalog','information_schema') AND table_name IN (` + relList + `)
+		ORDER BY table_name, ordinal_position`
+	if cr, err := q.ExecuteQuery(ctx, forkID, colSQL, catalogTimeoutMs); err == nil {
+		cols := map[string][]string{}
+		queryWords := identifierSet(query)
+		seen := map[string]bool{}
+		for _, row := range cr.Rows {
+			tbl := rowString(row, "table_name")
+			name := rowString(row, "column_name")
+			def := name + " " + rowString(row, "data_type")
+			if rowString(row, "is_nullable") == "NO" { def += " NOT NULL" }
+			if d := rowString(row, "column_default"); d != "" { def += " DEFAULT " + d }
+			cols[tbl] = append(cols[tbl], def)
+			if queryWords[strings.ToLower(name)] && !seen[name] {
+				seen[name] = true
+				referenced = append(referenced, name)
+			}
+		}
+		for tbl, defs := range cols {
+			if t, ok := tables[tbl]; ok {
+				t.DDL = fmt.Sprintf("CREATE TABLE %s (\n  %s\n);", tbl, strings.Join(defs, ",\n  "))
+			}
+		}
+	}
+
+	// 2) Existing indexes
+	idxSQL := `SELECT tablename::text AS table_name, indexdef::text AS indexdef FROM pg_indexes
+		WHERE tablename IN (` + relList + `) ORDER BY tablename, indexname`
+	if ir, err := q.ExecuteQuery(ctx, forkID, idxSQL, catalogTimeoutMs); err == nil {
+		for _, row := range ir.Rows {
+			if t, ok := tables[rowString(row, "table_name")]; ok {
+				t.Indexes = append(t.Indexes, rowString(row, "indexdef"))
+			}
+		}
+	}
+
+	// 3) Row estimates
+	relSQL := `SELECT relname::text AS table_name, reltuples::float8 AS reltuples FROM pg_class
+		WHERE relkind IN ('r','p','m') AND relname IN (` + relList + `)`
+	if rr, err := q.ExecuteQuery(ctx, forkID, relSQL, catalogTimeoutMs); err == nil {
+		for _, row := range rr.Rows {
+			if t, ok := tables[rowString(row, "table_name")]; ok {
+				t.EstimatedRows = rowFloat(row, "reltuples")
+			}
+		}
+	}
+
+	// 4) pg_stats for the columns the query mentions
+	if len(referenced) > 0 {
+		statSQL := `SELECT tablename::text AS table_name, attname::text AS column_name, null_frac::float8 AS null_frac,
+			n_distinct::float8 AS n_distinct, correlation::float8 AS correlation, most_common_vals::text AS most_common_vals
+			FROM pg_stats WHERE tablename IN (` + relList + `) AND attname IN (` + sqlLiteralList(referenced) + `)
+			ORDER BY tablename, attname`
+		if sr, err := q.ExecuteQuery(ctx, forkID, statSQL, catalogTimeoutMs); err == nil {
+			for _, row := range sr.Rows {
+				if t, ok := tables[rowString(row, "table_name")]; ok {
+					t.Stats = append(t.Stats, ColumnStats{
+						Column:         rowString(row, "column_name"),
+						NullFrac:       rowFloat(row, "null_frac"),
+						NDistinct:      rowFloat(row, "n_distinct"),
+						Correlation:    rowFloat(row, "correlation"),
+						MostCommonVals: rowString(row, "most_common_vals"),
+					})
+				}
+			}
+		}
+	}
+	return pc, nil
+}
+
+// PromptText renders the plan context as plain text for LLM prompts.
+func (pc *PlanContext) PromptText() string {
+	if pc == nil { return "" }
+	var b strings.Builder
+	if pc.Explain == nil {
+		b.WriteString("Execution plan: unavailable\n")
+	} else {
+		fmt.Fprintf(&b, "Execution plan (planning %.3f ms, execution %.3f ms):\n", pc.Explain.PlanningTime, pc.Explain.ExecutionTime)
+		pc.Explain.Walk(func(n *PlanNode, depth int) {
+			b.WriteString(strings.Repeat("  ", depth))
+			b.WriteString("-> ")
+			b.WriteString(describeNode(n))
+			b.WriteString("\n")
+		})
+	}
+	for _, t := range pc.Tables {
+		fmt.Fprintf(&b, "\nTable %s (~%.0f rows)\n", t.Name, t.EstimatedRows)
+		if t.DDL != "" { b.WriteString(t.DDL + "\n") }
+		if len(t.Indexes) == 0 {
+			b.WriteString("Indexes: none\n")
+		} else {
+			b.WriteString("Indexes:\n")
+			for _, idx := range t.Indexes { b.WriteString("  " + idx + "\n") }
+		}
+		if len(t.Stats) > 0 {
+			b.WriteString("Column statistics:\n")
+			for _, s := range t.Stats {
+				fmt.Fprintf(&b, "  %s: null_frac=%.3f n_distinct=%.0f correlation=%.3f", s.Column, s.NullFrac, s.NDistinct, s.Correlation)
+				if s.MostCommonVals != "" { fmt.Fprintf(&b, " most_common_vals=%s", truncate(s.MostCommonVals, 120)) }
+				b.WriteString("\n")
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func describeNode(n *PlanNode) string {
+	parts := []string{n.NodeType}
+	if n.RelationName != "" { parts = append(parts, "on "+n.RelationName) }
+	if n.IndexName != "" { parts = append(parts, "using "+n.IndexName) }
+	s := strings.Join(parts, " ")
+	s += fmt.Sprintf(" (cost=%.2f..%.2f rows=%.0f) (actual time=%.3f rows=%.0f loops=%.0f)",
+		n.StartupCost, n.TotalCost, n.PlanRows, n.ActualTotalTime, n.ActualRows, n.ActualLoops)
+	extras := []string{}
+	if n.JoinType != "" { extras = append(extras, "Join Type: "+n.JoinType) }
+	if n.IndexCond != "" { extras = append(extras, "Index Cond: "+n.IndexCond) }
+	if n.HashCond != "" { extras = append(extras, "Hash Cond: "+n.HashCond) }
+	if n.JoinFilter != "" { extras = append(extras, "Join Filter: "+n.JoinFilter) }
+	if n.Filter != "" { extras = append(extras, "Filter: "+n.Filter) }
+	if n.RowsRemovedByFilter > 0 { extras = append(extras, fmt.Sprintf("Rows Removed by Filter: %.0f", n.RowsRemovedByFilter)) }
+	if len(n.SortKey) > 0 { extras = append(extras, "Sort Key: "+strings.Join(n.SortKey, ", ")) }
+	if n.SortMethod != "" { extras = append(extras, "Sort Method: "+n.SortMethod) }
+	if len(n.GroupKey) > 0 { extras = append(extras, "Group Key: "+strings.Join(n.GroupKey, ", ")) }
+	if n.SharedHitBlocks > 0 || n.SharedReadBlocks > 0 {
+		extras = append(extras, fmt.Sprintf("Buffers: shared hit=%d read=%d", n.SharedHitBlocks, n.SharedReadBlocks))
+	}
+	if len(extras) > 0 { s += " [" + strings.Join(extras, "; ") + "]" }
+	return s
+}
+
+var identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// identifierSet returns the lower-cased identifiers appearing in a SQL text.
+func identifierSet(sql string) map[string]bool {
+	out := map[string]bool{}
+	for _, w := range identRe.FindAllString(sql, -1) { out[strings.ToLower(w)] = true }
+	return out
+}
+
+// sqlLiteralList renders a comma-separated list of quoted SQL string literals.
+func sqlLiteralList(items []string) string {
+	sorted := append([]string(nil), items...)
+	sort.Strings(sorted)
+	quoted := make([]string, 0, len(sorted))
+	for _, s := range sorted { quoted = append(quoted, quoteLiteral(s)) }
+	return strings.Join(quoted, ",")
+}
+
+func quoteLiteral(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+
+func rowString(row map[string]any, k string) string {
+	switch v := row[k].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func rowFloat(row map[string]any, k string) float64 {
+	switch v := row[k].(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case int:
+		return float64(v)
+	case []byte:
+		f, _ := strconv.ParseFloat(string(v), 64)
+		return f
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	default:
+		return 0
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n { return s }
+	return s[:n] + "..."
+}
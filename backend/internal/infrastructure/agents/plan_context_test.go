@@ -0,0 +1,170 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+const samplePlan = `[{"Plan": {"Node Type": "Sort", "Startup Cost": 10.5, "Total Cost": 12.0, "Plan Rows": 50,
+ "Actual Total Time": 3.2, "Actual Rows": 48, "Actual Loops": 1, "Sort Key": ["created_at DESC"], "Sort Method": "quicksort",
+ "Plans": [{"Node Type": "Seq Scan", "Relation Name": "orders", "Alias": "orders", "Startup Cost": 0, "Total Cost": 9.0,
+  "Plan Rows": 50, "Actual Total Time": 2.9, "Actual Rows": 48, "Actual Loops": 1, "Filter": "(status = 'completed'::text)",
+  "Rows Removed by Filter": 952, "Shared Hit Blocks": 4, "Shared Read Blocks": 7}]},
+ "Planning Time": 0.21, "Execution Time": 3.5}]`
+
+// scriptedMCP answers queries by matching SQL fragments, recording every statement it sees.
+type scriptedMCP struct {
+	answers map[string][]map[string]any
+	seen    []string
+}
+
+func (m *scriptedMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	m.seen = append(m.seen, sql)
+	for frag, rows := range m.answers {
+		if strings.Contains(sql, frag) {
+			return mcp.QueryResult{Rows: rows, RowCount: len(rows), ExecutionTimeMs: 1}, nil
+		}
+	}
+	return mcp.QueryResult{ExecutionTimeMs: 1}, nil
+}
+
+type capturingLLM struct {
+	prompts  []string
+	jsonResp map[string]interface{}
+}
+
+func (c *capturingLLM) SendMessage(ctx context.Context, prompt, system string) (string, error) { return "", nil }
+func (c *capturingLLM) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) {
+	c.prompts = append(c.prompts, prompt)
+	return c.jsonResp, nil
+}
+func (c *capturingLLM) GetUsage() (int, int) { return 0, 0 }
+
+func newPlanMCP() *scriptedMCP {
+	return &scriptedMCP{answers: map[string][]map[string]any{
+		"EXPLAIN (ANALYZE": {{"QUERY PLAN": []byte(samplePlan)}},
+		"information_schema.columns": {
+			{"table_name": []byte("orders"), "column_name": []byte("id"), "data_type": "integer", "is_nullable": "NO", "column_default": "nextval('orders_id_seq'::regclass)"},
+			{"table_name": []byte("orders"), "column_name": []byte("status"), "data_type": "text", "is_nullable": "YES", "column_default": nil},
+			{"table_name": []byte("orders"), "column_name": []byte("created_at"), "data_type": "timestamp without time zone", "is_nullable": "YES", "column_default": nil},
+		},
+		"pg_indexes": {{"table_name": "orders", "indexdef": "CREATE UNIQUE INDEX orders_pkey ON public.orders USING btree (id)"}},
+		"pg_class":   {{"table_name": "orders", "reltuples": 1000.0}},
+		"pg_stats":   {{"table_name": "orders", "column_name": "status", "null_frac": 0.0, "n_distinct": 3.0, "correlation": 0.12, "most_common_vals": "{completed,pending,processing}"}},
+	}}
+}
+
+func TestParseExplainJSON(t *testing.T) {
+	plan, err := ParseExplainJSON([]byte(samplePlan))
+	if err != nil { t.Fatalf("parse err: %v", err) }
+	if plan.ExecutionTime != 3.5 || plan.PlanningTime != 0.21 { t.Fatalf("unexpected timings: %+v", plan) }
+	if rels := plan.Relations(); len(rels) != 1 || rels[0] != "orders" { t.Fatalf("unexpected relations: %v", rels) }
+	if _, err := ParseExplainJSON([]byte(`{"not":"a plan"}`)); err == nil { t.Fatalf("expected error for malformed plan") }
+}
+
+func TestBuildPlanContext(t *testing.T) {
+	m := newPlanMCP()
+	query := "SELECT * FROM orders WHERE status='completed' ORDER BY created_at DESC"
+	pc, err := BuildPlanContext(context.Background(), m, "fork-1", query)
+	if err != nil { t.Fatalf("BuildPlanContext err: %v", err) }
+	if len(pc.Tables) != 1 { t.Fatalf("expected 1 table, got %d", len(pc.Tables)) }
+	tbl := pc.Tables[0]
+	if tbl.EstimatedRows != 1000 { t.Fatalf("unexpected row estimate: %v", tbl.EstimatedRows) }
+	if len(tbl.Indexes) != 1 || len(tbl.Stats) != 1 { t.Fatalf("unexpected table context: %+v", tbl) }
+	// Only columns mentioned by the query are requested from pg_stats
+	last := m.seen[len(m.seen)-1]
+	if !strings.Contains(last, "'status'") || !strings.Contains(last, "'created_at'") || strings.Contains(last, "'id'") {
+		t.Fatalf("unexpected pg_stats filter: %s", last)
+	}
+
+	text := pc.PromptText()
+	for _, want := range []string{"Seq Scan on orders", "Rows Removed by Filter: 952", "status text", "orders_pkey", "n_distinct=3"} {
+		if !strings.Contains(text, want) { t.Fatalf("prompt text missing %q:\n%s", want, text) }
+	}
+}
+
+func TestAnalyzeTask_PromptIncludesPlan(t *testing.T) {
+	llm := &capturingLLM{jsonResp: map[string]interface{}{"insights": []interface{}{"seq scan on orders"}}}
+	task := &entities.Task{TargetQuery: "SELECT * FROM orders WHERE status='completed'"}
+	ags := []Agent{
+		&OperativoAgent{MCPQ: newPlanMCP(), LLM: llm},
+		&CerebroAgent{MCPQ: newPlanMCP(), LLM: llm},
+		&OperativoCompatAgent{MCPQ: newPlanMCP(), LLM: llm},
+	}
+	for _, ag := range ags {
+		if _, err := ag.AnalyzeTask(context.Background(), task, "fork-1"); err != nil { t.Fatalf("AnalyzeTask err: %v", err) }
+	}
+	for i, p := range llm.prompts {
+		if !strings.Contains(p, "Seq Scan on orders") || !strings.Contains(p, "CREATE TABLE orders") {
+			t.Fatalf("prompt %d lacks plan context:\n%s", i, p)
+		}
+	}
+}
+
+func TestProposeOptimization_PromptCarriesAnalysis(t *testing.T) {
+	task := &entities.Task{TargetQuery: "SELECT * FROM orders WHERE status='completed'", Description: "slow completed orders report"}
+	ags := []Agent{
+		&OperativoAgent{MCPQ: newPlanMCP()},
+		&CerebroAgent{MCPQ: newPlanMCP()},
+		&OperativoCompatAgent{MCPQ: newPlanMCP()},
+	}
+	for i, ag := range ags {
+		llm := &capturingLLM{jsonResp: map[string]interface{}{
+			"insights":    []interface{}{"seq scan on orders"},
+			"issues":      []interface{}{"952 rows removed by filter"},
+			"focus_areas": []interface{}{"status predicate"},
+		}}
+		switch a := ag.(type) {
+		case *OperativoAgent: a.LLM = llm
+		case *CerebroAgent: a.LLM = llm
+		case *OperativoCompatAgent: a.LLM = llm
+		}
+		analysis, err := ag.AnalyzeTask(context.Background(), task, "fork-1")
+		if err != nil { t.Fatalf("AnalyzeTask err: %v", err) }
+		if analysis.Task != task || analysis.Plan == nil { t.Fatalf("analysis %d lost task/plan: %+v", i, analysis) }
+		llm.jsonResp = map[string]interface{}{"proposal_type": "index", "sql_commands": []interface{}{"CREATE INDEX idx_orders_status ON orders(status);"}, "rationale": "filter"}
+		if _, err := ag.ProposeOptimization(context.Background(), analysis, "fork-1"); err != nil { t.Fatalf("ProposeOptimization err: %v", err) }
+		p := llm.prompts[len(llm.prompts)-1]
+		for _, want := range []string{task.TargetQuery, task.Description, "Seq Scan on orders", "- seq scan on orders", "- 952 rows removed by filter", "- status predicate", "sql_commands"} {
+			if !strings.Contains(p, want) { t.Fatalf("proposal prompt %d missing %q:\n%s", i, want, p) }
+		}
+	}
+}
+
+func TestBuildProposalPrompt_PriorAttempts(t *testing.T) {
+	analysis := AnalysisResult{Task: &entities.Task{TargetQuery: "SELECT 1"}}
+	if strings.Contains(buildProposalPrompt("Propose.", analysis), "Previous attempts") { t.Fatalf("no attempts section expected on the first attempt") }
+	analysis.PriorAttempts = []PriorAttempt{{Attempt: 1, SQLCommands: []string{"CREATE INDEX ON ordrs(status)"}, Feedback: `execution on fork failed: relation "ordrs" does not exist`}}
+	p := buildProposalPrompt("Propose.", analysis)
+	for _, want := range []string{"Previous attempts on this fork", "Attempt 1:", "CREATE INDEX ON ordrs(status)", `relation "ordrs" does not exist`} {
+		if !strings.Contains(p, want) { t.Fatalf("prompt missing %q:\n%s", want, p) }
+	}
+	if strings.Index(p, "Previous attempts") > strings.Index(p, "Output JSON fields:") { t.Fatalf("attempts should precede the output spec:\n%s", p) }
+}
+
+func TestParseProposals_RankedCandidates(t *testing.T) {
+	est := entities.EstimatedImpact{QueryTimeImprovement: 10, Complexity: "low", Risk: "low"}
+	obj := map[string]interface{}{"proposals": []interface{}{
+		map[string]interface{}{"proposal_type": "index", "sql_commands": []interface{}{"CREATE INDEX ON orders(status)"}, "rationale": "filter"},
+		map[string]interface{}{"proposal_type": "bogus", "sql_commands": []interface{}{"SELECT 1"}},
+		map[string]interface{}{"proposal_type": "rewrite", "sql_commands": []interface{}{"SELECT id FROM orders WHERE status='x'"}, "rationale": "narrow columns"},
+		map[string]interface{}{"proposal_type": "index", "sql_commands": []interface{}{"CREATE INDEX ON orders(id)"}, "rationale": "join key"},
+		map[string]interface{}{"proposal_type": "index", "sql_commands": []interface{}{"CREATE INDEX ON orders(created_at)"}, "rationale": "range"},
+	}}
+	props, err := parseProposals(obj, est)
+	if err != nil { t.Fatalf("parseProposals err: %v", err) }
+	if len(props) != maxProposalCandidates { t.Fatalf("expected %d candidates, got %d", maxProposalCandidates, len(props)) }
+	if props[1].ProposalType != "query_rewrite" || props[2].SQLCommands[0] != "CREATE INDEX ON orders(id)" { t.Fatalf("invalid candidate not skipped: %+v", props) }
+	for i, p := range props {
+		if p.EstimatedImpact.AgentRank != i+1 { t.Fatalf("candidate %d has rank %d", i, p.EstimatedImpact.AgentRank) }
+	}
+
+	// Models that ignore the array still yield their single proposal
+	single, err := parseProposals(map[string]interface{}{"proposal_type": "index", "sql_commands": []interface{}{"CREATE INDEX ON orders(status)"}, "rationale": "filter"}, est)
+	if err != nil || len(single) != 1 { t.Fatalf("expected single top-level proposal: %v %+v", err, single) }
+	if _, err := parseProposals(map[string]interface{}{"proposals": []interface{}{}}, est); err == nil { t.Fatalf("expected error for empty proposals") }
+}
@@ -0,0 +1,125 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+// maxProposalCandidates caps the ranked candidates an agent returns per proposal round.
+const maxProposalCandidates = 3
+
+// proposalOutputSpec is the JSON contract every agent asks the LLM to honor in the proposal phase.
+var proposalOutputSpec = []string{
+	"Output JSON fields:",
+	fmt.Sprintf("proposals (array of 1 to %d alternative candidates, best first), each with:", maxProposalCandidates),
+	"  proposal_type (index|partial_index|composite_index|materialized_view|partitioning|denormalization|query_rewrite)",
+	"  sql_commands (array of SQL strings)",
+	"  rationale (string)",
+}
+
+// buildAnalysisPrompt assembles the analysis prompt shared by all agents.
+func buildAnalysisPrompt(instruction string, pc *PlanContext) string {
+	return strings.Join([]string{
+		instruction,
+		"Return fields: insights[], issues[], focus_areas[].",
+		"Query:", pc.Query,
+		pc.PromptText(),
+	}, "\n")
+}
+
+// buildProposalPrompt assembles the proposal prompt from the analysis phase output,
+// so the model proposes against the same query, plan and findings it just analyzed.
+func buildProposalPrompt(instruction string, analysis AnalysisResult) string {
+	lines := []string{instruction}
+	if analysis.Task != nil {
+		lines = append(lines, "Query:", analysis.Task.TargetQuery)
+		if strings.TrimSpace(analysis.Task.Description) != "" {
+			lines = append(lines, "Task description: "+analysis.Task.Description)
+		}
+	}
+	if analysis.Plan != nil {
+		lines = append(lines, analysis.Plan.PromptText())
+	}
+	lines = append(lines, bulletSection("Insights from analysis:", analysis.Insights)...)
+	lines = append(lines, bulletSection("Issues detected:", analysis.Issues)...)
+	lines = append(lines, bulletSection("Focus areas:", analysis.Focus)...)
+	lines = append(lines, priorAttemptsSection(analysis.PriorAttempts)...)
+	lines = append(lines, proposalOutputSpec...)
+	return strings.Join(lines, "\n")
+}
+
+// priorAttemptsSection lists earlier proposals with the error or benchmark outcome they got,
+// asking the model to revise instead of repeating them.
+func priorAttemptsSection(attempts []PriorAttempt) []string {
+	if len(attempts) == 0 { return nil }
+	out := []string{"Previous attempts on this fork (do not repeat them; revise the proposals to address the feedback):"}
+	for _, at := range attempts {
+		if at.Candidate > 0 {
+			out = append(out, fmt.Sprintf("Attempt %d, candidate %d:", at.Attempt, at.Candidate))
+		} else {
+			out = append(out, fmt.Sprintf("Attempt %d:", at.Attempt))
+		}
+		for _, stmt := range at.SQLCommands { out = append(out, "  "+stmt) }
+		out = append(out, "  Feedback: "+at.Feedback)
+	}
+	return out
+}
+
+func bulletSection(title string, items []string) []string {
+	if len(items) == 0 { return nil }
+	out := []string{title}
+	for _, it := range items { out = append(out, "- "+it) }
+	return out
+}
+
+// parseAnalysis maps the LLM JSON object into an AnalysisResult defensively,
+// attaching the task and plan context that produced it.
+func parseAnalysis(obj map[string]interface{}, task *entities.Task, pc *PlanContext) AnalysisResult {
+	ar := AnalysisResult{Task: task, Plan: pc}
+	if v, ok := obj["insights"].([]interface{}); ok { ar.Insights = toStringSlice(v) }
+	if v, ok := obj["issues"].([]interface{}); ok { ar.Issues = toStringSlice(v) }
+	if v, ok := obj["focus_areas"].([]interface{}); ok { ar.Focus = toStringSlice(v) }
+	return ar
+}
+
+// parseProposals maps the LLM JSON into the agent's ranked candidates, best first.
+// It reads the "proposals" array, or a single top-level proposal from models that ignore it.
+// Candidates failing validation are dropped; when none survive, the first validation error is returned.
+func parseProposals(obj map[string]interface{}, est entities.EstimatedImpact) ([]*entities.OptimizationProposal, error) {
+	cands := []map[string]interface{}{obj}
+	if arr, ok := obj["proposals"].([]interface{}); ok {
+		cands = cands[:0]
+		for _, c := range arr {
+			if m, ok := c.(map[string]interface{}); ok { cands = append(cands, m) }
+		}
+	}
+	var out []*entities.OptimizationProposal
+	var firstErr error
+	for _, c := range cands {
+		if len(out) == maxProposalCandidates { break }
+		p := &entities.OptimizationProposal{
+			AgentExecutionID: 1,
+			ProposalType:     values.ProposalType(NormalizeProposalType(getString(c, "proposal_type"))),
+			SQLCommands:      getStringSlice(c, "sql_commands"),
+			Rationale:        getString(c, "rationale"),
+			EstimatedImpact:  est,
+			CreatedAt:        time.Now().UTC(),
+		}
+		p.EstimatedImpact.AgentRank = len(out) + 1
+		if err := p.Validate(); err != nil {
+			if firstErr == nil { firstErr = err }
+			continue
+		}
+		out = append(out, p)
+	}
+	if len(out) == 0 {
+		if firstErr == nil { firstErr = errors.New("no proposals in LLM response") }
+		return nil, firstErr
+	}
+	return out, nil
+}
@@ -0,0 +1,145 @@
+package agents
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/llm"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// PromptProfile holds the system prompts and instructions an agent sends to the LLM.
+// Empty fields fall back to the agent implementation's defaults.
+type PromptProfile struct {
+	AnalysisSystem      string `json:"analysis_system,omitempty"`
+	AnalysisInstruction string `json:"analysis_instruction,omitempty"`
+	ProposalSystem      string `json:"proposal_system,omitempty"`
+	ProposalInstruction string `json:"proposal_instruction,omitempty"`
+}
+
+// withDefaults fills the empty fields of p from def.
+func (p PromptProfile) withDefaults(def PromptProfile) PromptProfile {
+	if p.AnalysisSystem == "" { p.AnalysisSystem = def.AnalysisSystem }
+	if p.AnalysisInstruction == "" { p.AnalysisInstruction = def.AnalysisInstruction }
+	if p.ProposalSystem == "" { p.ProposalSystem = def.ProposalSystem }
+	if p.ProposalInstruction == "" { p.ProposalInstruction = def.ProposalInstruction }
+	return p
+}
+
+// Constructor builds an agent from its wired BaseAgent. base.Prompts carries the kind's prompt profile.
+type Constructor func(base *BaseAgent, mcpClient mcp.ForkProvider, llmClient llm.LLMClient) Agent
+
+// Kind describes a registered agent kind: how to build it, which model it runs on
+// and which prompts it uses.
+type Kind struct {
+	Type           values.AgentType
+	Specialization values.AgentSpecialization
+	Description    string
+	Aliases        []string // extra names accepted by values.ParseAgentType
+	DefaultModel   string
+	ConfigModel    func(cfg *cfgpkg.Config) string // optional per-deployment model override
+	Prompts        PromptProfile
+	New            Constructor
+}
+
+// Model returns the configured model for the kind, or DefaultModel when none is configured.
+func (k Kind) Model(cfg *cfgpkg.Config) string {
+	if cfg != nil && k.ConfigModel != nil {
+		if m := strings.TrimSpace(k.ConfigModel(cfg)); m != "" { return m }
+	}
+	return k.DefaultModel
+}
+
+var registry = struct {
+	sync.RWMutex
+	order []values.AgentType
+	kinds map[values.AgentType]Kind
+}{kinds: map[values.AgentType]Kind{}}
+
+func init() {
+	builtin := []Kind{
+		{
+			Type:           values.AgentCerebro,
+			Specialization: values.SpecializationPlanningQA,
+			Description:    "Materialized views and advanced strategies",
+			Aliases:        []string{"gemini-2.5-pro", "gemini25pro"},
+			DefaultModel:   "gemini-2.5-pro",
+			ConfigModel:    func(cfg *cfgpkg.Config) string { return cfg.VertexAI.ModelCerebro },
+			Prompts:        cerebroPrompts,
+			New:            NewCerebroAgent,
+		},
+		{
+			Type:           values.AgentOperativo,
+			Specialization: values.SpecializationOperational,
+			Description:    "Indexes and targeted query optimizations",
+			Aliases:        []string{"gemini-2.5-flash", "gemini25flash"},
+			DefaultModel:   "gemini-2.5-flash",
+			ConfigModel:    func(cfg *cfgpkg.Config) string { return cfg.VertexAI.ModelOperativo },
+			Prompts:        operativoPrompts,
+			New:            NewOperativoAgent,
+		},
+		{
+			Type:           values.AgentBulk,
+			Specialization: values.SpecializationBulk,
+			Description:    "Low-cost partitioning and schema redesign proposals",
+			Aliases:        []string{"gemini-2.0-flash", "gemini20flash"},
+			DefaultModel:   "gemini-2.0-flash",
+			ConfigModel:    func(cfg *cfgpkg.Config) string { return cfg.VertexAI.ModelBulk },
+			Prompts:        partitioningPrompts,
+			New:            NewOperativoCompatAgent,
+		},
+	}
+	for _, k := range builtin {
+		if err := RegisterKind(k); err != nil { panic(err) }
+	}
+}
+
+// RegisterKind adds or replaces an agent kind. A kind beyond the built-in roles (which values
+// declares itself) is also declared valid in the domain, so AgentExecution.Validate and
+// values.ParseAgentType accept it without further changes.
+func RegisterKind(k Kind) error {
+	k.Type = values.AgentType(strings.TrimSpace(string(k.Type)))
+	if k.Type == "" { return errors.New("agent kind: type is required") }
+	if k.New == nil { return fmt.Errorf("agent kind %s: constructor is required", k.Type) }
+	if strings.TrimSpace(k.DefaultModel) == "" { return fmt.Errorf("agent kind %s: default model is required", k.Type) }
+	registry.Lock()
+	defer registry.Unlock()
+	if _, ok := registry.kinds[k.Type]; !ok {
+		registry.order = append(registry.order, k.Type)
+	}
+	registry.kinds[k.Type] = k
+	if !builtinType(k.Type) { values.RegisterAgentType(k.Type, k.Specialization, k.Aliases...) }
+	return nil
+}
+
+// builtinType reports whether t is one of the roles values registers on its own.
+func builtinType(t values.AgentType) bool {
+	return t == values.AgentCerebro || t == values.AgentOperativo || t == values.AgentBulk
+}
+
+// LookupKind returns the registered kind for an agent type.
+func LookupKind(t values.AgentType) (Kind, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	k, ok := registry.kinds[t]
+	return k, ok
+}
+
+// Kinds returns every registered kind in registration order.
+func Kinds() []Kind {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]Kind, 0, len(registry.order))
+	for _, t := range registry.order { out = append(out, registry.kinds[t]) }
+	return out
+}
+
+func supportedKinds() string {
+	names := []string{}
+	for _, k := range Kinds() { names = append(names, string(k.Type)) }
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,68 @@
+package agents
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+// systemLLM records the system prompt and prompt of every call.
+type systemLLM struct{ systems, prompts []string }
+
+func (s *systemLLM) SendMessage(ctx context.Context, prompt, system string) (string, error) { return "", nil }
+func (s *systemLLM) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) {
+	s.systems = append(s.systems, system)
+	s.prompts = append(s.prompts, prompt)
+	return map[string]interface{}{}, nil
+}
+func (s *systemLLM) GetUsage() (int, int) { return 0, 0 }
+
+func TestRegistry_BuiltinKinds(t *testing.T) {
+	want := map[values.AgentType]string{values.AgentCerebro: "gemini-2.5-pro", values.AgentOperativo: "gemini-2.5-flash", values.AgentBulk: "gemini-2.0-flash"}
+	for at, model := range want {
+		k, ok := LookupKind(at)
+		if !ok { t.Fatalf("kind %s not registered", at) }
+		if k.Model(nil) != model { t.Fatalf("unexpected default model for %s: %s", at, k.Model(nil)) }
+	}
+	cfg := &cfgpkg.Config{}
+	cfg.VertexAI.ModelBulk = "bulk-override"
+	if k, _ := LookupKind(values.AgentBulk); k.Model(cfg) != "bulk-override" { t.Fatalf("config model should override the default") }
+	ag, err := NewAgent(values.AgentBulk, nil, &dummyLLM{}, cfg)
+	if err != nil { t.Fatalf("NewAgent err: %v", err) }
+	if _, ok := ag.(*OperativoCompatAgent); !ok { t.Fatalf("bulk should build the partitioning agent, got %T", ag) }
+}
+
+func TestRegistry_CustomKind(t *testing.T) {
+	vacuum := values.AgentType("vacuum_stats")
+	if err := RegisterKind(Kind{Type: vacuum}); err == nil { t.Fatalf("expected error for kind without constructor") }
+	err := RegisterKind(Kind{
+		Type:           vacuum,
+		Specialization: "Maintenance/Statistics",
+		Aliases:        []string{"vacuum"},
+		DefaultModel:   "gemini-2.5-flash",
+		Prompts:        PromptProfile{AnalysisSystem: "You are a VACUUM and statistics specialist.", AnalysisInstruction: "Check for stale statistics and bloat."},
+		New:            NewOperativoAgent,
+	})
+	if err != nil { t.Fatalf("RegisterKind err: %v", err) }
+	if values.ParseAgentType("vacuum") != vacuum { t.Fatalf("alias not registered in the domain") }
+	exec := &entities.AgentExecution{TaskID: 1, AgentType: vacuum, ForkID: "fork-1", Status: entities.ExecutionRunning, StartedAt: time.Now()}
+	if err := exec.Validate(); err != nil { t.Fatalf("registered kind should be a valid agent type: %v", err) }
+
+	llm := &systemLLM{}
+	ag, err := NewAgent(vacuum, nil, llm, &cfgpkg.Config{})
+	if err != nil { t.Fatalf("NewAgent err: %v", err) }
+	op := ag.(*OperativoAgent)
+	op.MCPQ = newPlanMCP()
+	if _, err := ag.AnalyzeTask(context.Background(), &entities.Task{TargetQuery: "SELECT id FROM orders"}, "fork-1"); err != nil { t.Fatalf("AnalyzeTask err: %v", err) }
+	if _, err := ag.ProposeOptimization(context.Background(), AnalysisResult{}, "fork-1"); err == nil { t.Fatalf("expected validation error for empty proposal") }
+	if llm.systems[0] != "You are a VACUUM and statistics specialist." || !strings.HasPrefix(llm.prompts[0], "Check for stale statistics and bloat.") {
+		t.Fatalf("kind prompts not used: %q / %q", llm.systems[0], llm.prompts[0])
+	}
+	// Fields left empty in the profile fall back to the implementation defaults
+	if llm.systems[1] != operativoPrompts.ProposalSystem { t.Fatalf("expected default proposal system prompt, got %q", llm.systems[1]) }
+}
@@ -0,0 +1,83 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+// proposalRelationPatterns capture the relations a proposal creates or modifies.
+var proposalRelationPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\bON\s+(?:ONLY\s+)?([\w."]+)\s*(?:USING|\()`),
+	regexp.MustCompile(`(?i)\bCREATE\s+(?:UNLOGGED\s+)?(?:MATERIALIZED\s+VIEW|TABLE)\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`),
+	regexp.MustCompile(`(?i)\bALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)`),
+}
+
+// affectedRelations merges the relations read by the baseline plan with those named by the proposal SQL.
+func affectedRelations(plan *ExplainOutput, proposal *entities.OptimizationProposal) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[strings.ToLower(name)] { return }
+		seen[strings.ToLower(name)] = true
+		out = append(out, name)
+	}
+	for _, r := range plan.Relations() { add(r) }
+	if proposal != nil {
+		for _, stmt := range proposal.SQLCommands {
+			for _, re := range proposalRelationPatterns {
+				for _, m := range re.FindAllStringSubmatch(stmt, -1) { add(m[1]) }
+			}
+		}
+	}
+	return out
+}
+
+// relationSizes returns the summed pg_total_relation_size and pg_indexes_size of relations, in bytes.
+// Relations that do not exist (yet) count as zero.
+func relationSizes(ctx context.Context, q mcpQueryPort, forkID string, relations []string) (total, indexes int64, err error) {
+	if len(relations) == 0 { return 0, 0, nil }
+	quoted := make([]string, 0, len(relations))
+	for _, r := range relations { quoted = append(quoted, quoteLiteral(r)) }
+	sizeSQL := fmt.Sprintf(`SELECT COALESCE(SUM(pg_total_relation_size(to_regclass(r))), 0)::float8 AS total_bytes,
+		COALESCE(SUM(pg_indexes_size(to_regclass(r))), 0)::float8 AS index_bytes
+		FROM unnest(ARRAY[%s]::text[]) AS r`, strings.Join(quoted, ","))
+	res, err := q.ExecuteQuery(ctx, forkID, sizeSQL, catalogTimeoutMs)
+	if err != nil { return 0, 0, err }
+	if len(res.Rows) == 0 { return 0, 0, nil }
+	return int64(rowFloat(res.Rows[0], "total_bytes")), int64(rowFloat(res.Rows[0], "index_bytes")), nil
+}
+
+// storageSnapshot records sizes before applying a proposal so the delta can be taken afterwards.
+type storageSnapshot struct {
+	relations []string
+	total     int64
+	indexes   int64
+}
+
+// snapshotStorage is best-effort: a failed size lookup yields nil and the
+// benchmark proceeds without a measured storage delta.
+func snapshotStorage(ctx context.Context, q mcpQueryPort, forkID string, relations []string) *storageSnapshot {
+	if len(relations) == 0 { return nil }
+	total, idx, err := relationSizes(ctx, q, forkID, relations)
+	if err != nil { return nil }
+	return &storageSnapshot{relations: relations, total: total, indexes: idx}
+}
+
+// delta measures the same relations again and returns the storage change.
+func (s *storageSnapshot) delta(ctx context.Context, q mcpQueryPort, forkID string) *entities.StorageDelta {
+	if s == nil { return nil }
+	total, idx, err := relationSizes(ctx, q, forkID, s.relations)
+	if err != nil { return nil }
+	return &entities.StorageDelta{
+		Relations:        s.relations,
+		TotalBytesBefore: s.total,
+		TotalBytesAfter:  total,
+		IndexBytesBefore: s.indexes,
+		IndexBytesAfter:  idx,
+	}
+}
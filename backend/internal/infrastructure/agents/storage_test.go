@@ -0,0 +1,58 @@
+package agents
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// growingMCP reports relation sizes that grow once the proposal's CREATE statement has run.
+type growingMCP struct {
+	applied  bool
+	sizeSQLs []string
+}
+
+func (m *growingMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	switch {
+	case strings.HasPrefix(sql, "CREATE"):
+		m.applied = true
+	case strings.Contains(sql, "pg_total_relation_size"):
+		m.sizeSQLs = append(m.sizeSQLs, sql)
+		row := map[string]any{"total_bytes": 80.0 * 1024 * 1024, "index_bytes": 8.0 * 1024 * 1024}
+		if m.applied { row = map[string]any{"total_bytes": []byte("89128960"), "index_bytes": []byte("13631488")} }
+		return mcp.QueryResult{Rows: []map[string]any{row}, RowCount: 1, ExecutionTimeMs: 1}, nil
+	case strings.HasPrefix(sql, "EXPLAIN"):
+		return mcp.QueryResult{Rows: []map[string]any{{"QUERY PLAN": []byte(samplePlan)}}, RowCount: 1, ExecutionTimeMs: 1}, nil
+	}
+	return mcp.QueryResult{ExecutionTimeMs: 1}, nil
+}
+
+func TestAffectedRelations(t *testing.T) {
+	plan, _ := ParseExplainJSON([]byte(samplePlan))
+	prop := &entities.OptimizationProposal{SQLCommands: []string{
+		"CREATE INDEX CONCURRENTLY idx_orders_status ON public.orders USING btree (status)",
+		"CREATE MATERIALIZED VIEW IF NOT EXISTS mv_completed AS SELECT * FROM orders WHERE status='completed'",
+		"ALTER TABLE users ADD COLUMN order_count int",
+	}}
+	got := affectedRelations(plan, prop)
+	want := []string{"orders", "public.orders", "mv_completed", "users"}
+	if !reflect.DeepEqual(got, want) { t.Fatalf("expected %v, got %v", want, got) }
+}
+
+func TestBenchmarkProposal_MeasuresStorageDelta(t *testing.T) {
+	m := &growingMCP{}
+	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX idx_orders_status ON orders(status)"}}
+	res, err := BenchmarkProposal(context.Background(), m, prop, "fork-1", "SELECT * FROM orders WHERE status='completed'", DefaultBenchmarkOptions())
+	if err != nil { t.Fatalf("BenchmarkProposal err: %v", err) }
+	if len(m.sizeSQLs) != 2 || !strings.Contains(m.sizeSQLs[0], "'orders'") { t.Fatalf("expected two size snapshots of orders, got %v", m.sizeSQLs) }
+	if res[0].StorageImpactMB != 0 || res[0].Stats.Storage != nil { t.Fatalf("baseline must not carry a storage delta: %+v", res[0]) }
+	for _, r := range res[1:] {
+		d := r.Stats.Storage
+		if d == nil || d.IndexBytesAfter-d.IndexBytesBefore != 5*1024*1024 { t.Fatalf("unexpected storage delta: %+v", d) }
+		if r.StorageImpactMB != 5 { t.Fatalf("expected 5MB impact, got %v", r.StorageImpactMB) }
+	}
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+type PostgresAgentAttemptRepository struct{ db *sqlx.DB }
+
+func NewPostgresAgentAttemptRepository(db *sqlx.DB) domainif.AgentAttemptRepository {
+	return &PostgresAgentAttemptRepository{db: db}
+}
+
+type attemptRow struct {
+	ID               int64          `db:"id"`
+	AgentExecutionID int64          `db:"agent_execution_id"`
+	Attempt          int            `db:"attempt"`
+	Candidate        int            `db:"candidate"`
+	ProposalType     sql.NullString `db:"proposal_type"`
+	SQLCommands      pq.StringArray `db:"sql_commands"`
+	Outcome          string         `db:"outcome"`
+	Feedback         sql.NullString `db:"feedback"`
+	ImprovementPct   float64        `db:"improvement_pct"`
+	CreatedAt        time.Time      `db:"created_at"`
+}
+
+func (r *PostgresAgentAttemptRepository) Create(ctx context.Context, a *entities.AgentAttempt) error {
+	if r.db == nil { return errors.New("nil db") }
+	if err := a.Validate(); err != nil { return err }
+	var ptype, feedback sql.NullString
+	if a.ProposalType != "" { ptype = sql.NullString{String: string(a.ProposalType), Valid: true} }
+	if a.Feedback != "" { feedback = sql.NullString{String: a.Feedback, Valid: true} }
+	cmds := a.SQLCommands
+	if cmds == nil { cmds = []string{} }
+	var created interface{}
+	if !a.CreatedAt.IsZero() { created = a.CreatedAt }
+	q := `INSERT INTO agent_attempts (agent_execution_id, attempt, candidate, proposal_type, sql_commands, outcome, feedback, improvement_pct, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8, COALESCE($9, NOW()))
+		RETURNING id, created_at`
+	return r.db.QueryRowxContext(ctx, q,
+		a.AgentExecutionID,
+		a.Attempt,
+		a.Candidate,
+		ptype,
+		pq.Array(cmds),
+		string(a.Outcome),
+		feedback,
+		a.ImprovementPct,
+		created,
+	).Scan(&a.ID, &a.CreatedAt)
+}
+
+func (r *PostgresAgentAttemptRepository) GetByAgentExecutionID(ctx context.Context, execID int) ([]*entities.AgentAttempt, error) {
+	if r.db == nil { return nil, errors.New("nil db") }
+	q := `SELECT id, agent_execution_id, attempt, candidate, proposal_type, sql_commands, outcome, feedback, improvement_pct, created_at
+		FROM agent_attempts WHERE agent_execution_id=$1 ORDER BY attempt, candidate`
+	rows := []attemptRow{}
+	if err := r.db.SelectContext(ctx, &rows, q, execID); err != nil { return nil, err }
+	out := make([]*entities.AgentAttempt, 0, len(rows))
+	for _, rr := range rows { out = append(out, rr.toEntity()) }
+	return out, nil
+}
+
+func (r attemptRow) toEntity() *entities.AgentAttempt {
+	return &entities.AgentAttempt{
+		ID:               r.ID,
+		AgentExecutionID: r.AgentExecutionID,
+		Attempt:          r.Attempt,
+		Candidate:        r.Candidate,
+		ProposalType:     values.ProposalType(r.ProposalType.String),
+		SQLCommands:      []string(r.SQLCommands),
+		Outcome:          entities.AttemptOutcome(r.Outcome),
+		Feedback:         r.Feedback.String,
+		ImprovementPct:   r.ImprovementPct,
+		CreatedAt:        r.CreatedAt,
+	}
+}
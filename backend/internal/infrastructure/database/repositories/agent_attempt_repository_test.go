@@ -0,0 +1,33 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+)
+
+func TestAgentAttemptRepository(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	taskID := insertTaskHelper(t, db)
+	exec := &entities.AgentExecution{TaskID: taskID, AgentType: values.AgentOperativo, Status: entities.ExecutionRunning, StartedAt: time.Now().UTC()}
+	if err := NewPostgresAgentExecutionRepository(db).Create(ctx, exec); err != nil { t.Fatalf("create exec err: %v", err) }
+
+	repo := NewPostgresAgentAttemptRepository(db)
+	first := &entities.AgentAttempt{AgentExecutionID: exec.ID, Attempt: 1, Candidate: 1, ProposalType: values.ProposalIndex, SQLCommands: []string{"CREATE INDEX ON missing(col)"}, Outcome: entities.AttemptFailed, Feedback: `relation "missing" does not exist`}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Skipf("cannot insert attempt (migrations may be missing): %v", err)
+	}
+	second := &entities.AgentAttempt{AgentExecutionID: exec.ID, Attempt: 2, Candidate: 1, ProposalType: values.ProposalIndex, SQLCommands: []string{"CREATE INDEX ON orders(status)"}, Outcome: entities.AttemptAccepted, ImprovementPct: 42.5}
+	if err := repo.Create(ctx, second); err != nil { t.Fatalf("create err: %v", err) }
+
+	list, err := repo.GetByAgentExecutionID(ctx, int(exec.ID))
+	if err != nil || len(list) != 2 { t.Fatalf("list err: %v n=%d", err, len(list)) }
+	if list[0].Feedback != first.Feedback || list[1].Outcome != entities.AttemptAccepted || list[1].ImprovementPct != 42.5 {
+		t.Fatalf("unexpected attempts: %+v %+v", list[0], list[1])
+	}
+}
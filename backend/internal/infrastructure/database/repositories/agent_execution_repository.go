@@ -30,13 +30,19 @@ type agentExecRow struct {
 	StartedAt   time.Time    `db:"started_at"`
 	CompletedAt sql.NullTime `db:"completed_at"`
 	ErrorMsg    sql.NullString `db:"error_message"`
+	Phase       sql.NullString `db:"phase"`
+	DurationMS  int64        `db:"duration_ms"`
+	InputTokens int          `db:"input_tokens"`
+	OutputTokens int          `db:"output_tokens"`
 }
 
+const agentExecColumns = `id, task_id, agent_type, fork_id, status, started_at, completed_at, error_message, phase, duration_ms, input_tokens, output_tokens`
+
 func (r *PostgresAgentExecutionRepository) Create(ctx context.Context, exec *entities.AgentExecution) error {
 	if r.db == nil { return errors.New("nil db") }
 	if exec.Status == "" { exec.Status = entities.ExecutionRunning }
-	q := `INSERT INTO agent_executions (task_id, agent_type, fork_id, status, started_at, completed_at, error_message)
-		VALUES ($1,$2,$3,COALESCE($4,'running'), COALESCE($5,NOW()), $6, $7)
+	q := `INSERT INTO agent_executions (task_id, agent_type, fork_id, status, started_at, completed_at, error_message, phase, duration_ms, input_tokens, output_tokens)
+		VALUES ($1,$2,$3,COALESCE($4,'running'), COALESCE($5,NOW()), $6, $7, $8, $9, $10, $11)
 		RETURNING id, started_at`
 	var started time.Time
 	var fork sql.NullString
@@ -53,6 +59,10 @@ func (r *PostgresAgentExecutionRepository) Create(ctx context.Context, exec *ent
 		exec.StartedAt,
 		completed,
 		errMsg,
+		phaseOf(exec),
+		exec.DurationMS,
+		exec.InputTokens,
+		exec.OutputTokens,
 	).Scan(&exec.ID, &started)
 	if err != nil { return err }
 	exec.StartedAt = started
@@ -61,7 +71,7 @@ func (r *PostgresAgentExecutionRepository) Create(ctx context.Context, exec *ent
 
 func (r *PostgresAgentExecutionRepository) GetByID(ctx context.Context, id int) (*entities.AgentExecution, error) {
 	if r.db == nil { return nil, errors.New("nil db") }
-	q := `SELECT id, task_id, agent_type, fork_id, status, started_at, completed_at, error_message FROM agent_executions WHERE id=$1`
+	q := `SELECT `+agentExecColumns+` FROM agent_executions WHERE id=$1`
 	var rw agentExecRow
 	if err := r.db.GetContext(ctx, &rw, q, id); err != nil { return nil, err }
 	return rw.toEntity(), nil
@@ -69,7 +79,7 @@ func (r *PostgresAgentExecutionRepository) GetByID(ctx context.Context, id int)
 
 func (r *PostgresAgentExecutionRepository) GetByTaskID(ctx context.Context, taskID int) ([]*entities.AgentExecution, error) {
 	if r.db == nil { return nil, errors.New("nil db") }
-	q := `SELECT id, task_id, agent_type, fork_id, status, started_at, completed_at, error_message FROM agent_executions WHERE task_id=$1 ORDER BY id`
+	q := `SELECT `+agentExecColumns+` FROM agent_executions WHERE task_id=$1 ORDER BY id`
 	rows := []agentExecRow{}
 	if err := r.db.SelectContext(ctx, &rows, q, taskID); err != nil { return nil, err }
 	out := make([]*entities.AgentExecution, 0, len(rows))
@@ -79,7 +89,7 @@ func (r *PostgresAgentExecutionRepository) GetByTaskID(ctx context.Context, task
 
 func (r *PostgresAgentExecutionRepository) List(ctx context.Context) ([]*entities.AgentExecution, error) {
 	if r.db == nil { return nil, errors.New("nil db") }
-	q := `SELECT id, task_id, agent_type, fork_id, status, started_at, completed_at, error_message FROM agent_executions ORDER BY started_at DESC LIMIT 100`
+	q := `SELECT `+agentExecColumns+` FROM agent_executions ORDER BY started_at DESC LIMIT 100`
 	rows := []agentExecRow{}
 	if err := r.db.SelectContext(ctx, &rows, q); err != nil { return nil, err }
 	out := make([]*entities.AgentExecution, 0, len(rows))
@@ -96,8 +106,8 @@ func (r *PostgresAgentExecutionRepository) Update(ctx context.Context, exec *ent
 	if exec.CompletedAt != nil { completed = sql.NullTime{Time: *exec.CompletedAt, Valid: true} }
 	var errMsg sql.NullString
 	if exec.ErrorMsg != "" { errMsg = sql.NullString{String: exec.ErrorMsg, Valid: true} }
-	q := `UPDATE agent_executions SET fork_id=$1, status=$2, completed_at=$3, error_message=$4 WHERE id=$5`
-	res, err := r.db.ExecContext(ctx, q, fork, string(exec.Status), completed, errMsg, exec.ID)
+	q := `UPDATE agent_executions SET fork_id=$1, status=$2, completed_at=$3, error_message=$4, phase=$5, duration_ms=$6, input_tokens=$7, output_tokens=$8 WHERE id=$9`
+	res, err := r.db.ExecContext(ctx, q, fork, string(exec.Status), completed, errMsg, phaseOf(exec), exec.DurationMS, exec.InputTokens, exec.OutputTokens, exec.ID)
 	if err != nil { return err }
 	a, _ := res.RowsAffected()
 	if a == 0 { return sql.ErrNoRows }
@@ -112,13 +122,22 @@ func (r agentExecRow) toEntity() *entities.AgentExecution {
 	var errMsg string
 	if r.ErrorMsg.Valid { errMsg = r.ErrorMsg.String }
 	return &entities.AgentExecution{
-		ID:          r.ID,
-		TaskID:      r.TaskID,
-		AgentType:   values.AgentType(r.AgentType),
-		ForkID:      fork,
-		Status:      entities.ExecutionStatus(r.Status),
-		StartedAt:   r.StartedAt,
-		CompletedAt: completed,
-		ErrorMsg:    errMsg,
+		ID:           r.ID,
+		TaskID:       r.TaskID,
+		AgentType:    values.AgentType(r.AgentType),
+		ForkID:       fork,
+		Status:       entities.ExecutionStatus(r.Status),
+		StartedAt:    r.StartedAt,
+		CompletedAt:  completed,
+		ErrorMsg:     errMsg,
+		Phase:        entities.ExecutionPhase(r.Phase.String),
+		DurationMS:   r.DurationMS,
+		InputTokens:  r.InputTokens,
+		OutputTokens: r.OutputTokens,
 	}
 }
+
+func phaseOf(exec *entities.AgentExecution) sql.NullString {
+	if exec.Phase == "" { return sql.NullString{} }
+	return sql.NullString{String: string(exec.Phase), Valid: true}
+}
@@ -35,10 +35,16 @@ func TestAgentExecutionRepository(t *testing.T) {
 	list, err := repo.GetByTaskID(ctx, int(exec.TaskID))
 	if err != nil || len(list) == 0 { t.Fatalf("list err: %v n=%d", err, len(list)) }
 
-	got.Status = entities.ExecutionCompleted
+	got.Status = entities.ExecutionFailed
 	now := time.Now().UTC()
 	got.CompletedAt = &now
+	got.ErrorMsg, got.Phase, got.DurationMS, got.InputTokens, got.OutputTokens = "vertex: http error 429", entities.PhaseProposal, 1500, 1200, 300
 	if err := repo.Update(ctx, got); err != nil { t.Fatalf("update err: %v", err) }
+	got, err = repo.GetByID(ctx, int(exec.ID))
+	if err != nil { t.Fatalf("get err: %v", err) }
+	if got.Status != entities.ExecutionFailed || got.Phase != entities.PhaseProposal || got.DurationMS != 1500 || got.InputTokens != 1200 || got.OutputTokens != 300 {
+		t.Fatalf("expected the outcome persisted, got %+v", got)
+	}
 }
 
 // helper: insert a minimal task row and return ID
@@ -28,6 +28,7 @@ type benchmarkRow struct {
 	RowsReturned    sql.NullInt64   `db:"rows_returned"`
 	ExplainPlan     json.RawMessage `db:"explain_plan"`
 	StorageImpactMB sql.NullFloat64 `db:"storage_impact_mb"`
+	Stats           []byte          `db:"stats"`
 	CreatedAt       time.Time       `db:"created_at"`
 }
 
@@ -40,8 +41,13 @@ func (r *PostgresBenchmarkRepository) Create(ctx context.Context, b *entities.Be
 	if b.RowsReturned != 0 { rows = sql.NullInt64{Int64: b.RowsReturned, Valid: true} }
 	var storage sql.NullFloat64
 	if b.StorageImpactMB != 0 { storage = sql.NullFloat64{Float64: b.StorageImpactMB, Valid: true} }
-	q := `INSERT INTO benchmark_results (proposal_id, query_name, query_executed, execution_time_ms, rows_returned, explain_plan, storage_impact_mb, created_at)
-		VALUES ($1,$2,$3,$4,$5,$6,$7, COALESCE($8, NOW()))
+	var stats []byte
+	if b.Stats.Iterations > 0 {
+		stats, err = json.Marshal(b.Stats)
+		if err != nil { return err }
+	}
+	q := `INSERT INTO benchmark_results (proposal_id, query_name, query_executed, execution_time_ms, rows_returned, explain_plan, storage_impact_mb, stats, created_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8, COALESCE($9, NOW()))
 		RETURNING id, created_at`
 	err = r.db.QueryRowxContext(ctx, q,
 		b.ProposalID,
@@ -51,6 +57,7 @@ func (r *PostgresBenchmarkRepository) Create(ctx context.Context, b *entities.Be
 		rows,
 		json.RawMessage(plan),
 		storage,
+		stats,
 		b.CreatedAt,
 	).Scan(&b.ID, &b.CreatedAt)
 	return err
@@ -58,7 +65,7 @@ func (r *PostgresBenchmarkRepository) Create(ctx context.Context, b *entities.Be
 
 func (r *PostgresBenchmarkRepository) GetByProposalID(ctx context.Context, proposalID int) ([]*entities.BenchmarkResult, error) {
 	if r.db == nil { return nil, errors.New("nil db") }
-	q := `SELECT id, proposal_id, query_name, query_executed, execution_time_ms, rows_returned, explain_plan, storage_impact_mb, created_at
+	q := `SELECT id, proposal_id, query_name, query_executed, execution_time_ms, rows_returned, explain_plan, storage_impact_mb, stats, created_at
 		FROM benchmark_results WHERE proposal_id=$1 ORDER BY id`
 	rows := []benchmarkRow{}
 	if err := r.db.SelectContext(ctx, &rows, q, proposalID); err != nil { return nil, err }
@@ -75,6 +82,10 @@ func (br benchmarkRow) toEntity() (*entities.BenchmarkResult, error) {
 	if len(br.ExplainPlan) > 0 {
 		if err := json.Unmarshal(br.ExplainPlan, &plan); err != nil { return nil, err }
 	}
+	var stats entities.BenchmarkStats
+	if len(br.Stats) > 0 {
+		if err := json.Unmarshal(br.Stats, &stats); err != nil { return nil, err }
+	}
 	var rows int64
 	if br.RowsReturned.Valid { rows = br.RowsReturned.Int64 }
 	var storage float64
@@ -88,6 +99,7 @@ func (br benchmarkRow) toEntity() (*entities.BenchmarkResult, error) {
 		RowsReturned:    rows,
 		ExplainPlan:     plan,
 		StorageImpactMB: storage,
+		Stats:           stats,
 		CreatedAt:       br.CreatedAt,
 	}, nil
 }
@@ -11,7 +11,6 @@ import (
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
 	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
-	"github.com/tuusuario/afs-challenge/internal/domain/values"
 )
 
 type PostgresConsensusRepository struct{ db *sqlx.DB }
@@ -27,25 +26,29 @@ type consensusRow struct {
 	AllScores         json.RawMessage `db:"all_scores"`
 	DecisionRationale sql.NullString  `db:"decision_rationale"`
 	AppliedToMain     bool            `db:"applied_to_main"`
+	ApplyReport       []byte          `db:"apply_report"`
 	CreatedAt         time.Time       `db:"created_at"`
 }
 
 func (r *PostgresConsensusRepository) Create(ctx context.Context, d *entities.ConsensusDecision) error {
 	if r.db == nil { return errors.New("nil db") }
-	scores := marshalScores(d.AllScores)
+	scores := marshalScores(d.ProposalScores)
 	var win sql.NullInt64
 	if d.WinningProposalID != nil { win = sql.NullInt64{Int64: *d.WinningProposalID, Valid: true} }
 	var rationale sql.NullString
 	if d.DecisionRationale != "" { rationale = sql.NullString{String: d.DecisionRationale, Valid: true} }
-	q := `INSERT INTO consensus_decisions (task_id, winning_proposal_id, all_scores, decision_rationale, applied_to_main, created_at)
-		VALUES ($1,$2,$3,$4, $5, COALESCE($6, NOW()))
+	report, err := marshalApplyReport(d.ApplyReport)
+	if err != nil { return err }
+	q := `INSERT INTO consensus_decisions (task_id, winning_proposal_id, all_scores, decision_rationale, applied_to_main, apply_report, created_at)
+		VALUES ($1,$2,$3,$4, $5, $6, COALESCE($7, NOW()))
 		RETURNING id, created_at`
-	err := r.db.QueryRowxContext(ctx, q,
+	err = r.db.QueryRowxContext(ctx, q,
 		d.TaskID,
 		win,
 		scores,
 		rationale,
 		d.AppliedToMain,
+		report,
 		d.CreatedAt,
 	).Scan(&d.ID, &d.CreatedAt)
 	return err
@@ -53,7 +56,7 @@ func (r *PostgresConsensusRepository) Create(ctx context.Context, d *entities.Co
 
 func (r *PostgresConsensusRepository) GetByTaskID(ctx context.Context, taskID int) (*entities.ConsensusDecision, error) {
 	if r.db == nil { return nil, errors.New("nil db") }
-	q := `SELECT id, task_id, winning_proposal_id, all_scores, decision_rationale, applied_to_main, created_at FROM consensus_decisions WHERE task_id=$1`
+	q := `SELECT id, task_id, winning_proposal_id, all_scores, decision_rationale, applied_to_main, apply_report, created_at FROM consensus_decisions WHERE task_id=$1`
 	var row consensusRow
 	if err := r.db.GetContext(ctx, &row, q, taskID); err != nil { return nil, err }
 	return row.toEntity()
@@ -62,13 +65,15 @@ func (r *PostgresConsensusRepository) GetByTaskID(ctx context.Context, taskID in
 func (r *PostgresConsensusRepository) Update(ctx context.Context, d *entities.ConsensusDecision) error {
 	if r.db == nil { return errors.New("nil db") }
 	if d.ID == 0 { return errors.New("missing id") }
-	scores := marshalScores(d.AllScores)
+	scores := marshalScores(d.ProposalScores)
 	var win sql.NullInt64
 	if d.WinningProposalID != nil { win = sql.NullInt64{Int64: *d.WinningProposalID, Valid: true} }
 	var rationale sql.NullString
 	if d.DecisionRationale != "" { rationale = sql.NullString{String: d.DecisionRationale, Valid: true} }
-	q := `UPDATE consensus_decisions SET winning_proposal_id=$1, all_scores=$2, decision_rationale=$3, applied_to_main=$4 WHERE id=$5`
-	_, err := r.db.ExecContext(ctx, q, win, scores, rationale, d.AppliedToMain, d.ID)
+	report, err := marshalApplyReport(d.ApplyReport)
+	if err != nil { return err }
+	q := `UPDATE consensus_decisions SET winning_proposal_id=$1, all_scores=$2, decision_rationale=$3, applied_to_main=$4, apply_report=$5 WHERE id=$6`
+	_, err = r.db.ExecContext(ctx, q, win, scores, rationale, d.AppliedToMain, report, d.ID)
 	return err
 }
 
@@ -77,28 +82,39 @@ func (r consensusRow) toEntity() (*entities.ConsensusDecision, error) {
 	if r.WinningProposalID.Valid { v := r.WinningProposalID.Int64; win = &v }
 	m, err := unmarshalScores(r.AllScores)
 	if err != nil { return nil, err }
+	var report *entities.ApplyReport
+	if len(r.ApplyReport) > 0 {
+		report = &entities.ApplyReport{}
+		if err := json.Unmarshal(r.ApplyReport, report); err != nil { return nil, err }
+	}
 	return &entities.ConsensusDecision{
 		ID:                r.ID,
 		TaskID:            r.TaskID,
 		WinningProposalID: win,
-		AllScores:         m,
+		ProposalScores:    m,
 		DecisionRationale: r.DecisionRationale.String,
 		AppliedToMain:     r.AppliedToMain,
+		ApplyReport:       report,
 		CreatedAt:         r.CreatedAt,
 	}, nil
 }
 
-func marshalScores(m map[values.AgentType]entities.ProposalScore) json.RawMessage {
+// marshalScores stores the scores as an object keyed by proposal ID.
+func marshalScores(m map[int64]entities.ProposalScore) json.RawMessage {
 	if m == nil { return json.RawMessage([]byte(`{}`)) }
 	b, _ := json.Marshal(m)
 	return json.RawMessage(b)
 }
 
-func unmarshalScores(b []byte) (map[values.AgentType]entities.ProposalScore, error) {
-	if len(b) == 0 { return map[values.AgentType]entities.ProposalScore{}, nil }
-	var tmp map[string]entities.ProposalScore
-	if err := json.Unmarshal(b, &tmp); err != nil { return nil, err }
-	out := make(map[values.AgentType]entities.ProposalScore, len(tmp))
-	for k, v := range tmp { out[values.AgentType(k)] = v }
+// marshalApplyReport returns NULL for decisions that were not applied.
+func marshalApplyReport(r *entities.ApplyReport) ([]byte, error) {
+	if r == nil { return nil, nil }
+	return json.Marshal(r)
+}
+
+func unmarshalScores(b []byte) (map[int64]entities.ProposalScore, error) {
+	out := map[int64]entities.ProposalScore{}
+	if len(b) == 0 { return out, nil }
+	if err := json.Unmarshal(b, &out); err != nil { return nil, err }
 	return out, nil
 }
@@ -7,7 +7,6 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
-	"github.com/tuusuario/afs-challenge/internal/domain/values"
 )
 
 func TestConsensusRepository(t *testing.T) {
@@ -25,8 +24,8 @@ func TestConsensusRepository(t *testing.T) {
 	// Create decision without winner initially
 	dec := &entities.ConsensusDecision{
 		TaskID: taskID,
-		AllScores: map[values.AgentType]entities.ProposalScore{
-			values.AgentCerebro: {ProposalID: propID, Performance: 90, Storage: 80, Complexity: 85, Risk: 88},
+		ProposalScores: map[int64]entities.ProposalScore{
+			propID: {ProposalID: propID, AgentExecutionID: execID, Performance: 90, Storage: 80, Complexity: 85, Risk: 88},
 		},
 		DecisionRationale: "initial",
 		AppliedToMain:     false,
@@ -38,7 +37,7 @@ func TestConsensusRepository(t *testing.T) {
 	got, err := repo.GetByTaskID(ctx, int(taskID))
 	if err != nil { t.Fatalf("get err: %v", err) }
 	if got.TaskID != taskID { t.Fatalf("taskID mismatch") }
-	if len(got.AllScores) == 0 { t.Fatalf("expected scores") }
+	if s := got.ProposalScores[propID]; s.ProposalID != propID || s.AgentExecutionID != execID { t.Fatalf("expected the score keyed by proposal and linked to its agent, got %+v", got.ProposalScores) }
 
 	// Update: set winner and applied flag
 	got.DecisionRationale = "final"
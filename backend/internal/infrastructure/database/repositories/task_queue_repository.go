@@ -0,0 +1,177 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
+)
+
+// PostgresTaskQueue implements TaskQueue on the task_jobs table. Workers claim jobs with
+// SELECT ... FOR UPDATE SKIP LOCKED, so concurrent workers (in one or several servers) never
+// take the same job, and hold them through a lease they extend with heartbeats.
+type PostgresTaskQueue struct{ db *sqlx.DB }
+
+func NewPostgresTaskQueue(db *sqlx.DB) domainif.TaskQueue {
+	return &PostgresTaskQueue{db: db}
+}
+
+type taskJobRow struct {
+	ID          int64          `db:"id"`
+	TaskID      int64          `db:"task_id"`
+	Status      string         `db:"status"`
+	Attempts    int            `db:"attempts"`
+	MaxAttempts int            `db:"max_attempts"`
+	RunAfter    time.Time      `db:"run_after"`
+	LockedBy    sql.NullString `db:"locked_by"`
+	LeaseUntil  sql.NullTime   `db:"lease_until"`
+	HeartbeatAt sql.NullTime   `db:"heartbeat_at"`
+	LastError   sql.NullString `db:"last_error"`
+	CreatedAt   time.Time      `db:"created_at"`
+	UpdatedAt   time.Time      `db:"updated_at"`
+}
+
+const taskJobColumns = `id, task_id, status, attempts, max_attempts, run_after, locked_by, lease_until, heartbeat_at, last_error, created_at, updated_at`
+
+func (r taskJobRow) toEntity() *entities.TaskJob {
+	j := &entities.TaskJob{
+		ID:          r.ID,
+		TaskID:      r.TaskID,
+		Status:      entities.JobStatus(r.Status),
+		Attempts:    r.Attempts,
+		MaxAttempts: r.MaxAttempts,
+		RunAfter:    r.RunAfter,
+		LockedBy:    r.LockedBy.String,
+		LastError:   r.LastError.String,
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+	if r.LeaseUntil.Valid { j.LeaseUntil = &r.LeaseUntil.Time }
+	if r.HeartbeatAt.Valid { j.HeartbeatAt = &r.HeartbeatAt.Time }
+	return j
+}
+
+func (q *PostgresTaskQueue) Enqueue(ctx context.Context, taskID int64, maxAttempts int) error {
+	if q.db == nil { return errors.New("nil db") }
+	if maxAttempts <= 0 { maxAttempts = 1 }
+	_, err := q.db.ExecContext(ctx, `INSERT INTO task_jobs (task_id, max_attempts) VALUES ($1, $2)
+		ON CONFLICT (task_id) DO UPDATE SET status = 'queued', attempts = 0, max_attempts = EXCLUDED.max_attempts,
+			run_after = NOW(), locked_by = NULL, lease_until = NULL, last_error = NULL, updated_at = NOW()
+		WHERE task_jobs.status IN ('done', 'failed')`, taskID, maxAttempts)
+	return err
+}
+
+func (q *PostgresTaskQueue) Claim(ctx context.Context, workerID string, lease time.Duration) (*entities.TaskJob, error) {
+	if q.db == nil { return nil, errors.New("nil db") }
+	var row taskJobRow
+	err := q.db.QueryRowxContext(ctx, `UPDATE task_jobs SET status = 'running', attempts = attempts + 1, locked_by = $1,
+			lease_until = NOW() + $2 * interval '1 millisecond', heartbeat_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM task_jobs WHERE status = 'queued' AND run_after <= NOW()
+			ORDER BY run_after, id FOR UPDATE SKIP LOCKED LIMIT 1)
+		RETURNING `+taskJobColumns, workerID, lease.Milliseconds()).StructScan(&row)
+	if errors.Is(err, sql.ErrNoRows) { return nil, nil }
+	if err != nil { return nil, err }
+	return row.toEntity(), nil
+}
+
+func (q *PostgresTaskQueue) Heartbeat(ctx context.Context, jobID int64, workerID string, lease time.Duration) error {
+	var taskStatus sql.NullString
+	err := q.db.QueryRowxContext(ctx, `UPDATE task_jobs SET lease_until = NOW() + $3 * interval '1 millisecond', heartbeat_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+		RETURNING (SELECT t.status FROM tasks t WHERE t.id = task_jobs.task_id)`, jobID, workerID, lease.Milliseconds()).Scan(&taskStatus)
+	if errors.Is(err, sql.ErrNoRows) { return entities.ErrJobLeaseLost }
+	if err != nil { return err }
+	// Cancelled through another server: that server cannot reach this worker's context.
+	if taskStatus.String == string(entities.TaskStatusCancelled) { return entities.ErrJobCancelled }
+	return nil
+}
+
+func (q *PostgresTaskQueue) Complete(ctx context.Context, jobID int64, workerID string) error {
+	return q.owned(q.db.ExecContext(ctx, `UPDATE task_jobs SET status = 'done', locked_by = NULL, lease_until = NULL, last_error = NULL, updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'`, jobID, workerID))
+}
+
+func (q *PostgresTaskQueue) Fail(ctx context.Context, jobID int64, workerID, reason string, retryAfter time.Duration) error {
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil { return err }
+	defer tx.Rollback()
+	var taskID int64
+	var status string
+	err = tx.QueryRowxContext(ctx, `UPDATE task_jobs SET status = CASE WHEN attempts < max_attempts THEN 'queued' ELSE 'failed' END,
+			run_after = NOW() + $4 * interval '1 millisecond', locked_by = NULL, lease_until = NULL, last_error = $3, updated_at = NOW()
+		WHERE id = $1 AND locked_by = $2 AND status = 'running'
+		RETURNING task_id, status`, jobID, workerID, reason, retryAfter.Milliseconds()).Scan(&taskID, &status)
+	if errors.Is(err, sql.ErrNoRows) { return entities.ErrJobLeaseLost }
+	if err != nil { return err }
+	if status == string(entities.JobFailed) {
+		if err := failUnfinishedTasks(ctx, tx, []int64{taskID}); err != nil { return err }
+	}
+	return tx.Commit()
+}
+
+func (q *PostgresTaskQueue) Recover(ctx context.Context, maxAttempts int) (int, error) {
+	if q.db == nil { return 0, errors.New("nil db") }
+	tx, err := q.db.BeginTxx(ctx, nil)
+	if err != nil { return 0, err }
+	defer tx.Rollback()
+
+	// Leases that expired belong to workers that died: queue again, or fail on the last attempt.
+	rows, err := tx.QueryxContext(ctx, `UPDATE task_jobs SET status = CASE WHEN attempts < max_attempts THEN 'queued' ELSE 'failed' END,
+			run_after = NOW(), locked_by = NULL, lease_until = NULL,
+			last_error = 'lease of worker ' || COALESCE(locked_by, '?') || ' expired', updated_at = NOW()
+		WHERE status = 'running' AND lease_until < NOW()
+		RETURNING task_id, status`)
+	if err != nil { return 0, err }
+	recovered := 0
+	var exhausted []int64
+	for rows.Next() {
+		var taskID int64
+		var status string
+		if err := rows.Scan(&taskID, &status); err != nil { rows.Close(); return 0, err }
+		if status == string(entities.JobFailed) { exhausted = append(exhausted, taskID) } else { recovered++ }
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil { return 0, err }
+	if err := failUnfinishedTasks(ctx, tx, exhausted); err != nil { return 0, err }
+
+	// Tasks that never got a job (created before the queue, or the enqueue failed).
+	if maxAttempts <= 0 { maxAttempts = 1 }
+	res, err := tx.ExecContext(ctx, `INSERT INTO task_jobs (task_id, max_attempts)
+		SELECT t.id, $1 FROM tasks t
+		WHERE t.status IN ('pending', 'in_progress') AND NOT EXISTS (SELECT 1 FROM task_jobs j WHERE j.task_id = t.id)
+		ON CONFLICT (task_id) DO NOTHING`, maxAttempts)
+	if err != nil { return 0, err }
+	if n, err := res.RowsAffected(); err == nil { recovered += int(n) }
+
+	// Approved tasks whose job finished when they went to awaiting_approval and were never queued again.
+	res, err = tx.ExecContext(ctx, `UPDATE task_jobs j SET status = 'queued', attempts = 0, max_attempts = $1, run_after = NOW(),
+			locked_by = NULL, lease_until = NULL, last_error = NULL, updated_at = NOW()
+		FROM tasks t WHERE t.id = j.task_id AND t.status = 'in_progress' AND j.status = 'done'`, maxAttempts)
+	if err != nil { return 0, err }
+	if n, err := res.RowsAffected(); err == nil { recovered += int(n) }
+	return recovered, tx.Commit()
+}
+
+// failUnfinishedTasks marks tasks whose job gave up as failed, unless they already finished.
+func failUnfinishedTasks(ctx context.Context, tx *sqlx.Tx, taskIDs []int64) error {
+	for _, id := range taskIDs {
+		if _, err := tx.ExecContext(ctx, `UPDATE tasks SET status = 'failed' WHERE id = $1 AND status IN ('pending', 'in_progress')`, id); err != nil {
+			return fmt.Errorf("fail task %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// owned maps an update that matched no row to ErrJobLeaseLost.
+func (q *PostgresTaskQueue) owned(res sql.Result, err error) error {
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err == nil && n == 0 { return entities.ErrJobLeaseLost }
+	return nil
+}
@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+func TestTaskQueue_ClaimRetryAndRecover(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	taskID := insertTaskHelper(t, db)
+	q := NewPostgresTaskQueue(db)
+	if err := q.Enqueue(ctx, taskID, 2); err != nil {
+		t.Skipf("cannot enqueue (migrations may be missing): %v", err)
+	}
+	defer db.Exec(`DELETE FROM task_jobs WHERE task_id = $1`, taskID)
+	// Only this test's job is due: push every other queued job into the future while it runs.
+	db.Exec(`UPDATE task_jobs SET run_after = run_after + interval '1 hour' WHERE task_id <> $1 AND status = 'queued'`, taskID)
+	defer db.Exec(`UPDATE task_jobs SET run_after = run_after - interval '1 hour' WHERE task_id <> $1 AND status = 'queued'`, taskID)
+
+	job, err := q.Claim(ctx, "w1", time.Minute)
+	if err != nil || job == nil || job.TaskID != taskID || job.Attempts != 1 || job.Status != entities.JobRunning {
+		t.Fatalf("claim: %+v %v", job, err)
+	}
+	if other, err := q.Claim(ctx, "w2", time.Minute); err != nil || other != nil {
+		t.Fatalf("expected nothing left to claim, got %+v %v", other, err)
+	}
+	if err := q.Heartbeat(ctx, job.ID, "w2", time.Minute); !errors.Is(err, entities.ErrJobLeaseLost) {
+		t.Errorf("expected a foreign heartbeat to be refused, got %v", err)
+	}
+	if err := q.Fail(ctx, job.ID, "w1", "boom", 0); err != nil { t.Fatalf("fail: %v", err) }
+
+	// Second and last attempt: the worker dies, its lease expires and recovery fails the task.
+	job, err = q.Claim(ctx, "w1", time.Millisecond)
+	if err != nil || job == nil || job.Attempts != 2 { t.Fatalf("reclaim: %+v %v", job, err) }
+	time.Sleep(20 * time.Millisecond)
+	if _, err := q.Recover(ctx, 2); err != nil { t.Fatalf("recover: %v", err) }
+	var jobStatus, taskStatus string
+	db.QueryRowx(`SELECT j.status, t.status FROM task_jobs j JOIN tasks t ON t.id = j.task_id WHERE j.task_id = $1`, taskID).Scan(&jobStatus, &taskStatus)
+	if jobStatus != string(entities.JobFailed) || taskStatus != string(entities.TaskStatusFailed) {
+		t.Errorf("expected job and task failed after the last lease expired, got %s/%s", jobStatus, taskStatus)
+	}
+}
+
+func TestTaskQueue_RecoverRequeuesApprovedTask(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	taskID := insertTaskHelper(t, db)
+	q := NewPostgresTaskQueue(db)
+	if err := q.Enqueue(ctx, taskID, 2); err != nil {
+		t.Skipf("cannot enqueue (migrations may be missing): %v", err)
+	}
+	defer db.Exec(`DELETE FROM task_jobs WHERE task_id = $1`, taskID)
+
+	// The job finished when the task went to awaiting_approval; the approval moved it back to in_progress.
+	db.Exec(`UPDATE task_jobs SET status = 'done', attempts = 1 WHERE task_id = $1`, taskID)
+	db.Exec(`UPDATE tasks SET status = 'in_progress' WHERE id = $1`, taskID)
+	if _, err := q.Recover(ctx, 2); err != nil { t.Fatalf("recover: %v", err) }
+	var jobStatus string
+	var attempts int
+	db.QueryRowx(`SELECT status, attempts FROM task_jobs WHERE task_id = $1`, taskID).Scan(&jobStatus, &attempts)
+	if jobStatus != string(entities.JobQueued) || attempts != 0 {
+		t.Errorf("expected the approved task queued again, got %s after %d attempts", jobStatus, attempts)
+	}
+}
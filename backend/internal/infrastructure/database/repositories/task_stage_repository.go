@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
+)
+
+type PostgresTaskStageRepository struct{ db *sqlx.DB }
+
+func NewPostgresTaskStageRepository(db *sqlx.DB) domainif.TaskStageRepository {
+	return &PostgresTaskStageRepository{db: db}
+}
+
+type stageRow struct {
+	ID         int64          `db:"id"`
+	TaskID     int64          `db:"task_id"`
+	Stage      string         `db:"stage"`
+	Status     string         `db:"status"`
+	Attempts   int            `db:"attempts"`
+	Output     []byte         `db:"output"`
+	Error      sql.NullString `db:"error"`
+	StartedAt  time.Time      `db:"started_at"`
+	FinishedAt *time.Time     `db:"finished_at"`
+}
+
+func (r *PostgresTaskStageRepository) Save(ctx context.Context, s *entities.TaskStage) error {
+	if r.db == nil { return errors.New("nil db") }
+	var output interface{}
+	if len(s.Output) > 0 { output = []byte(s.Output) }
+	var errMsg sql.NullString
+	if s.Error != "" { errMsg = sql.NullString{String: s.Error, Valid: true} }
+	var started interface{}
+	if !s.StartedAt.IsZero() { started = s.StartedAt }
+	q := `INSERT INTO task_stages (task_id, stage, status, attempts, output, error, started_at, finished_at)
+		VALUES ($1,$2,$3,$4,$5,$6, COALESCE($7, NOW()), $8)
+		ON CONFLICT (task_id, stage) DO UPDATE SET status = EXCLUDED.status, attempts = EXCLUDED.attempts,
+			output = EXCLUDED.output, error = EXCLUDED.error, started_at = EXCLUDED.started_at, finished_at = EXCLUDED.finished_at
+		RETURNING id, started_at`
+	return r.db.QueryRowxContext(ctx, q,
+		s.TaskID,
+		string(s.Stage),
+		string(s.Status),
+		s.Attempts,
+		output,
+		errMsg,
+		started,
+		s.FinishedAt,
+	).Scan(&s.ID, &s.StartedAt)
+}
+
+func (r *PostgresTaskStageRepository) GetByTaskID(ctx context.Context, taskID int) ([]*entities.TaskStage, error) {
+	if r.db == nil { return nil, errors.New("nil db") }
+	q := `SELECT id, task_id, stage, status, attempts, output, error, started_at, finished_at
+		FROM task_stages WHERE task_id=$1`
+	rows := []stageRow{}
+	if err := r.db.SelectContext(ctx, &rows, q, taskID); err != nil { return nil, err }
+	byStage := map[entities.PipelineStage]*entities.TaskStage{}
+	for _, rr := range rows { byStage[entities.PipelineStage(rr.Stage)] = rr.toEntity() }
+	out := make([]*entities.TaskStage, 0, len(rows))
+	for _, stage := range entities.PipelineStages {
+		if s, ok := byStage[stage]; ok { out = append(out, s) }
+	}
+	return out, nil
+}
+
+func (r stageRow) toEntity() *entities.TaskStage {
+	return &entities.TaskStage{
+		ID:         r.ID,
+		TaskID:     r.TaskID,
+		Stage:      entities.PipelineStage(r.Stage),
+		Status:     entities.StageStatus(r.Status),
+		Attempts:   r.Attempts,
+		Output:     r.Output,
+		Error:      r.Error.String,
+		StartedAt:  r.StartedAt,
+		FinishedAt: r.FinishedAt,
+	}
+}
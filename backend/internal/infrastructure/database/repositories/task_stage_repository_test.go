@@ -0,0 +1,37 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+func TestTaskStageRepository(t *testing.T) {
+	db := connectTestDB(t)
+	defer db.Close()
+	ctx := context.Background()
+	taskID := insertTaskHelper(t, db)
+	repo := NewPostgresTaskStageRepository(db)
+
+	forks := &entities.TaskStage{TaskID: taskID, Stage: entities.StageForks, Status: entities.StageRunning, Attempts: 1}
+	if err := repo.Save(ctx, forks); err != nil {
+		t.Skipf("cannot save stage (migrations may be missing): %v", err)
+	}
+	routing := &entities.TaskStage{TaskID: taskID, Stage: entities.StageRouting, Status: entities.StageDone, Attempts: 1, Output: json.RawMessage(`{"agents":["cerebro"]}`)}
+	if err := repo.Save(ctx, routing); err != nil { t.Fatalf("save routing: %v", err) }
+	now := time.Now().UTC()
+	forks.Status, forks.Error, forks.Attempts, forks.FinishedAt = entities.StageFailed, "no free fork", 2, &now
+	if err := repo.Save(ctx, forks); err != nil { t.Fatalf("update forks: %v", err) }
+
+	list, err := repo.GetByTaskID(ctx, int(taskID))
+	if err != nil || len(list) != 2 { t.Fatalf("list err: %v n=%d", err, len(list)) }
+	if list[0].Stage != entities.StageRouting || string(list[0].Output) != `{"agents": ["cerebro"]}` {
+		t.Errorf("expected routing first with its output, got %+v", list[0])
+	}
+	if list[1].ID != forks.ID || list[1].Status != entities.StageFailed || list[1].Attempts != 2 || list[1].Error != "no free fork" {
+		t.Errorf("expected the forks checkpoint replaced in place, got %+v", list[1])
+	}
+}
@@ -1,7 +1,10 @@
 package llm
 
+import "context"
+
+// LLMClient calls a model; ctx cancels the request (e.g. when its task is cancelled).
 type LLMClient interface {
-	SendMessage(prompt, system string) (string, error)
-	SendMessageWithJSON(prompt, system string) (map[string]interface{}, error)
-	GetUsage() (inputTokens, outputTokens int)
+	SendMessage(ctx context.Context, prompt, system string) (string, error)
+	SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error)
+	GetUsage() (inputTokens, outputTokens int) // summed over every call so far
 }
@@ -11,6 +11,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -30,7 +31,8 @@ type VertexClient struct {
 	location    string
 	model       string
 	baseURL     string
-	inputTokens int
+	usageMu     sync.Mutex
+	inputTokens int // summed over every call of the client
 	outputTokens int
 	timeout     time.Duration
 }
@@ -96,11 +98,15 @@ func normalizeModel(m string) string {
 	}
 }
 
-func (c *VertexClient) GetUsage() (int, int) { return c.inputTokens, c.outputTokens }
+func (c *VertexClient) GetUsage() (int, int) {
+	c.usageMu.Lock()
+	defer c.usageMu.Unlock()
+	return c.inputTokens, c.outputTokens
+}
 
 // SendMessage returns raw text from the model.
-func (c *VertexClient) SendMessage(prompt, system string) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+func (c *VertexClient) SendMessage(ctx context.Context, prompt, system string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	raw, err := c.invoke(ctx, prompt, system, false)
 	if err != nil { return "", err }
@@ -108,8 +114,8 @@ func (c *VertexClient) SendMessage(prompt, system string) (string, error) {
 }
 
 // SendMessageWithJSON returns parsed JSON map after removing markdown fences.
-func (c *VertexClient) SendMessageWithJSON(prompt, system string) (map[string]interface{}, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+func (c *VertexClient) SendMessageWithJSON(ctx context.Context, prompt, system string) (map[string]interface{}, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 	raw, err := c.invoke(ctx, prompt, system, true)
 	if err != nil { return nil, err }
@@ -162,8 +168,10 @@ func (c *VertexClient) invoke(ctx context.Context, prompt, system string, jsonMo
 			} `json:"usageMetadata"`
 		}
 		if err := json.NewDecoder(resp.Body).Decode(&obj); err != nil { return "", err }
-		c.inputTokens = obj.Usage.PromptTokenCount
-		c.outputTokens = obj.Usage.CandidatesTokenCount
+		c.usageMu.Lock()
+		c.inputTokens += obj.Usage.PromptTokenCount
+		c.outputTokens += obj.Usage.CandidatesTokenCount
+		c.usageMu.Unlock()
 		if len(obj.Candidates) == 0 || len(obj.Candidates[0].Content.Parts) == 0 {
 			return "", errors.New("vertex: empty candidates")
 		}
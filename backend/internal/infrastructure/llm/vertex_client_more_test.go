@@ -2,6 +2,7 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -28,7 +29,7 @@ func TestVertexClient_MultiPartCandidates_AndMissingUsage(t *testing.T) {
     }
     md := &mockDoer2{resp: &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(mustJSON(payload)))}}
     vc, _ := NewVertexClient(cfg, "gemini-2.5-pro", md)
-    m, err := vc.SendMessageWithJSON("x", "y")
+    m, err := vc.SendMessageWithJSON(context.Background(), "x", "y")
     if err != nil { t.Fatalf("unexpected err: %v", err) }
     if m["pick"] != float64(1) { t.Fatalf("expected first part pick=1, got %v", m["pick"]) }
     in, out := vc.GetUsage(); if in != 0 || out != 0 { t.Fatalf("expected zero usage, got %d %d", in, out) }
@@ -57,13 +58,13 @@ func TestVertexClient_HTTPErrorAndEmptyCandidates(t *testing.T) {
 	// HTTP error branch
 	mdErr := &mockDoer2{resp: &http.Response{StatusCode: 500, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))}}
 	vc, _ := NewVertexClient(cfg, "gemini-2.5-pro", mdErr)
-	if _, err := vc.SendMessage("x", "y"); err == nil { t.Fatalf("expected http error") }
+	if _, err := vc.SendMessage(context.Background(), "x", "y"); err == nil { t.Fatalf("expected http error") }
 
 	// Empty candidates branch
 	body := map[string]interface{}{"candidates": []interface{}{}, "usageMetadata": map[string]interface{}{"promptTokenCount":1,"candidatesTokenCount":1}}
 	mdEmpty := &mockDoer2{resp: &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(mustJSON(body)))}}
 	vc2, _ := NewVertexClient(cfg, "gemini-2.5-pro", mdEmpty)
-	if _, err := vc2.SendMessage("x", "y"); err == nil { t.Fatalf("expected empty candidates error") }
+	if _, err := vc2.SendMessage(context.Background(), "x", "y"); err == nil { t.Fatalf("expected empty candidates error") }
 }
 
 func TestVertexClient_JSONFallbackAndIntFromMap(t *testing.T) {
@@ -78,16 +79,20 @@ func TestVertexClient_JSONFallbackAndIntFromMap(t *testing.T) {
 	}
 	md := &mockDoer2{resp: &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(mustJSON(payload)))}}
 	vc, _ := NewVertexClient(cfg, "gemini-2.5-pro", md)
-	m, err := vc.SendMessageWithJSON("x", "y")
+	m, err := vc.SendMessageWithJSON(context.Background(), "x", "y")
 	if err != nil || m["ok"] != true { t.Fatalf("fallback json failed %v %v", err, m) }
 	in, out := vc.GetUsage(); if in != 12 || out != 34 { t.Fatalf("usage mismatch %d %d", in, out) }
+	// Usage adds up over the calls of the client
+	md.resp = &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader(mustJSON(payload)))}
+	if _, err := vc.SendMessageWithJSON(context.Background(), "x", "y"); err != nil { t.Fatal(err) }
+	in, out = vc.GetUsage(); if in != 24 || out != 68 { t.Fatalf("expected usage summed over calls, got %d %d", in, out) }
 }
 
 func TestVertexClient_UnsupportedModel(t *testing.T) {
 	cfg := &cfgpkg.Config{ VertexAI: struct{ ProjectID string; Location string; ModelCerebro string; ModelOperativo string; ModelBulk string; Credentials string }{ ProjectID: "p", Location: "l" } }
 	md := &mockDoer2{resp: &http.Response{StatusCode: 200, Body: ioutil.NopCloser(bytes.NewReader([]byte(`{}`)))}}
 	vc, _ := NewVertexClient(cfg, "unknown-model", md)
-	if _, err := vc.SendMessage("x", "y"); err == nil { t.Fatalf("expected unsupported model error") }
+	if _, err := vc.SendMessage(context.Background(), "x", "y"); err == nil { t.Fatalf("expected unsupported model error") }
 }
 
 func mustJSON(v interface{}) []byte { b, _ := json.Marshal(v); return b }
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -50,7 +51,7 @@ func TestVertexClient(t *testing.T) {
 	mdGeminiPro := &mockDoer{responses: []*http.Response{httpResp(200, geminiBodyPro)}}
 	gm, err := NewVertexClient(cfg, "gemini-2.5-pro", mdGeminiPro)
 	if err != nil { t.Fatalf("new gemini: %v", err) }
-	jsonMap2, err := gm.SendMessageWithJSON("please json", "sys")
+	jsonMap2, err := gm.SendMessageWithJSON(context.Background(), "please json", "sys")
 	if err != nil { t.Fatalf("gemini json parse err: %v", err) }
 	if jsonMap2["engine"] != "gemini" { t.Fatalf("unexpected json: %v", jsonMap2) }
 
@@ -66,7 +67,7 @@ func TestVertexClient(t *testing.T) {
 	mdGeminiFlash := &mockDoer{responses: []*http.Response{httpResp(200, geminiBodyFlash)}}
 	gf, err := NewVertexClient(cfg, "gemini-2.5-flash", mdGeminiFlash)
 	if err != nil { t.Fatalf("new gemini flash: %v", err) }
-	jm, err := gf.SendMessageWithJSON("please json", "sys")
+	jm, err := gf.SendMessageWithJSON(context.Background(), "please json", "sys")
 	if err != nil || jm["tier"] != "flash" { t.Fatalf("gemini flash json err: %v %v", err, jm) }
 
 	// Gemini 2.0 Flash: candidates plain JSON string
@@ -81,6 +82,6 @@ func TestVertexClient(t *testing.T) {
 	mdGemini20 := &mockDoer{responses: []*http.Response{httpResp(200, geminiBody20)}}
 	g20, err := NewVertexClient(cfg, "gemini-2.0-flash", mdGemini20)
 	if err != nil { t.Fatalf("new gemini 2.0: %v", err) }
-	jm20, err := g20.SendMessageWithJSON("please json", "sys")
+	jm20, err := g20.SendMessageWithJSON(context.Background(), "please json", "sys")
 	if err != nil || jm20["tier"] != "20" { t.Fatalf("gemini 2.0 json err: %v %v", err, jm20) }
 }
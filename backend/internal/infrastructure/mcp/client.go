@@ -3,11 +3,13 @@ package mcp
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
 	"time"
 
 	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
@@ -15,17 +17,21 @@ import (
 )
 
 // MCPClient provides access to Tiger Cloud forks via direct PostgreSQL connections.
-// Uses pre-created, immutable forks (gwb579t287, mn4o89xewb) with zero-copy PITR support.
+// Service IDs are resolved to connection URLs through its ServiceRegistry, so any
+// Tiger project and any number of pre-created forks work without code changes.
 type MCPClient struct {
 	configDir  string
 	publicKey  string
 	secretKey  string
 	projectID  string
-	fork1URL   string
-	fork2URL   string
-	mainURL    string
+	mcpURL     string
+	services   *ServiceRegistry
+	conns      *connCache
 	timeout    time.Duration
 	maxRetries int
+
+	mu      sync.Mutex
+	created map[string]bool // point-in-time forks this client created; the only ones DeleteFork removes
 }
 
 // QueryResult is a normalized subset of query results.
@@ -33,6 +39,8 @@ type QueryResult struct {
 	Rows            []map[string]any `json:"rows,omitempty"`
 	RowCount        int              `json:"row_count,omitempty"`
 	ExecutionTimeMs float64          `json:"execution_time_ms,omitempty"`
+	PlanningTimeMs  float64          `json:"planning_time_ms,omitempty"` // server-side timing modes only
+	TimingSource    string           `json:"timing_source,omitempty"`    // client, explain or pg_stat_statements
 	Command         string           `json:"command,omitempty"`
 	Message         string           `json:"message,omitempty"`
 }
@@ -53,29 +61,40 @@ func New(c *cfgpkg.Config, httpClient interface{}) (*MCPClient, error) {
 		}
 	}
 	
-	// Pre-load fork connection strings from environment
-	fork1URL := os.Getenv("TIGER_FORK_A1_SERVICE_URL")
-	fork2URL := os.Getenv("TIGER_FORK_A2_SERVICE_URL")
-	mainURL := os.Getenv("TIGER_MAIN_SERVICE_URL")
-	
 	cl := &MCPClient{
 		configDir:  configDir,
 		publicKey:  c.TigerCloud.PublicKey,
 		secretKey:  c.TigerCloud.SecretKey,
 		projectID:  c.TigerCloud.ProjectID,
-		fork1URL:   fork1URL,
-		fork2URL:   fork2URL,
-		mainURL:    mainURL,
+		mcpURL:     c.TigerCloud.MCPURL,
+		services:   NewServiceRegistry(c.Database.URL, c.TigerCloud.ServiceURLs),
+		conns:      newConnCache(poolOptionsFrom(c.Forks.MaxConnsPerService, c.Forks.ConnIdleTimeoutS, c.Forks.HealthCheckS)),
 		timeout:    30 * time.Second,
 		maxRetries: 3,
+		created:    map[string]bool{},
 	}
 	return cl, nil
 }
 
+// Services returns the registry the client resolves service IDs with.
+func (c *MCPClient) Services() *ServiceRegistry { return c.services }
+
 // Connect verifies tiger CLI is available and authenticated.
 // If credentials are provided, it runs tiger auth login to store them.
 // In production (e.g., Railway), tiger CLI may not be available - skip MCP auth.
+// With TIGER_MCP_URL set, the services listed by the MCP server are registered too.
 func (c *MCPClient) Connect(ctx context.Context) error {
+	if c.mcpURL != "" {
+		lister := NewHTTPMCPClient(c.mcpURL, c.timeout)
+		n, err := c.services.Discover(ctx, lister)
+		lister.Close()
+		if err != nil {
+			fmt.Printf("[Tiger MCP] ⚠️  service_list failed, using configured services only: %v\n", err)
+		} else {
+			fmt.Printf("[Tiger MCP] ✅ Registered %d services from service_list\n", n)
+		}
+	}
+
 	// Check if tiger CLI is available
 	statusArgs := []string{"--version"}
 	statusCmd := exec.CommandContext(ctx, "tiger", statusArgs...)
@@ -123,32 +142,17 @@ func (c *MCPClient) ExecuteQuery(ctx context.Context, serviceID, sqlQuery string
 
 // executeQueryPostgres executes query via direct PostgreSQL connection.
 func (c *MCPClient) executeQueryPostgres(ctx context.Context, serviceID, sqlQuery string, timeoutMs int) (QueryResult, error) {
-	// Get connection string for pre-created fork or main service
-	var connStr string
-	switch serviceID {
-	case "gwb579t287": // TIGER_FORK_A1_SERVICE_ID
-		connStr = c.fork1URL
-	case "mn4o89xewb": // TIGER_FORK_A2_SERVICE_ID
-		connStr = c.fork2URL
-	case "wuj5xa6zpz": // TIGER_MAIN_SERVICE_ID
-		connStr = c.mainURL
-	default:
-		// Try to match by name
-		if strings.Contains(serviceID, "agent-1") {
-			connStr = c.fork1URL
-		} else if strings.Contains(serviceID, "agent-2") {
-			connStr = c.fork2URL
-		} else if strings.Contains(serviceID, "main") {
-			connStr = c.mainURL
-		} else {
-			return QueryResult{}, fmt.Errorf("mcp: unknown fork service ID: %s", serviceID)
-		}
-	}
-	
-	if connStr == "" {
-		return QueryResult{}, fmt.Errorf("mcp: connection string not configured for fork %s", serviceID)
+	ep, err := c.services.Resolve(serviceID)
+	if err != nil {
+		return QueryResult{}, err
 	}
-	
+	return queryPostgres(ctx, c.conns, ep.ConnURL, sqlQuery, timeoutMs)
+}
+
+// queryPostgres runs sqlQuery on a pooled connection to connStr and normalizes the rows.
+// ExecutionTimeMs covers the query and row fetch only; acquiring the connection is not timed.
+// The timing mode set with WithTimingMode may replace it with server-side times.
+func queryPostgres(ctx context.Context, conns *connCache, connStr, sqlQuery string, timeoutMs int) (QueryResult, error) {
 	// Set timeout
 	queryCtx := ctx
 	if timeoutMs > 0 {
@@ -157,21 +161,33 @@ func (c *MCPClient) executeQueryPostgres(ctx context.Context, serviceID, sqlQuer
 		defer cancel()
 	}
 	
-	// Open database connection
-	db, err := sql.Open("postgres", connStr)
+	// Reuse the service pool and hold one connection for the query
+	db, err := conns.get(queryCtx, connStr)
 	if err != nil {
-		return QueryResult{}, fmt.Errorf("mcp: failed to open database: %w", err)
+		return QueryResult{}, err
 	}
-	defer db.Close()
-	
-	// Test connection
-	if err := db.PingContext(queryCtx); err != nil {
+	conn, err := db.Conn(queryCtx)
+	if err != nil {
 		return QueryResult{}, fmt.Errorf("mcp: failed to connect to database: %w", err)
 	}
+	defer conn.Close()
 	
+	switch TimingModeFrom(ctx) {
+	case TimingExplain:
+		if ReadOnlyQuery(sqlQuery) {
+			return explainTimed(queryCtx, conn, sqlQuery)
+		}
+	case TimingStatStatements:
+		return statStatementsTimed(queryCtx, conn, func() (QueryResult, error) { return runQuery(queryCtx, conn, sqlQuery) })
+	}
+	return runQuery(queryCtx, conn, sqlQuery)
+}
+
+// runQuery executes sqlQuery on conn, timing it in the client.
+func runQuery(ctx context.Context, conn *sql.Conn, sqlQuery string) (QueryResult, error) {
 	// Execute query and measure time
 	startTime := time.Now()
-	rows, err := db.QueryContext(queryCtx, sqlQuery)
+	rows, err := conn.QueryContext(ctx, sqlQuery)
 	if err != nil {
 		return QueryResult{}, fmt.Errorf("mcp: query execution failed: %w", err)
 	}
@@ -212,28 +228,83 @@ func (c *MCPClient) executeQueryPostgres(ctx context.Context, serviceID, sqlQuer
 	
 	result.RowCount = len(result.Rows)
 	result.ExecutionTimeMs = executionTime
+	result.TimingSource = string(TimingClient)
 	return result, nil
 }
 
-// CreateFork returns a pre-created fixed fork ID based on fork name.
-// Forks are permanent (never deleted), data is immutable.
+// CreateFork resolves a pre-created fork by name through the service registry.
+// Forks are permanent (never deleted), data is immutable; this client cannot create new
+// ones, so a name that matches no registered service is an error rather than a shared fork.
 func (c *MCPClient) CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error) {
-	var forkID string
-	if strings.Contains(forkName, "agent-2") || strings.Contains(forkName, "agent_2") {
-		forkID = "mn4o89xewb" // TIGER_FORK_A2_SERVICE_ID
-	} else {
-		forkID = "gwb579t287" // TIGER_FORK_A1_SERVICE_ID
+	ep, err := c.services.Resolve(forkName)
+	if err != nil || ep.Source == "derived" {
+		return "", fmt.Errorf("mcp: no pre-created fork named %s (list fork service IDs in TIGER_FORK_POOL)", forkName)
 	}
-	
-	fmt.Printf("      ✅ Using pre-created fork: %s\n", forkID)
-	return forkID, nil
+	if ep.ServiceID == parentServiceID {
+		return "", fmt.Errorf("mcp: fork name %s resolves to the parent service %s", forkName, parentServiceID)
+	}
+	fmt.Printf("      ✅ Using pre-created fork: %s\n", ep.ServiceID)
+	return ep.ServiceID, nil
 }
 
-// DeleteFork is a no-op since forks are pre-created and permanent.
+// CreateForkAtTimestamp creates a new fork of parentServiceID as it was at the given moment
+// (point-in-time recovery) through the Tiger CLI. Unlike the pre-created forks it is a fresh
+// service, so it is registered for queries and removed again by DeleteFork.
+func (c *MCPClient) CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error) {
+	out, err := c.tiger(ctx, "service", "fork", parentServiceID,
+		"--name", forkName, "--to-timestamp", at.UTC().Format(time.RFC3339), "-o", "json")
+	if err != nil {
+		return "", fmt.Errorf("mcp: point-in-time fork of %s at %s failed: %w", parentServiceID, at.UTC().Format(time.RFC3339), err)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out, &entry); err != nil {
+		return "", fmt.Errorf("mcp: unreadable tiger service fork output: %w", err)
+	}
+	id := firstString(entry, "service_id", "id")
+	if id == "" || id == parentServiceID {
+		return "", fmt.Errorf("mcp: tiger service fork returned no new service for %s", forkName)
+	}
+	if ep, ok := c.services.endpointFromListing(entry); ok {
+		c.services.Register(ep)
+	}
+	c.mu.Lock()
+	c.created[id] = true
+	c.mu.Unlock()
+	fmt.Printf("      ✅ Created point-in-time fork: %s (%s at %s)\n", id, parentServiceID, at.UTC().Format(time.RFC3339))
+	return id, nil
+}
+
+// DeleteFork deletes forks created by CreateForkAtTimestamp. Pre-created forks are permanent,
+// so for them it is a no-op.
 func (c *MCPClient) DeleteFork(ctx context.Context, serviceID string) error {
-	fmt.Printf("      [DeleteFork] No-op: forks are permanent\n")
+	c.mu.Lock()
+	created := c.created[serviceID]
+	c.mu.Unlock()
+	if !created {
+		fmt.Printf("      [DeleteFork] No-op: forks are permanent\n")
+		return nil
+	}
+	if _, err := c.tiger(ctx, "service", "delete", serviceID, "--force", "-o", "json"); err != nil {
+		return fmt.Errorf("mcp: delete fork %s: %w", serviceID, err)
+	}
+	c.mu.Lock()
+	delete(c.created, serviceID)
+	c.mu.Unlock()
 	return nil
 }
 
-// Close is a no-op for direct PostgreSQL connections.
-func (c *MCPClient) Close() error { return nil }
+// tiger runs a Tiger CLI command with the client's config dir and returns its stdout.
+func (c *MCPClient) tiger(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "tiger", append([]string{"--config-dir", c.configDir}, args...)...)
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+		return out, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return out, err
+}
+
+// Close closes the pooled connections of every service.
+func (c *MCPClient) Close() error {
+	c.conns.close()
+	return nil
+}
@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Defaults of the per-service connection pools.
+const (
+	defaultMaxConnsPerService = 4
+	defaultConnIdleTimeout    = 5 * time.Minute
+	defaultHealthCheckAfter   = 30 * time.Second
+)
+
+// PoolOptions size the connection pool kept per service.
+type PoolOptions struct {
+	MaxConns         int           // open connections per service
+	IdleTimeout      time.Duration // a service pool unused this long is closed
+	HealthCheckAfter time.Duration // a pool idle this long is pinged before it is reused
+}
+
+func poolOptionsFrom(maxConns, idleTimeoutS, healthCheckS int) PoolOptions {
+	o := PoolOptions{
+		MaxConns:         maxConns,
+		IdleTimeout:      time.Duration(idleTimeoutS) * time.Second,
+		HealthCheckAfter: time.Duration(healthCheckS) * time.Second,
+	}
+	if o.MaxConns <= 0 {
+		o.MaxConns = defaultMaxConnsPerService
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = defaultConnIdleTimeout
+	}
+	if o.HealthCheckAfter <= 0 {
+		o.HealthCheckAfter = defaultHealthCheckAfter
+	}
+	return o
+}
+
+// connCache keeps one *sql.DB per connection URL, so queries on the same fork reuse
+// connections instead of paying connection setup every time.
+type connCache struct {
+	opts PoolOptions
+	open func(connStr string) (*sql.DB, error) // sql.Open("postgres", ...) unless replaced in tests
+	now  func() time.Time
+
+	mu  sync.Mutex
+	dbs map[string]*cachedDB
+}
+
+type cachedDB struct {
+	db       *sql.DB
+	lastUsed time.Time
+}
+
+func newConnCache(opts PoolOptions) *connCache {
+	return &connCache{
+		opts: opts,
+		open: func(connStr string) (*sql.DB, error) { return sql.Open("postgres", connStr) },
+		now:  time.Now,
+		dbs:  map[string]*cachedDB{},
+	}
+}
+
+// get returns the pool for connStr, opening it on first use. Pools idle past IdleTimeout are
+// closed first; a pool idle past HealthCheckAfter is pinged and reopened when the ping fails.
+func (c *connCache) get(ctx context.Context, connStr string) (*sql.DB, error) {
+	now := c.now()
+	c.mu.Lock()
+	stale := c.evictIdleLocked(now)
+	entry, ok := c.dbs[connStr]
+	var idle time.Duration
+	if ok {
+		idle = now.Sub(entry.lastUsed)
+		entry.lastUsed = now
+	}
+	c.mu.Unlock()
+	closeAll(stale)
+
+	if ok {
+		if idle < c.opts.HealthCheckAfter {
+			return entry.db, nil
+		}
+		if err := entry.db.PingContext(ctx); err == nil {
+			return entry.db, nil
+		}
+		c.evict(connStr)
+	}
+
+	db, err := c.open(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("mcp: failed to open database: %w", err)
+	}
+	db.SetMaxOpenConns(c.opts.MaxConns)
+	db.SetMaxIdleConns(c.opts.MaxConns)
+	db.SetConnMaxIdleTime(c.opts.IdleTimeout)
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("mcp: failed to connect to database: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cur, ok := c.dbs[connStr]; ok {
+		// Another query opened the pool meanwhile; keep that one.
+		db.Close()
+		cur.lastUsed = now
+		return cur.db, nil
+	}
+	c.dbs[connStr] = &cachedDB{db: db, lastUsed: now}
+	return db, nil
+}
+
+// evict closes the pool of connStr, e.g. before its database is dropped or used as a template.
+func (c *connCache) evict(connStr string) {
+	c.mu.Lock()
+	entry, ok := c.dbs[connStr]
+	delete(c.dbs, connStr)
+	c.mu.Unlock()
+	if ok {
+		entry.db.Close()
+	}
+}
+
+// close closes every pool.
+func (c *connCache) close() {
+	c.mu.Lock()
+	var all []*sql.DB
+	for k, e := range c.dbs {
+		all = append(all, e.db)
+		delete(c.dbs, k)
+	}
+	c.mu.Unlock()
+	closeAll(all)
+}
+
+func (c *connCache) evictIdleLocked(now time.Time) []*sql.DB {
+	var stale []*sql.DB
+	for k, e := range c.dbs {
+		if now.Sub(e.lastUsed) >= c.opts.IdleTimeout {
+			stale = append(stale, e.db)
+			delete(c.dbs, k)
+		}
+	}
+	return stale
+}
+
+func closeAll(dbs []*sql.DB) {
+	for _, db := range dbs {
+		db.Close()
+	}
+}
@@ -0,0 +1,77 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubDriver hands out connections whose Ping fails while down is set.
+type stubDriver struct{ down *bool }
+
+type stubConn struct{ down *bool }
+
+func (d stubDriver) Open(name string) (driver.Conn, error) { return stubConn{down: d.down}, nil }
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+func (c stubConn) Ping(ctx context.Context) error {
+	if *c.down {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+var stubDown bool
+
+func init() { sql.Register("mcp-stub", stubDriver{down: &stubDown}) }
+
+func TestConnCache_ReusesEvictsAndHealthChecks(t *testing.T) {
+	stubDown = false
+	now := time.Unix(0, 0)
+	opens := 0
+	c := newConnCache(poolOptionsFrom(2, 300, 30))
+	c.now = func() time.Time { return now }
+	c.open = func(connStr string) (*sql.DB, error) { opens++; return sql.Open("mcp-stub", connStr) }
+	ctx := context.Background()
+
+	first, err := c.get(ctx, "svc-a")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	now = now.Add(10 * time.Second)
+	if again, _ := c.get(ctx, "svc-a"); again != first || opens != 1 {
+		t.Fatalf("expected the pool to be reused, opens=%d", opens)
+	}
+	if first.Stats().MaxOpenConnections != 2 {
+		t.Errorf("expected pool size 2, got %d", first.Stats().MaxOpenConnections)
+	}
+
+	// Idle past the health-check interval: a failed ping reopens the pool.
+	now = now.Add(time.Minute)
+	stubDown = true
+	if _, err := c.get(ctx, "svc-a"); err == nil {
+		t.Fatal("expected reopen to fail while the server is down")
+	}
+	stubDown = false
+	if _, err := c.get(ctx, "svc-a"); err != nil || opens != 3 {
+		t.Fatalf("expected a fresh pool after the failed health check, opens=%d err=%v", opens, err)
+	}
+
+	// Idle past the idle timeout: the pool is closed and reopened on next use.
+	now = now.Add(10 * time.Minute)
+	if _, err := c.get(ctx, "svc-b"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if _, ok := c.dbs["svc-a"]; ok {
+		t.Error("expected idle pool of svc-a to be evicted")
+	}
+	c.close()
+	if len(c.dbs) != 0 {
+		t.Error("expected close to drop every pool")
+	}
+}
@@ -1,28 +1,57 @@
 package mcp
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// HTTPMCPClient provides direct HTTP communication with Tiger MCP server.
+// mcpProtocolVersion is the MCP revision the client negotiates in initialize.
+const mcpProtocolVersion = "2025-03-26"
+
+// Tiger MCP tools used by HTTPMCPClient.
+const (
+	toolServiceList = "service_list"
+	toolServiceGet  = "service_get"
+	toolServiceFork = "service_fork"
+	toolServiceDel  = "service_delete"
+	toolExecQuery   = "db_execute_query"
+)
+
+// HTTPMCPClient talks to a Tiger MCP server over the MCP streamable HTTP transport:
+// JSON-RPC 2.0 requests POSTed to one endpoint, answered with JSON or an SSE stream.
+// The first call performs the initialize / notifications/initialized handshake and
+// lists the server's tools; the session ID the server assigns is sent on every later request.
+// It implements ForkProvider, so TIGER_MCP_URL alone can drive the system (FORK_PROVIDER=mcp).
 type HTTPMCPClient struct {
 	baseURL    string
 	httpClient *http.Client
 	timeout    time.Duration
+
+	nextID atomic.Int64
+
+	mu        sync.Mutex
+	ready     bool
+	sessionID string
+	tools     map[string]bool
 }
 
-// MCPRequest represents a JSON-RPC 2.0 request to MCP server.
+// MCPRequest represents a JSON-RPC 2.0 request to MCP server; notifications carry no ID.
 type MCPRequest struct {
-	JSONRPC string                 `json:"jsonrpc"`
-	Method  string                 `json:"method"`
-	Params  map[string]interface{} `json:"params"`
-	ID      int64                  `json:"id"`
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+	ID      *int64      `json:"id,omitempty"`
 }
 
 // MCPResponse represents a JSON-RPC 2.0 response from MCP server.
@@ -30,29 +59,31 @@ type MCPResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   *MCPError       `json:"error,omitempty"`
-	ID      int64           `json:"id"`
+	ID      *int64          `json:"id,omitempty"`
 }
 
 // MCPError represents a JSON-RPC 2.0 error response.
 type MCPError struct {
-	Code    int64  `json:"code"`
-	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
+	Code    int64           `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
-// DBExecuteQueryParams represents parameters for db_execute_query method.
-type DBExecuteQueryParams struct {
-	ServiceID  string `json:"service_id"`
-	Query      string `json:"query"`
-	TimeoutMs  int    `json:"timeout_ms,omitempty"`
+// ToolInfo is one entry of the server's tools/list.
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
 }
 
-// DBExecuteQueryResult represents the result from db_execute_query.
-type DBExecuteQueryResult struct {
-	Rows       []map[string]interface{} `json:"rows,omitempty"`
-	RowCount   int                      `json:"row_count,omitempty"`
-	Message    string                   `json:"message,omitempty"`
-	ExecutedAt time.Time                `json:"executed_at,omitempty"`
+// toolResult is the result of tools/call.
+type toolResult struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text,omitempty"`
+	} `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
 }
 
 // NewHTTPMCPClient creates a new HTTP MCP client.
@@ -66,79 +97,318 @@ func NewHTTPMCPClient(mcpURL string, timeout time.Duration) *HTTPMCPClient {
 	}
 }
 
-// ExecuteQuery executes a SQL query via HTTP MCP.
-func (c *HTTPMCPClient) ExecuteQuery(ctx context.Context, serviceID, sqlQuery string, timeoutMs int) (*DBExecuteQueryResult, error) {
-	req := MCPRequest{
-		JSONRPC: "2.0",
-		Method:  "db_execute_query",
-		ID:      time.Now().UnixNano(),
-		Params: map[string]interface{}{
-			"service_id": serviceID,
-			"query":      sqlQuery,
-			"timeout_ms": timeoutMs,
-		},
-	}
+// Connect performs the MCP handshake and caches the server's tool names.
+func (c *HTTPMCPClient) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.handshakeLocked(ctx)
+}
 
-	resp, err := c.call(ctx, &req)
-	if err != nil {
+// Tools lists the tools the server exposes.
+func (c *HTTPMCPClient) Tools(ctx context.Context) ([]ToolInfo, error) {
+	if err := c.ensureSession(ctx); err != nil {
 		return nil, err
 	}
+	return c.listTools(ctx)
+}
 
-	if resp.Error != nil {
-		return nil, fmt.Errorf("mcp: db_execute_query failed: code=%d message=%s", resp.Error.Code, resp.Error.Message)
-	}
+// CreateFork forks parentServiceID as it is now and waits until the fork is ready.
+func (c *HTTPMCPClient) CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error) {
+	return c.fork(ctx, parentServiceID, forkName, map[string]interface{}{"fork_strategy": "NOW"})
+}
 
-	var result DBExecuteQueryResult
-	if err := json.Unmarshal(resp.Result, &result); err != nil {
-		return nil, fmt.Errorf("mcp: failed to parse query result: %w", err)
+// CreateForkAtTimestamp forks parentServiceID as it was at the given moment (point-in-time recovery).
+func (c *HTTPMCPClient) CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error) {
+	return c.fork(ctx, parentServiceID, forkName, map[string]interface{}{
+		"fork_strategy": "PITR",
+		"target_time":   at.UTC().Format(time.RFC3339),
+	})
+}
+
+func (c *HTTPMCPClient) fork(ctx context.Context, parentServiceID, forkName string, args map[string]interface{}) (string, error) {
+	args["service_id"] = parentServiceID
+	args["name"] = forkName
+	args["wait"] = true
+	var out map[string]interface{}
+	if err := c.callTool(ctx, toolServiceFork, args, &out); err != nil {
+		return "", err
+	}
+	id := firstString(out, "service_id", "id")
+	if svc, ok := out["service"].(map[string]interface{}); ok && id == "" {
+		id = firstString(svc, "service_id", "id")
 	}
+	if id == "" {
+		return "", errors.New("mcp: service_fork returned no service_id")
+	}
+	return id, nil
+}
 
-	return &result, nil
+// DeleteFork deletes a fork service.
+func (c *HTTPMCPClient) DeleteFork(ctx context.Context, serviceID string) error {
+	return c.callTool(ctx, toolServiceDel, map[string]interface{}{"service_id": serviceID}, nil)
+}
+
+// GetServiceInfo returns metadata of one service.
+func (c *HTTPMCPClient) GetServiceInfo(ctx context.Context, serviceID string) (ServiceInfo, error) {
+	var out map[string]interface{}
+	if err := c.callTool(ctx, toolServiceGet, map[string]interface{}{"service_id": serviceID}, &out); err != nil {
+		return ServiceInfo{}, err
+	}
+	if svc, ok := out["service"].(map[string]interface{}); ok {
+		out = svc
+	}
+	info := ServiceInfo{
+		ServiceID: firstString(out, "service_id", "id"),
+		ParentID:  firstString(out, "parent_id", "forked_from"),
+		Status:    firstString(out, "status"),
+		CreatedAt: firstString(out, "created_at", "created"),
+	}
+	if info.ServiceID == "" {
+		info.ServiceID = serviceID
+	}
+	return info, nil
 }
 
 // ServiceList lists all services via HTTP MCP.
 func (c *HTTPMCPClient) ServiceList(ctx context.Context) ([]map[string]interface{}, error) {
-	req := MCPRequest{
-		JSONRPC: "2.0",
-		Method:  "service_list",
-		ID:      time.Now().UnixNano(),
-		Params:  map[string]interface{}{},
+	var raw json.RawMessage
+	if err := c.callTool(ctx, toolServiceList, map[string]interface{}{}, &raw); err != nil {
+		return nil, err
+	}
+	var services []map[string]interface{}
+	if err := json.Unmarshal(raw, &services); err == nil {
+		return services, nil
 	}
+	var wrapped struct {
+		Services []map[string]interface{} `json:"services"`
+	}
+	if err := json.Unmarshal(raw, &wrapped); err != nil {
+		return nil, fmt.Errorf("mcp: failed to parse services: %w", err)
+	}
+	return wrapped.Services, nil
+}
+
+// ExecuteQuery runs a SQL query on a service through the db_execute_query tool.
+// ExecutionTimeMs is the time the server reports, or the round trip when it reports none.
+// TimingExplain is honored by wrapping read statements in EXPLAIN ANALYZE; pg_stat_statements
+// timing is not available over MCP and falls back to the reported time.
+func (c *HTTPMCPClient) ExecuteQuery(ctx context.Context, serviceID, sqlQuery string, timeoutMs int) (QueryResult, error) {
+	explain := TimingModeFrom(ctx) == TimingExplain && ReadOnlyQuery(sqlQuery)
+	query := sqlQuery
+	if explain {
+		query = explainTimingPrefix + strings.TrimRight(strings.TrimSpace(sqlQuery), "; \n\t")
+	}
+	args := map[string]interface{}{"service_id": serviceID, "query": query}
+	if timeoutMs > 0 {
+		args["timeout_seconds"] = int(math.Ceil(float64(timeoutMs) / 1000))
+	}
+	start := time.Now()
+	var out map[string]interface{}
+	if err := c.callTool(ctx, toolExecQuery, args, &out); err != nil {
+		return QueryResult{}, err
+	}
+	elapsed := time.Since(start).Seconds() * 1000
+
+	res := QueryResult{Rows: queryRows(out), Command: firstString(out, "command"), Message: firstString(out, "message")}
+	res.RowCount = len(res.Rows)
+	if n, ok := out["row_count"].(float64); ok {
+		res.RowCount = int(n)
+	}
+	if explain {
+		raw := ""
+		if len(res.Rows) > 0 {
+			for _, v := range res.Rows[0] {
+				raw = explainText(v)
+			}
+		}
+		return parseExplainTiming(raw)
+	}
+	res.ExecutionTimeMs, res.TimingSource = elapsed, string(TimingClient)
+	if ms, ok := reportedMs(out); ok {
+		res.ExecutionTimeMs = ms
+	}
+	return res, nil
+}
 
-	resp, err := c.call(ctx, &req)
+// Close ends the MCP session.
+func (c *HTTPMCPClient) Close() error {
+	c.mu.Lock()
+	sid := c.sessionID
+	c.ready, c.sessionID = false, ""
+	c.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL, nil)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil // the server drops idle sessions on its own
+	}
+	resp.Body.Close()
+	return nil
+}
 
+// ensureSession runs the handshake once, before the first tool call.
+func (c *HTTPMCPClient) ensureSession(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ready {
+		return nil
+	}
+	return c.handshakeLocked(ctx)
+}
+
+func (c *HTTPMCPClient) handshakeLocked(ctx context.Context) error {
+	if c.baseURL == "" {
+		return errors.New("mcp: TIGER_MCP_URL is not configured")
+	}
+	c.sessionID = ""
+	params := map[string]interface{}{
+		"protocolVersion": mcpProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]interface{}{"name": "afs-backend", "version": "1.0"},
+	}
+	resp, sid, err := c.post(ctx, c.request("initialize", params), "")
+	if err != nil {
+		return err
+	}
 	if resp.Error != nil {
-		return nil, fmt.Errorf("mcp: service_list failed: code=%d message=%s", resp.Error.Code, resp.Error.Message)
+		return fmt.Errorf("mcp: initialize failed: code=%d message=%s", resp.Error.Code, resp.Error.Message)
 	}
+	c.sessionID = sid
+	if _, _, err := c.post(ctx, &MCPRequest{JSONRPC: "2.0", Method: "notifications/initialized"}, c.sessionID); err != nil {
+		return err
+	}
+	c.ready = true
+	tools, err := c.listToolsLocked(ctx)
+	if err != nil {
+		c.ready = false
+		return err
+	}
+	c.tools = map[string]bool{}
+	for _, t := range tools {
+		c.tools[t.Name] = true
+	}
+	return nil
+}
 
-	var services []map[string]interface{}
-	if err := json.Unmarshal(resp.Result, &services); err != nil {
-		return nil, fmt.Errorf("mcp: failed to parse services: %w", err)
+func (c *HTTPMCPClient) listTools(ctx context.Context) ([]ToolInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.listToolsLocked(ctx)
+}
+
+func (c *HTTPMCPClient) listToolsLocked(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	for cursor := ""; ; {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		resp, _, err := c.post(ctx, c.request("tools/list", params), c.sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("mcp: tools/list failed: code=%d message=%s", resp.Error.Code, resp.Error.Message)
+		}
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor,omitempty"`
+		}
+		if err := json.Unmarshal(resp.Result, &page); err != nil {
+			return nil, fmt.Errorf("mcp: failed to parse tools/list: %w", err)
+		}
+		tools = append(tools, page.Tools...)
+		if page.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = page.NextCursor
 	}
+}
 
-	return services, nil
+// callTool invokes a tool and decodes its structured content (or the JSON in its first text
+// content) into out. A tool result flagged isError becomes an error carrying the tool's text.
+func (c *HTTPMCPClient) callTool(ctx context.Context, name string, args map[string]interface{}, out interface{}) error {
+	if err := c.ensureSession(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	known, sid := c.tools[name], c.sessionID
+	listed := len(c.tools) > 0
+	c.mu.Unlock()
+	if listed && !known {
+		return fmt.Errorf("mcp: server does not expose the %s tool", name)
+	}
+
+	resp, _, err := c.post(ctx, c.request("tools/call", map[string]interface{}{"name": name, "arguments": args}), sid)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("mcp: %s failed: code=%d message=%s", name, resp.Error.Code, resp.Error.Message)
+	}
+	var tr toolResult
+	if err := json.Unmarshal(resp.Result, &tr); err != nil {
+		return fmt.Errorf("mcp: failed to parse %s result: %w", name, err)
+	}
+	text := ""
+	for _, ct := range tr.Content {
+		if ct.Type == "text" {
+			text += ct.Text
+		}
+	}
+	if tr.IsError {
+		return fmt.Errorf("mcp: %s failed: %s", name, strings.TrimSpace(text))
+	}
+	if out == nil {
+		return nil
+	}
+	payload := []byte(tr.StructuredContent)
+	if len(payload) == 0 {
+		payload = []byte(text)
+	}
+	if len(bytes.TrimSpace(payload)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("mcp: failed to parse %s result: %w", name, err)
+	}
+	return nil
 }
 
-// call sends a JSON-RPC request to the MCP server and returns the response.
-func (c *HTTPMCPClient) call(ctx context.Context, req *MCPRequest) (*MCPResponse, error) {
+func (c *HTTPMCPClient) request(method string, params interface{}) *MCPRequest {
+	id := c.nextID.Add(1)
+	return &MCPRequest{JSONRPC: "2.0", Method: method, Params: params, ID: &id}
+}
+
+// post sends a JSON-RPC message and returns the matching response and the session ID header.
+// Notifications return an empty response (the server answers 202 Accepted).
+// SSE responses are read until the event carrying the response to req arrives.
+func (c *HTTPMCPClient) post(ctx context.Context, req *MCPRequest, sessionID string) (*MCPResponse, string, error) {
 	// Marshal request
 	reqBody, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("mcp: failed to marshal request: %w", err)
+		return nil, "", fmt.Errorf("mcp: failed to marshal request: %w", err)
 	}
 
 	// Create HTTP request
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewReader(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewReader(reqBody))
 	if err != nil {
-		return nil, fmt.Errorf("mcp: failed to create request: %w", err)
+		return nil, "", fmt.Errorf("mcp: failed to create request: %w", err)
 	}
 
 	// Set headers
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	if sessionID != "" {
+		httpReq.Header.Set("Mcp-Session-Id", sessionID)
+		httpReq.Header.Set("MCP-Protocol-Version", mcpProtocolVersion)
+	}
 
 	// Execute request
 	start := time.Now()
@@ -146,26 +416,141 @@ func (c *HTTPMCPClient) call(ctx context.Context, req *MCPRequest) (*MCPResponse
 	duration := time.Since(start)
 
 	if err != nil {
-		return nil, fmt.Errorf("mcp: http request failed: %w (duration: %dms)", err, duration.Milliseconds())
+		return nil, "", fmt.Errorf("mcp: http request failed: %w (duration: %dms)", err, duration.Milliseconds())
 	}
 	defer httpResp.Body.Close()
+	sid := httpResp.Header.Get("Mcp-Session-Id")
 
-	// Read response body
-	respBody, err := io.ReadAll(httpResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("mcp: failed to read response: %w", err)
+	if req.ID == nil {
+		if httpResp.StatusCode != http.StatusAccepted && httpResp.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(httpResp.Body)
+			return nil, sid, fmt.Errorf("mcp: unexpected HTTP status: %d (body: %s)", httpResp.StatusCode, string(body))
+		}
+		return &MCPResponse{}, sid, nil
 	}
 
 	// Check HTTP status
 	if httpResp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("mcp: unexpected HTTP status: %d (body: %s)", httpResp.StatusCode, string(respBody))
+		body, _ := io.ReadAll(httpResp.Body)
+		if httpResp.StatusCode == http.StatusNotFound && sessionID != "" {
+			// The server dropped the session; the next call starts a new one.
+			c.mu.Lock()
+			c.ready = false
+			c.mu.Unlock()
+		}
+		return nil, sid, fmt.Errorf("mcp: unexpected HTTP status: %d (body: %s)", httpResp.StatusCode, string(body))
+	}
+
+	if strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream") {
+		resp, err := readSSEResponse(httpResp.Body, *req.ID)
+		return resp, sid, err
+	}
+
+	// Read response body
+	respBody, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, sid, fmt.Errorf("mcp: failed to read response: %w", err)
 	}
 
 	// Parse JSON-RPC response
 	var mcpResp MCPResponse
 	if err := json.Unmarshal(respBody, &mcpResp); err != nil {
-		return nil, fmt.Errorf("mcp: failed to parse response: %w (body: %s)", err, string(respBody))
+		return nil, sid, fmt.Errorf("mcp: failed to parse response: %w (body: %s)", err, string(respBody))
+	}
+	return &mcpResp, sid, nil
+}
+
+// readSSEResponse reads server-sent events until the JSON-RPC response with the given ID.
+// Server requests and notifications interleaved on the stream are skipped.
+func readSSEResponse(body io.Reader, id int64) (*MCPResponse, error) {
+	sc := bufio.NewScanner(body)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	flush := func() (*MCPResponse, bool) {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return nil, false
+		}
+		var resp MCPResponse
+		if err := json.Unmarshal([]byte(data.String()), &resp); err != nil || resp.ID == nil || *resp.ID != id {
+			return nil, false
+		}
+		return &resp, true
 	}
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case line == "":
+			if resp, ok := flush(); ok {
+				return resp, nil
+			}
+		case strings.HasPrefix(line, "data:"):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if resp, ok := flush(); ok {
+		return resp, nil
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("mcp: failed to read event stream: %w", err)
+	}
+	return nil, fmt.Errorf("mcp: event stream ended without a response to request %d", id)
+}
 
-	return &mcpResp, nil
+// queryRows normalizes db_execute_query rows, given either as objects or as arrays
+// aligned with a columns list of names or {name} objects.
+func queryRows(out map[string]interface{}) []map[string]any {
+	rows := []map[string]any{}
+	list, _ := out["rows"].([]interface{})
+	var cols []string
+	if cs, ok := out["columns"].([]interface{}); ok {
+		for _, col := range cs {
+			switch v := col.(type) {
+			case string:
+				cols = append(cols, v)
+			case map[string]interface{}:
+				cols = append(cols, firstString(v, "name"))
+			}
+		}
+	}
+	for _, r := range list {
+		switch v := r.(type) {
+		case map[string]interface{}:
+			rows = append(rows, v)
+		case []interface{}:
+			entry := make(map[string]any, len(v))
+			for i, val := range v {
+				if i < len(cols) {
+					entry[cols[i]] = val
+				}
+			}
+			rows = append(rows, entry)
+		}
+	}
+	return rows
+}
+
+// reportedMs reads the server-reported execution time, as milliseconds or a Go duration string.
+func reportedMs(out map[string]interface{}) (float64, bool) {
+	if ms, ok := out["execution_time_ms"].(float64); ok && ms > 0 {
+		return ms, true
+	}
+	if s, ok := out["execution_time"].(string); ok {
+		if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			return d.Seconds() * 1000, true
+		}
+	}
+	return 0, false
+}
+
+// explainText returns the EXPLAIN (FORMAT JSON) document held in a result cell.
+func explainText(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
 }
@@ -0,0 +1,140 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMCPServer answers the streamable HTTP transport: JSON for most calls, SSE for db_execute_query.
+type fakeMCPServer struct {
+	mu      sync.Mutex
+	methods []string
+	calls   map[string]map[string]interface{} // tool name -> last arguments
+	closed  bool
+}
+
+func (f *fakeMCPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		f.mu.Lock()
+		f.closed = r.Header.Get("Mcp-Session-Id") == "sess-1"
+		f.mu.Unlock()
+		return
+	}
+	var req struct {
+		Method string          `json:"method"`
+		Params json.RawMessage `json:"params"`
+		ID     *int64          `json:"id"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	f.mu.Lock()
+	f.methods = append(f.methods, req.Method)
+	f.mu.Unlock()
+	if req.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != "sess-1" {
+		http.Error(w, "missing session", http.StatusBadRequest)
+		return
+	}
+	if req.ID == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	reply := func(result interface{}) map[string]interface{} {
+		return map[string]interface{}{"jsonrpc": "2.0", "id": *req.ID, "result": result}
+	}
+	switch req.Method {
+	case "initialize":
+		w.Header().Set("Mcp-Session-Id", "sess-1")
+		_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{"protocolVersion": mcpProtocolVersion}))
+	case "tools/list":
+		var tools []map[string]interface{}
+		for _, n := range []string{toolServiceList, toolServiceGet, toolServiceFork, toolServiceDel, toolExecQuery} {
+			tools = append(tools, map[string]interface{}{"name": n})
+		}
+		_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{"tools": tools}))
+	case "tools/call":
+		var p struct {
+			Name      string                 `json:"name"`
+			Arguments map[string]interface{} `json:"arguments"`
+		}
+		_ = json.Unmarshal(req.Params, &p)
+		f.mu.Lock()
+		f.calls[p.Name] = p.Arguments
+		f.mu.Unlock()
+		switch p.Name {
+		case toolServiceFork:
+			_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{
+				"structuredContent": map[string]interface{}{"service": map[string]interface{}{"service_id": "fork42"}},
+			}))
+		case toolServiceGet:
+			_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{
+				"structuredContent": map[string]interface{}{"service": map[string]interface{}{
+					"service_id": p.Arguments["service_id"], "parent_id": "main1", "status": "READY"}},
+			}))
+		case toolServiceDel:
+			_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{
+				"isError": true, "content": []map[string]interface{}{{"type": "text", "text": "service not found"}},
+			}))
+		case toolServiceList:
+			_ = json.NewEncoder(w).Encode(reply(map[string]interface{}{
+				"content": []map[string]interface{}{{"type": "text", "text": `{"services":[{"service_id":"main1","name":"main"}]}`}},
+			}))
+		case toolExecQuery:
+			w.Header().Set("Content-Type", "text/event-stream")
+			body, _ := json.Marshal(reply(map[string]interface{}{"structuredContent": map[string]interface{}{
+				"columns":        []interface{}{map[string]interface{}{"name": "id"}, "name"},
+				"rows":           [][]interface{}{{1, "a"}, {2, "b"}},
+				"execution_time": "12.5ms",
+			}}))
+			fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", body)
+		}
+	}
+}
+
+func TestHTTPMCPClient_HandshakeToolsAndSSE(t *testing.T) {
+	fake := &fakeMCPServer{calls: map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	c := NewHTTPMCPClient(srv.URL, 5*time.Second)
+	ctx := context.Background()
+
+	services, err := c.ServiceList(ctx)
+	if err != nil || len(services) != 1 || services[0]["service_id"] != "main1" {
+		t.Fatalf("ServiceList = %v (%v)", services, err)
+	}
+	if want := []string{"initialize", "notifications/initialized", "tools/list", "tools/call"}; fmt.Sprint(fake.methods) != fmt.Sprint(want) {
+		t.Errorf("expected lazy handshake before the first tool call, got %v", fake.methods)
+	}
+
+	id, err := c.CreateForkAtTimestamp(ctx, "main1", "fork-a", time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil || id != "fork42" {
+		t.Fatalf("CreateForkAtTimestamp = %q (%v)", id, err)
+	}
+	args := fake.calls[toolServiceFork]
+	if args["fork_strategy"] != "PITR" || args["target_time"] != "2026-01-02T03:04:05Z" || args["service_id"] != "main1" {
+		t.Errorf("unexpected service_fork arguments: %v", args)
+	}
+
+	res, err := c.ExecuteQuery(ctx, "fork42", "SELECT id, name FROM t", 1500)
+	if err != nil {
+		t.Fatalf("ExecuteQuery: %v", err)
+	}
+	if res.RowCount != 2 || res.Rows[1]["name"] != "b" || res.ExecutionTimeMs != 12.5 {
+		t.Errorf("unexpected query result from the event stream: %+v", res)
+	}
+	if fake.calls[toolExecQuery]["timeout_seconds"] != float64(2) {
+		t.Errorf("expected the timeout rounded up to seconds, got %v", fake.calls[toolExecQuery])
+	}
+
+	if err := c.DeleteFork(ctx, "fork42"); err == nil {
+		t.Error("expected an isError tool result to surface as an error")
+	}
+	if err := c.Close(); err != nil || !fake.closed {
+		t.Errorf("expected Close to end the session (err=%v)", err)
+	}
+}
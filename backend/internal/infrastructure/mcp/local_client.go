@@ -0,0 +1,183 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+)
+
+// localForkPrefix marks databases created by LocalClient; DeleteFork drops nothing else.
+const localForkPrefix = "fork_"
+
+// LocalClient provides forks on a plain PostgreSQL server, so the pipeline runs offline
+// and in integration tests without Tiger Cloud. The main service is a template database;
+// a fork is a copy made with CREATE DATABASE ... TEMPLATE and dropped with DROP DATABASE.
+// Service IDs are database names on the server behind adminURL.
+type LocalClient struct {
+	adminURL string // connection URL of a database the user may run CREATE/DROP DATABASE from
+	template string // database forks are copied from when no parent is given
+	conns    *connCache
+	timeout  time.Duration
+}
+
+// NewLocal creates a local fork provider from c.Forks.
+func NewLocal(c *cfgpkg.Config) (*LocalClient, error) {
+	if c == nil {
+		return nil, errors.New("nil config")
+	}
+	admin := strings.TrimSpace(c.Forks.LocalAdminURL)
+	if admin == "" {
+		admin = c.Database.URL
+	}
+	if _, err := databaseURL(admin, "postgres"); err != nil {
+		return nil, err
+	}
+	return &LocalClient{
+		adminURL: admin,
+		template: strings.TrimSpace(c.Forks.LocalTemplate),
+		conns:    newConnCache(poolOptionsFrom(c.Forks.MaxConnsPerService, c.Forks.ConnIdleTimeoutS, c.Forks.HealthCheckS)),
+		timeout:  30 * time.Second,
+	}, nil
+}
+
+// Connect verifies the admin connection.
+func (c *LocalClient) Connect(ctx context.Context) error {
+	db, err := sql.Open("postgres", c.adminURL)
+	if err != nil {
+		return fmt.Errorf("mcp local: failed to open admin database: %w", err)
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("mcp local: failed to connect to admin database: %w", err)
+	}
+	return nil
+}
+
+// CreateFork copies parentServiceID (the template database when empty) into a new database
+// named after forkName. A leftover fork with the same name, e.g. from a crashed run, is dropped first.
+// PostgreSQL refuses the copy while other sessions are connected to the parent, so the
+// client closes its own pooled connections to it first.
+func (c *LocalClient) CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error) {
+	parent := strings.TrimSpace(parentServiceID)
+	if parent == "" {
+		parent = c.template
+	}
+	if parent == "" {
+		return "", errors.New("mcp local: no parent database to fork (set LOCAL_FORK_TEMPLATE)")
+	}
+	forkID := forkDatabaseName(forkName)
+	if forkID == parent {
+		return "", fmt.Errorf("mcp local: fork name %q resolves to its parent database", forkName)
+	}
+	if err := c.admin(ctx, "DROP DATABASE IF EXISTS "+pq.QuoteIdentifier(forkID)); err != nil {
+		return "", err
+	}
+	// Our own pooled sessions on the parent would block the copy.
+	c.evict(parent)
+	stmt := "CREATE DATABASE " + pq.QuoteIdentifier(forkID) + " TEMPLATE " + pq.QuoteIdentifier(parent)
+	if err := c.admin(ctx, stmt); err != nil {
+		return "", fmt.Errorf("mcp local: fork %s from %s: %w", forkID, parent, err)
+	}
+	fmt.Printf("      ✅ Created local fork: %s (template %s)\n", forkID, parent)
+	return forkID, nil
+}
+
+// CreateForkAtTimestamp is not supported: a plain PostgreSQL server keeps no history to copy from.
+func (c *LocalClient) CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error) {
+	return "", fmt.Errorf("mcp local: point-in-time forks need Tiger Cloud (fork of %s at %s requested)", parentServiceID, at.UTC().Format(time.RFC3339))
+}
+
+// DeleteFork drops a fork database, closing its open sessions first.
+// Only databases created by CreateFork can be dropped.
+func (c *LocalClient) DeleteFork(ctx context.Context, serviceID string) error {
+	if !strings.HasPrefix(serviceID, localForkPrefix) || serviceID == c.template {
+		return fmt.Errorf("mcp local: refusing to drop %q: not a fork database", serviceID)
+	}
+	c.evict(serviceID)
+	terminate := "SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname = " +
+		pq.QuoteLiteral(serviceID) + " AND pid <> pg_backend_pid()"
+	if err := c.admin(ctx, terminate); err != nil {
+		return err
+	}
+	return c.admin(ctx, "DROP DATABASE IF EXISTS "+pq.QuoteIdentifier(serviceID))
+}
+
+// ExecuteQuery runs a SQL query on the database named by serviceID.
+func (c *LocalClient) ExecuteQuery(ctx context.Context, serviceID, sqlQuery string, timeoutMs int) (QueryResult, error) {
+	connStr, err := databaseURL(c.adminURL, serviceID)
+	if err != nil {
+		return QueryResult{}, err
+	}
+	return queryPostgres(ctx, c.conns, connStr, sqlQuery, timeoutMs)
+}
+
+// Close closes the pooled connections of every database.
+func (c *LocalClient) Close() error {
+	c.conns.close()
+	return nil
+}
+
+// evict closes the pooled connections to one database.
+func (c *LocalClient) evict(dbName string) {
+	if connStr, err := databaseURL(c.adminURL, dbName); err == nil {
+		c.conns.evict(connStr)
+	}
+}
+
+// admin runs a statement on the admin connection. CREATE/DROP DATABASE cannot run in a
+// transaction, so it goes through Exec on a dedicated connection.
+func (c *LocalClient) admin(ctx context.Context, stmt string) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	db, err := sql.Open("postgres", c.adminURL)
+	if err != nil {
+		return fmt.Errorf("mcp local: failed to open admin database: %w", err)
+	}
+	defer db.Close()
+	if _, err := db.ExecContext(ctx, stmt); err != nil {
+		return fmt.Errorf("mcp local: %w", err)
+	}
+	return nil
+}
+
+// forkDatabaseName turns a fork name such as "fork-cerebro-task12" into a database name:
+// lowercase, [a-z0-9_] only, prefixed with fork_ and cut to PostgreSQL's 63-byte limit.
+func forkDatabaseName(forkName string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(forkName)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if !strings.HasPrefix(name, localForkPrefix) {
+		name = localForkPrefix + name
+	}
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// databaseURL points a postgres:// connection URL at another database on the same server.
+func databaseURL(base, dbName string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") || u.Host == "" {
+		return "", fmt.Errorf("mcp local: admin connection must be a postgres:// URL")
+	}
+	if strings.TrimSpace(dbName) == "" {
+		return "", errors.New("mcp local: empty database name")
+	}
+	u.Path = "/" + dbName
+	u.RawPath = ""
+	return u.String(), nil
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+)
+
+// ForkProvider is the port the agents and the orchestrator use to create, query and drop forks.
+// MCPClient implements it on Tiger Cloud; HTTPMCPClient through a Tiger MCP server;
+// LocalClient on a plain PostgreSQL server.
+type ForkProvider interface {
+	Connect(ctx context.Context) error
+	CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error)
+	// CreateForkAtTimestamp forks parentServiceID as it was at the given moment (point-in-time recovery).
+	CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error)
+	DeleteFork(ctx context.Context, serviceID string) error
+	ExecuteQuery(ctx context.Context, serviceID, sqlQuery string, timeoutMs int) (QueryResult, error)
+	Close() error
+}
+
+// Fork provider names accepted by FORK_PROVIDER.
+const (
+	ProviderTiger = "tiger"
+	ProviderLocal = "local"
+	ProviderMCP   = "mcp"
+)
+
+var (
+	_ ForkProvider = (*MCPClient)(nil)
+	_ ForkProvider = (*LocalClient)(nil)
+	_ ForkProvider = (*HTTPMCPClient)(nil)
+)
+
+// NewProvider builds the fork provider selected by c.Forks.Provider (Tiger Cloud by default).
+func NewProvider(c *cfgpkg.Config) (ForkProvider, error) {
+	if c == nil {
+		return nil, errors.New("nil config")
+	}
+	switch strings.ToLower(strings.TrimSpace(c.Forks.Provider)) {
+	case "", ProviderTiger:
+		return New(c, nil)
+	case ProviderLocal:
+		return NewLocal(c)
+	case ProviderMCP:
+		if strings.TrimSpace(c.TigerCloud.MCPURL) == "" {
+			return nil, errors.New("mcp: FORK_PROVIDER=mcp needs TIGER_MCP_URL")
+		}
+		return NewHTTPMCPClient(c.TigerCloud.MCPURL, 60*time.Second), nil
+	default:
+		return nil, fmt.Errorf("mcp: unknown fork provider %q (supported: %s, %s, %s)", c.Forks.Provider, ProviderTiger, ProviderLocal, ProviderMCP)
+	}
+}
@@ -0,0 +1,26 @@
+package mcp
+
+import "regexp"
+
+var (
+	// sqlLiteralRe matches string literals, quoted identifiers and comments, whose words are not keywords.
+	sqlLiteralRe = regexp.MustCompile(`(?s)'(?:[^']|'')*'|"(?:[^"]|"")*"|--[^\n]*|/\*.*?\*/`)
+	// readStatementRe matches the keywords a read statement may start with.
+	readStatementRe = regexp.MustCompile(`(?i)^\s*(SELECT|WITH|VALUES|TABLE)\b`)
+	// writeStatementRe matches a data-modifying statement in statement position: at the start, after a
+	// ";" or opening a parenthesis, as in WITH d AS (DELETE ...).
+	writeStatementRe = regexp.MustCompile(`(?i)(^|[(;])\s*(INSERT|UPDATE|DELETE|MERGE|COPY|TRUNCATE|CREATE|DROP|ALTER)\b`)
+	// lockingClauseRe matches SELECT ... INTO (creates a table) and row-locking clauses.
+	lockingClauseRe = regexp.MustCompile(`(?i)\b(INTO|FOR\s+(NO\s+KEY\s+)?UPDATE|FOR\s+(KEY\s+)?SHARE)\b`)
+)
+
+// ReadOnlyQuery reports whether sqlQuery only reads: a SELECT, WITH, VALUES or TABLE statement with
+// no data-modifying statement inside it, no SELECT INTO and no row locks. Such a query can run under
+// EXPLAIN ANALYZE or be re-run on main without changing anything.
+func ReadOnlyQuery(sqlQuery string) bool {
+	q := sqlLiteralRe.ReplaceAllString(sqlQuery, " ")
+	if !readStatementRe.MatchString(q) {
+		return false
+	}
+	return !writeStatementRe.MatchString(q) && !lockingClauseRe.MatchString(q)
+}
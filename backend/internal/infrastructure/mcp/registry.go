@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// tigerHostSuffix is the domain of Tiger Cloud service hosts: <service_id>.<project_id>.tsdb.cloud.timescale.com.
+const tigerHostSuffix = ".tsdb.cloud.timescale.com"
+
+// ServiceEndpoint is the connection info of one Tiger service (the main database or a fork).
+type ServiceEndpoint struct {
+	ServiceID string
+	Name      string
+	ConnURL   string
+	Source    string // config, discovered or derived
+}
+
+// serviceLister is the part of HTTPMCPClient the registry discovers services with.
+type serviceLister interface {
+	ServiceList(ctx context.Context) ([]map[string]interface{}, error)
+}
+
+// ServiceRegistry maps service IDs (or names) to connection URLs.
+// Entries come from config (TIGER_SERVICE_URLS and the per-service *_SERVICE_URL variables)
+// and from the MCP service_list; config wins over discovery. Services found in neither are
+// reached by swapping the service ID into the host of the template URL, which works for
+// any service of the same Tiger project because they share credentials and host layout.
+type ServiceRegistry struct {
+	template string // connection URL whose credentials and host layout unknown services inherit
+
+	mu       sync.RWMutex
+	services map[string]ServiceEndpoint
+}
+
+// NewServiceRegistry creates a registry from explicit service ID -> URL entries.
+func NewServiceRegistry(template string, urls map[string]string) *ServiceRegistry {
+	r := &ServiceRegistry{template: template, services: map[string]ServiceEndpoint{}}
+	for id, u := range urls {
+		r.Register(ServiceEndpoint{ServiceID: id, ConnURL: u, Source: "config"})
+	}
+	return r
+}
+
+// Register adds or replaces an endpoint. Discovered entries never replace configured ones.
+func (r *ServiceRegistry) Register(ep ServiceEndpoint) {
+	ep.ServiceID = strings.TrimSpace(ep.ServiceID)
+	if ep.ServiceID == "" || ep.ConnURL == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cur, ok := r.services[ep.ServiceID]; ok && cur.Source == "config" && ep.Source != "config" {
+		if cur.Name == "" {
+			cur.Name = ep.Name
+			r.services[ep.ServiceID] = cur
+		}
+		return
+	}
+	r.services[ep.ServiceID] = ep
+}
+
+// Resolve returns the endpoint of a service by ID, then by unique name, then derived from the template.
+func (r *ServiceRegistry) Resolve(serviceID string) (ServiceEndpoint, error) {
+	r.mu.RLock()
+	if ep, ok := r.services[serviceID]; ok {
+		r.mu.RUnlock()
+		return ep, nil
+	}
+	var byName []ServiceEndpoint
+	for _, ep := range r.services {
+		if ep.Name != "" && ep.Name == serviceID {
+			byName = append(byName, ep)
+		}
+	}
+	r.mu.RUnlock()
+	switch {
+	case len(byName) == 1:
+		return byName[0], nil
+	case len(byName) > 1:
+		return ServiceEndpoint{}, fmt.Errorf("mcp: service name %s matches %d services", serviceID, len(byName))
+	}
+	if u := deriveServiceURL(r.template, serviceID); u != "" {
+		return ServiceEndpoint{ServiceID: serviceID, ConnURL: u, Source: "derived"}, nil
+	}
+	return ServiceEndpoint{}, fmt.Errorf("mcp: unknown fork service ID: %s", serviceID)
+}
+
+// Services returns the registered endpoints sorted by service ID.
+func (r *ServiceRegistry) Services() []ServiceEndpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ServiceEndpoint, 0, len(r.services))
+	for _, ep := range r.services {
+		out = append(out, ep)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ServiceID < out[j].ServiceID })
+	return out
+}
+
+// Discover registers the services reported by the MCP service_list and returns how many it added.
+func (r *ServiceRegistry) Discover(ctx context.Context, lister serviceLister) (int, error) {
+	entries, err := lister.ServiceList(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, e := range entries {
+		ep, ok := r.endpointFromListing(e)
+		if !ok {
+			continue
+		}
+		r.Register(ep)
+		n++
+	}
+	return n, nil
+}
+
+// endpointFromListing reads one service_list entry: an explicit connection string when present,
+// otherwise the service host and port combined with the template credentials.
+func (r *ServiceRegistry) endpointFromListing(e map[string]interface{}) (ServiceEndpoint, bool) {
+	id := firstString(e, "service_id", "id")
+	if id == "" {
+		return ServiceEndpoint{}, false
+	}
+	ep := ServiceEndpoint{ServiceID: id, Name: firstString(e, "name", "service_name"), Source: "discovered"}
+	ep.ConnURL = firstString(e, "connection_string", "connection_url", "url")
+	if ep.ConnURL == "" {
+		host, port := firstString(e, "host", "hostname"), firstString(e, "port")
+		if endpoint, ok := e["endpoint"].(map[string]interface{}); ok && host == "" {
+			host, port = firstString(endpoint, "host", "hostname"), firstString(endpoint, "port")
+		}
+		ep.ConnURL = withHost(r.template, host, port)
+	}
+	return ep, ep.ConnURL != ""
+}
+
+// deriveServiceURL swaps serviceID into a Tiger template host (<id>.<project>.tsdb.cloud.timescale.com).
+func deriveServiceURL(template, serviceID string) string {
+	u, err := url.Parse(template)
+	if err != nil || serviceID == "" || strings.ContainsAny(serviceID, "./:@ ") {
+		return ""
+	}
+	host := u.Hostname()
+	if !strings.HasSuffix(host, tigerHostSuffix) {
+		return ""
+	}
+	k := strings.Index(host, ".")
+	return withHost(template, serviceID+host[k:], u.Port())
+}
+
+// withHost returns template with its host (and port, when given) replaced.
+func withHost(template, host, port string) string {
+	if template == "" || host == "" {
+		return ""
+	}
+	u, err := url.Parse(template)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if port == "" {
+		port = u.Port()
+	}
+	if port != "" {
+		u.Host = net.JoinHostPort(host, port)
+	} else {
+		u.Host = host
+	}
+	return u.String()
+}
+
+// firstString returns the first non-empty value among keys, formatting numbers (JSON ports) as text.
+func firstString(m map[string]interface{}, keys ...string) string {
+	for _, k := range keys {
+		switch v := m[k].(type) {
+		case string:
+			if s := strings.TrimSpace(v); s != "" {
+				return s
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+)
+
+type stubLister struct{ entries []map[string]interface{} }
+
+func (s stubLister) ServiceList(ctx context.Context) ([]map[string]interface{}, error) {
+	return s.entries, nil
+}
+
+func TestServiceRegistry_ResolvesConfiguredDiscoveredAndDerived(t *testing.T) {
+	template := "postgresql://tsdbadmin:pw@main01.proj9.tsdb.cloud.timescale.com:34567/tsdb?sslmode=require"
+	r := NewServiceRegistry(template, map[string]string{"main01": "postgres://u:p@main-host:5432/tsdb"})
+
+	n, err := r.Discover(context.Background(), stubLister{entries: []map[string]interface{}{
+		{"service_id": "fork7", "name": "fork-agent-7", "endpoint": map[string]interface{}{"host": "fork7.proj9.tsdb.cloud.timescale.com", "port": float64(30001)}},
+		{"service_id": "main01", "name": "main", "connection_string": "postgres://other"},
+		{"name": "no-id"},
+	}})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 discovered services, got %d (%v)", n, err)
+	}
+
+	main, err := r.Resolve("main01")
+	if err != nil || main.ConnURL != "postgres://u:p@main-host:5432/tsdb" || main.Name != "main" {
+		t.Fatalf("configured URL must win over discovery, got %+v (%v)", main, err)
+	}
+	fork, err := r.Resolve("fork-agent-7")
+	if err != nil || fork.ServiceID != "fork7" {
+		t.Fatalf("expected lookup by name, got %+v (%v)", fork, err)
+	}
+	if want := "postgresql://tsdbadmin:pw@fork7.proj9.tsdb.cloud.timescale.com:30001/tsdb?sslmode=require"; fork.ConnURL != want {
+		t.Errorf("discovered URL = %s, want %s", fork.ConnURL, want)
+	}
+	derived, err := r.Resolve("zz99")
+	if err != nil || derived.ConnURL != "postgresql://tsdbadmin:pw@zz99.proj9.tsdb.cloud.timescale.com:34567/tsdb?sslmode=require" {
+		t.Errorf("expected URL derived from the template host, got %+v (%v)", derived, err)
+	}
+
+	local := NewServiceRegistry("postgres://u:p@localhost:5432/db", nil)
+	if _, err := local.Resolve("zz99"); err == nil {
+		t.Error("expected unknown service outside Tiger Cloud to fail")
+	}
+}
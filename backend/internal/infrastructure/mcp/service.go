@@ -10,7 +10,3 @@ type ServiceInfo struct {
 	LastAccessed string   `json:"last_accessed,omitempty"`
 	Tables       []string `json:"tables,omitempty"`
 }
-
-// Legacy HTTP methods (kept for reference, all delegated to client.go CLI proxy methods)
-// These are now handled by the MCPClient CLI proxy implementation in client.go
-// CreateFork, CreateForkAtTimestamp, DeleteFork, ExecuteQuery are implemented in client.go
@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TimingMode selects where QueryResult.ExecutionTimeMs comes from.
+type TimingMode string
+
+const (
+	// TimingClient is wall-clock time in the client: query, network and row scanning.
+	TimingClient TimingMode = "client"
+	// TimingExplain runs read statements under EXPLAIN (ANALYZE, TIMING OFF, FORMAT JSON) and reports the
+	// server's Execution Time and Planning Time. Result rows are not returned, only RowCount.
+	TimingExplain TimingMode = "explain"
+	// TimingStatStatements runs the statement as is and reports the pg_stat_statements
+	// delta of total_exec_time / total_plan_time. Needs the pg_stat_statements extension.
+	TimingStatStatements TimingMode = "pg_stat_statements"
+)
+
+// ParseTimingMode maps a config value to a TimingMode; unknown or empty values are TimingClient.
+func ParseTimingMode(s string) TimingMode {
+	switch TimingMode(strings.ToLower(strings.TrimSpace(s))) {
+	case TimingExplain:
+		return TimingExplain
+	case TimingStatStatements, "stat_statements":
+		return TimingStatStatements
+	default:
+		return TimingClient
+	}
+}
+
+type timingModeKey struct{}
+
+// WithTimingMode asks ExecuteQuery to time queries run with ctx in the given mode.
+// Statements the mode cannot time (DDL under TimingExplain, or any statement when
+// pg_stat_statements is unavailable) fall back to client timing; QueryResult.TimingSource
+// tells which one was used.
+func WithTimingMode(ctx context.Context, mode TimingMode) context.Context {
+	return context.WithValue(ctx, timingModeKey{}, mode)
+}
+
+// TimingModeFrom returns the timing mode requested with WithTimingMode, TimingClient by default.
+func TimingModeFrom(ctx context.Context) TimingMode {
+	if m, ok := ctx.Value(timingModeKey{}).(TimingMode); ok {
+		return m
+	}
+	return TimingClient
+}
+
+// explainTimingPrefix wraps a read statement for TimingExplain; per-node timing is off so
+// instrumentation overhead stays out of the total.
+const explainTimingPrefix = "EXPLAIN (ANALYZE, TIMING OFF, FORMAT JSON) "
+
+// explainTimed runs sqlQuery under EXPLAIN (ANALYZE, TIMING OFF, FORMAT JSON).
+func explainTimed(ctx context.Context, conn *sql.Conn, sqlQuery string) (QueryResult, error) {
+	var raw string
+	stmt := explainTimingPrefix + strings.TrimRight(strings.TrimSpace(sqlQuery), "; \n\t")
+	if err := conn.QueryRowContext(ctx, stmt).Scan(&raw); err != nil {
+		return QueryResult{}, fmt.Errorf("mcp: query execution failed: %w", err)
+	}
+	return parseExplainTiming(raw)
+}
+
+// parseExplainTiming reads the row count and server timings out of an EXPLAIN (ANALYZE, FORMAT JSON) document.
+func parseExplainTiming(raw string) (QueryResult, error) {
+	var out []struct {
+		Plan struct {
+			ActualRows float64 `json:"Actual Rows"`
+		} `json:"Plan"`
+		PlanningTime  float64 `json:"Planning Time"`
+		ExecutionTime float64 `json:"Execution Time"`
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil || len(out) == 0 {
+		return QueryResult{}, fmt.Errorf("mcp: unreadable EXPLAIN output: %v", err)
+	}
+	return QueryResult{
+		RowCount:        int(out[0].Plan.ActualRows),
+		ExecutionTimeMs: out[0].ExecutionTime,
+		PlanningTimeMs:  out[0].PlanningTime,
+		TimingSource:    string(TimingExplain),
+	}, nil
+}
+
+const statStatementsSQL = `SELECT queryid, calls, total_exec_time, total_plan_time FROM pg_stat_statements
+	WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+		AND userid = (SELECT oid FROM pg_roles WHERE rolname = current_user)
+		AND query NOT LIKE '%pg_stat_statements%'`
+
+type statCounters struct {
+	calls          int64
+	execMs, planMs float64
+}
+
+// statStatementsTimed runs sqlQuery through run and attributes to it the pg_stat_statements entry
+// whose call count grew. The client timing is kept when the extension is unavailable, or when
+// none or several entries grew, e.g. because other sessions ran statements on the same database.
+func statStatementsTimed(ctx context.Context, conn *sql.Conn, run func() (QueryResult, error)) (QueryResult, error) {
+	before, err := readStatCounters(ctx, conn)
+	if err != nil {
+		return run()
+	}
+	res, err := run()
+	if err != nil {
+		return res, err
+	}
+	after, err := readStatCounters(ctx, conn)
+	if err != nil {
+		return res, nil
+	}
+	var hit *statCounters
+	for id, a := range after {
+		b := before[id]
+		if a.calls <= b.calls {
+			continue
+		}
+		if hit != nil {
+			return res, nil
+		}
+		hit = &statCounters{calls: a.calls - b.calls, execMs: a.execMs - b.execMs, planMs: a.planMs - b.planMs}
+	}
+	if hit == nil {
+		return res, nil
+	}
+	res.ExecutionTimeMs = hit.execMs / float64(hit.calls)
+	res.PlanningTimeMs = hit.planMs / float64(hit.calls)
+	res.TimingSource = string(TimingStatStatements)
+	return res, nil
+}
+
+func readStatCounters(ctx context.Context, conn *sql.Conn) (map[int64]statCounters, error) {
+	rows, err := conn.QueryContext(ctx, statStatementsSQL)
+	if err != nil {
+		return nil, fmt.Errorf("mcp: pg_stat_statements unavailable: %w", err)
+	}
+	defer rows.Close()
+	out := map[int64]statCounters{}
+	for rows.Next() {
+		var id sql.NullInt64
+		var c statCounters
+		if err := rows.Scan(&id, &c.calls, &c.execMs, &c.planMs); err != nil {
+			return nil, fmt.Errorf("mcp: failed to read pg_stat_statements: %w", err)
+		}
+		if id.Valid {
+			out[id.Int64] = c
+		}
+	}
+	return out, rows.Err()
+}
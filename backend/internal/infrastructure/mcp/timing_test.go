@@ -0,0 +1,38 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTimingModes(t *testing.T) {
+	if TimingModeFrom(context.Background()) != TimingClient {
+		t.Error("expected client timing by default")
+	}
+	if got := TimingModeFrom(WithTimingMode(context.Background(), TimingExplain)); got != TimingExplain {
+		t.Errorf("expected explain timing, got %s", got)
+	}
+	for in, want := range map[string]TimingMode{"EXPLAIN": TimingExplain, "pg_stat_statements": TimingStatStatements, "": TimingClient, "bogus": TimingClient} {
+		if got := ParseTimingMode(in); got != want {
+			t.Errorf("ParseTimingMode(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for q, want := range map[string]bool{
+		"SELECT * FROM orders":                                   true,
+		"  with t as (select 1) select * from t":                 true,
+		"SELECT last_update, deleted FROM orders":                true,
+		"SELECT * FROM audit WHERE action = 'DELETE FROM t'":     true,
+		"VALUES (1), (2)":                                        true,
+		"CREATE INDEX idx ON orders(status)":                     false,
+		"WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d":  false,
+		"WITH d AS (\n  update t SET x = 1 RETURNING *) TABLE d": false,
+		"SELECT * INTO backup FROM orders":                       false,
+		"SELECT * FROM orders FOR NO KEY UPDATE":                 false,
+		"SELECT 1; DELETE FROM orders":                           false,
+		"EXPLAIN SELECT 1":                                       false,
+	} {
+		if got := ReadOnlyQuery(q); got != want {
+			t.Errorf("ReadOnlyQuery(%q) = %v, want %v", q, got, want)
+		}
+	}
+}
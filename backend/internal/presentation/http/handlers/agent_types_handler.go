@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+// AgentTypesHandler expone el registro de tipos de agente.
+type AgentTypesHandler struct {
+	Cfg *cfgpkg.Config
+}
+
+func NewAgentTypesHandler(cfg *cfgpkg.Config) *AgentTypesHandler {
+	return &AgentTypesHandler{Cfg: cfg}
+}
+
+// GET /api/v1/agents/types
+// Público: solo expone tipo, especialización, modelo y alias; los prompts quedan fuera.
+func (h *AgentTypesHandler) ListTypes(c *fiber.Ctx) error {
+	var cfg *cfgpkg.Config
+	if h != nil { cfg = h.Cfg }
+	kinds := agents.Kinds()
+	resp := make([]fiber.Map, 0, len(kinds))
+	for _, k := range kinds {
+		aliases := k.Aliases
+		if aliases == nil { aliases = []string{} }
+		resp = append(resp, fiber.Map{
+			"type":           k.Type,
+			"specialization": k.Specialization,
+			"model":          k.Model(cfg),
+			"aliases":        aliases,
+		})
+	}
+	return c.JSON(fiber.Map{"data": resp})
+}
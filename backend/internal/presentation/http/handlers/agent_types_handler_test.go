@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+)
+
+func TestAgentTypesHandler_ListTypes(t *testing.T) {
+	cfg := &cfgpkg.Config{}
+	cfg.VertexAI.ModelOperativo = "custom-flash"
+	app := fiber.New()
+	app.Get("/api/v1/agents/types", NewAgentTypesHandler(cfg).ListTypes)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/v1/agents/types", nil))
+	if err != nil || resp.StatusCode != 200 { t.Fatalf("unexpected response: %v", err) }
+	var body struct{ Data []map[string]any `json:"data"` }
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil { t.Fatalf("invalid json: %v", err) }
+	models := map[string]any{}
+	for _, d := range body.Data { models[d["type"].(string)] = d["model"] }
+	if models["cerebro"] != "gemini-2.5-pro" || models["operativo"] != "custom-flash" || models["bulk"] != "gemini-2.0-flash" {
+		t.Fatalf("unexpected models: %v", models)
+	}
+	for _, d := range body.Data {
+		if len(d) != 4 || d["prompts"] != nil { t.Fatalf("expected only type, specialization, model and aliases: %v", d) }
+	}
+}
@@ -110,8 +110,8 @@ func (h *MetricsHandler) GetAgentMetrics(c *fiber.Ctx) error {
 		SELECT 
 			ae.agent_type,
 			COUNT(*) as total_tasks,
-			COALESCE(AVG(CASE WHEN ae.status = 'completed' THEN 1.0 ELSE 0.0 END) * 100, 0) as success_rate,
-			COALESCE(AVG(EXTRACT(EPOCH FROM (ae.completed_at - ae.started_at))), 0) as avg_duration
+			COALESCE(AVG(CASE WHEN ae.status = 'completed' THEN 1.0 ELSE 0.0 END) FILTER (WHERE ae.status IN ('completed', 'failed')) * 100, 0) as success_rate,
+			COALESCE(AVG(ae.duration_ms / 1000.0) FILTER (WHERE ae.status IN ('completed', 'failed')), 0) as avg_duration
 		FROM agent_executions ae
 		WHERE ae.started_at IS NOT NULL
 		GROUP BY ae.agent_type
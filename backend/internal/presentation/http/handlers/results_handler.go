@@ -35,14 +35,18 @@ func (h *ResultsHandler) ListAllAgents(c *fiber.Ctx) error {
 	resp := make([]fiber.Map, 0, len(execs))
 	for _, e := range execs {
 		m := fiber.Map{
-			"id":           e.ID,
-			"task_id":      e.TaskID,
-			"agent_type":   e.AgentType,
-			"fork_id":      e.ForkID,
-			"status":       e.Status,
-			"started_at":   e.StartedAt.Format(time.RFC3339),
-			"completed_at": nil,
-			"error":        e.ErrorMsg,
+			"id":            e.ID,
+			"task_id":       e.TaskID,
+			"agent_type":    e.AgentType,
+			"fork_id":       e.ForkID,
+			"status":        e.Status,
+			"started_at":    e.StartedAt.Format(time.RFC3339),
+			"completed_at":  nil,
+			"error":         e.ErrorMsg,
+			"phase":         e.Phase,
+			"duration_ms":   e.DurationMS,
+			"input_tokens":  e.InputTokens,
+			"output_tokens": e.OutputTokens,
 		}
 		if e.CompletedAt != nil {
 			m["completed_at"] = e.CompletedAt.Format(time.RFC3339)
@@ -68,14 +72,18 @@ func (h *ResultsHandler) GetTaskAgents(c *fiber.Ctx) error {
 	resp := make([]fiber.Map, 0, len(execs))
 	for _, e := range execs {
 		m := fiber.Map{
-			"id":           e.ID,
-			"task_id":      e.TaskID,
-			"agent_type":   e.AgentType,
-			"fork_id":      e.ForkID,
-			"status":       e.Status,
-			"started_at":   e.StartedAt.Format(time.RFC3339),
-			"completed_at": nil,
-			"error":        e.ErrorMsg,
+			"id":            e.ID,
+			"task_id":       e.TaskID,
+			"agent_type":    e.AgentType,
+			"fork_id":       e.ForkID,
+			"status":        e.Status,
+			"started_at":    e.StartedAt.Format(time.RFC3339),
+			"completed_at":  nil,
+			"error":         e.ErrorMsg,
+			"phase":         e.Phase,
+			"duration_ms":   e.DurationMS,
+			"input_tokens":  e.InputTokens,
+			"output_tokens": e.OutputTokens,
 			"links": fiber.Map{
 				"proposals": "/api/v1/tasks/" + strconv.Itoa(id) + "/proposals",
 			},
@@ -141,16 +149,17 @@ func (h *ResultsHandler) GetProposalBenchmarks(c *fiber.Ctx) error {
 	resp := make([]fiber.Map, 0, len(bms))
 	for _, b := range bms {
 		m := fiber.Map{
-			"id":                b.ID,
-			"proposal_id":       b.ProposalID,
-			"query_name":        b.QueryName,
-			"query_executed":    b.QueryExecuted,
-			"execution_time_ms": b.ExecutionTimeMS,
-			"rows_returned":     b.RowsReturned,
-			"explain_plan":      b.ExplainPlan,
-			"storage_impact_mb": b.StorageImpactMB,
-			"created_at":        b.CreatedAt.Format(time.RFC3339),
+			"id":            b.ID,
+			"proposal_id":   b.ProposalID,
+			"query_name":    b.QueryName,
+			"query_executed":b.QueryExecuted,
+			"execution_time_ms":b.ExecutionTimeMS,
+			"rows_returned": b.RowsReturned,
+			"explain_plan":  b.ExplainPlan,
+			"storage_impact_mb":b.StorageImpactMB,
+			"created_at":    b.CreatedAt.Format(time.RFC3339),
 		}
+		if b.Stats.Iterations > 0 { m["stats"] = b.Stats }
 		resp = append(resp, m)
 	}
 	return c.JSON(fiber.Map{"data": resp})
@@ -176,9 +185,10 @@ func (h *ResultsHandler) GetTaskConsensus(c *fiber.Ctx) error {
 		"id":                  d.ID,
 		"task_id":             d.TaskID,
 		"winning_proposal_id": winning,
-		"all_scores":          d.AllScores,
+		"all_scores":          d.ProposalScores,
 		"decision_rationale":  d.DecisionRationale,
 		"applied_to_main":     d.AppliedToMain,
+		"apply_report":        d.ApplyReport,
 		"created_at":          d.CreatedAt.Format(time.RFC3339),
 	})
 }
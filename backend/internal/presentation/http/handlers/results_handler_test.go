@@ -116,8 +116,8 @@ func (f *fakeConsRepo) GetByTaskID(_ context.Context, taskID int) (*entities.Con
         ID: 200,
         TaskID: int64(taskID),
         WinningProposalID: nil,
-        AllScores: map[values.AgentType]entities.ProposalScore{
-            values.AgentCerebro: {ProposalID: 10, Performance: 90, Storage: 80, Complexity: 90, Risk: 90},
+        ProposalScores: map[int64]entities.ProposalScore{
+            10: {ProposalID: 10, AgentExecutionID: 1, Performance: 90, Storage: 80, Complexity: 90, Risk: 90},
         },
         DecisionRationale: "ok",
         AppliedToMain: false,
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ type TaskHandler struct {
 	TaskService    *usecases.TaskService
 	TaskProcessor  *usecases.TaskProcessor
 	Hub            *usecases.Hub
+	Queue          *usecases.WorkerPool // durable queue; when nil tasks are processed in a goroutine
 }
 
 func NewTaskHandler(svc *usecases.TaskService, processor *usecases.TaskProcessor, hub *usecases.Hub) *TaskHandler {
@@ -142,17 +144,7 @@ func (h *TaskHandler) CreateTask(c *fiber.Ctx) error {
 		})
 	}
 
-	// Procesar tarea asíncronamente
-	if h.TaskProcessor != nil {
-		go func(taskID int64) {
-			ctx := context.Background()
-			if err := h.TaskProcessor.ProcessTask(ctx, taskID); err != nil {
-				// Log error pero no fallar el request HTTP
-				// El error se reflejará en el estado de la tarea
-				println("Error processing task", taskID, ":", err.Error())
-			}
-		}(int64(created.ID))
-	}
+	h.startTask(c.Context(), int64(created.ID))
 
 	return c.Status(fiber.StatusCreated).JSON(mapEntityToResponse(created))
 }
@@ -309,3 +301,155 @@ func (h *TaskHandler) DeleteTask(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusNoContent).Send(nil)
 }
+
+type rejectTaskRequest struct {
+	Reason string `json:"reason"`
+}
+
+// POST /api/v1/tasks/:id/approve (admin)
+// Approves applying the winning proposal of a task in awaiting_approval. The task is queued again
+// and a worker runs the apply; its outcome arrives as optimization_applied and task_completed/task_failed events.
+func (h *TaskHandler) ApproveTask(c *fiber.Ctx) error {
+	id, status, errBody := h.approvalTarget(c)
+	if errBody != nil {
+		return c.Status(status).JSON(errBody)
+	}
+	task, err := h.TaskProcessor.ApproveTask(c.Context(), int64(id), approverOf(c))
+	if err != nil {
+		return approvalError(c, err)
+	}
+	h.startTask(c.Context(), task.ID)
+	return c.Status(fiber.StatusAccepted).JSON(mapEntityToResponse(task))
+}
+
+// POST /api/v1/tasks/:id/reject (admin)
+// Rejects the winning proposal: main is left untouched and the task completes unapplied.
+func (h *TaskHandler) RejectTask(c *fiber.Ctx) error {
+	id, status, errBody := h.approvalTarget(c)
+	if errBody != nil {
+		return c.Status(status).JSON(errBody)
+	}
+	var req rejectTaskRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fiber.Map{
+					"code":      "VALIDATION_ERROR",
+					"message":   "Invalid request body",
+					"details":   fiber.Map{"body": "cannot parse JSON"},
+					"timestamp": time.Now().UTC().Format(time.RFC3339),
+				},
+			})
+		}
+	}
+	task, err := h.TaskProcessor.RejectTask(c.Context(), int64(id), approverOf(c), strings.TrimSpace(req.Reason))
+	if err != nil {
+		return approvalError(c, err)
+	}
+	return c.JSON(mapEntityToResponse(task))
+}
+
+// POST /api/v1/tasks/:id/cancel
+// Cancels a pending, in_progress or awaiting_approval task. A running pipeline stops its MCP
+// queries and LLM calls and releases its forks in the background; the task is cancelled at once.
+func (h *TaskHandler) CancelTask(c *fiber.Ctx) error {
+	id, status, errBody := h.approvalTarget(c)
+	if errBody != nil {
+		return c.Status(status).JSON(errBody)
+	}
+	task, err := h.TaskProcessor.CancelTask(c.Context(), int64(id), approverOf(c))
+	if err != nil {
+		return approvalError(c, err)
+	}
+	return c.JSON(mapEntityToResponse(task))
+}
+
+// POST /api/v1/tasks/:id/retry
+// Retries a failed task from its first stage that did not complete: the stages completed before
+// the failure are not run again. The task goes back to pending and is processed in the background.
+func (h *TaskHandler) RetryTask(c *fiber.Ctx) error {
+	id, status, errBody := h.approvalTarget(c)
+	if errBody != nil {
+		return c.Status(status).JSON(errBody)
+	}
+	task, err := h.TaskProcessor.RetryTask(c.Context(), int64(id), approverOf(c))
+	if err != nil {
+		return approvalError(c, err)
+	}
+	h.startTask(c.Context(), task.ID)
+	return c.Status(fiber.StatusAccepted).JSON(mapEntityToResponse(task))
+}
+
+// startTask queues a pending or approved task, or processes it in a goroutine without a queue.
+func (h *TaskHandler) startTask(ctx context.Context, taskID int64) {
+	// Encolar la tarea; si falla, la recuperación del pool la encola (sigue en pending o in_progress)
+	if h.Queue != nil {
+		if err := h.Queue.Enqueue(ctx, taskID); err != nil {
+			println("Error enqueueing task", taskID, ":", err.Error())
+		}
+	} else if h.TaskProcessor != nil {
+		// Procesar tarea asíncronamente
+		go func(taskID int64) {
+			ctx := context.Background()
+			if err := h.TaskProcessor.ProcessTask(ctx, taskID); err != nil {
+				// Log error pero no fallar el request HTTP
+				// El error se reflejará en el estado de la tarea
+				println("Error processing task", taskID, ":", err.Error())
+			}
+		}(taskID)
+	}
+}
+
+// approvalTarget validates the processor and the :id parameter; errBody is the error response otherwise.
+func (h *TaskHandler) approvalTarget(c *fiber.Ctx) (id int, status int, errBody fiber.Map) {
+	if h == nil || h.TaskProcessor == nil {
+		return 0, fiber.StatusServiceUnavailable, fiber.Map{
+			"error": fiber.Map{
+				"code":      "SERVICE_UNAVAILABLE",
+				"message":   "Task processor not available",
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+			},
+		}
+	}
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil || id <= 0 {
+		return 0, fiber.StatusBadRequest, fiber.Map{
+			"error": fiber.Map{
+				"code":      "VALIDATION_ERROR",
+				"message":   "Invalid task ID",
+				"timestamp": time.Now().UTC().Format(time.RFC3339),
+			},
+		}
+	}
+	return id, 0, nil
+}
+
+func approverOf(c *fiber.Ctx) string {
+	if email, ok := c.Locals("user_email").(string); ok && email != "" {
+		return email
+	}
+	if id, ok := c.Locals("user_id").(int); ok {
+		return "user:" + strconv.Itoa(id)
+	}
+	return "unknown"
+}
+
+func approvalError(c *fiber.Ctx, err error) error {
+	status, code := fiber.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, usecases.ErrTaskNotFound):
+		status, code = fiber.StatusNotFound, "TASK_NOT_FOUND"
+	case errors.Is(err, usecases.ErrTaskNotAwaitingApproval), errors.Is(err, usecases.ErrTaskNotCancellable),
+		errors.Is(err, usecases.ErrTaskNotRetryable):
+		status, code = fiber.StatusConflict, "INVALID_TASK_STATUS"
+	case errors.Is(err, usecases.ErrApplyDisabled):
+		status, code = fiber.StatusConflict, "APPLY_DISABLED"
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error": fiber.Map{
+			"code":      code,
+			"message":   err.Error(),
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+}
@@ -44,7 +44,7 @@ func TestListTasks_RepoError(t *testing.T) {
     app := fiber.New()
     r := &errRepo{}
     svc := usecases.NewTaskService(r)
-    h := NewTaskHandler(svc, nil)
+    h := NewTaskHandler(svc, nil, nil)
     app.Get("/api/v1/tasks", h.ListTasks)
 
     req := httptest.NewRequest("GET", "/api/v1/tasks", nil)
@@ -78,6 +78,7 @@ func (f *fakeTaskRepo) List(ctx context.Context, filters entities.TaskFilters) (
 	return f.stored, nil
 }
 func (f *fakeTaskRepo) Update(ctx context.Context, t *entities.Task) error { return nil }
+func (f *fakeTaskRepo) Delete(ctx context.Context, id int) error { return nil }
 
 // Ensure fake implements interface at compile time
 var _ domainif.TaskRepository = (*fakeTaskRepo)(nil)
@@ -91,7 +92,7 @@ func (e *errRepo) List(ctx context.Context, filters entities.TaskFilters) ([]*en
 func newTaskHandlerWithFake() *TaskHandler {
 	repo := &fakeTaskRepo{}
 	svc := usecases.NewTaskService(repo)
-	return NewTaskHandler(svc, nil)
+	return NewTaskHandler(svc, nil, nil)
 }
 
 func TestCreateTask_HappyPath(t *testing.T) {
@@ -3,13 +3,14 @@ package routes
 import (
     "github.com/gofiber/fiber/v2"
     websocket "github.com/gofiber/websocket/v2"
+    "github.com/tuusuario/afs-challenge/internal/domain/entities"
     "github.com/tuusuario/afs-challenge/internal/presentation/http/handlers"
     "github.com/tuusuario/afs-challenge/internal/presentation/http/middleware"
     "github.com/tuusuario/afs-challenge/internal/usecases"
 )
 
 // SetupRoutes configures all application routes
-func SetupRoutes(app *fiber.App, hub *usecases.Hub, taskH *handlers.TaskHandler, resH *handlers.ResultsHandler, authH *handlers.AuthHandler, authSvc *usecases.AuthService, metricsH *handlers.MetricsHandler) {
+func SetupRoutes(app *fiber.App, hub *usecases.Hub, taskH *handlers.TaskHandler, resH *handlers.ResultsHandler, authH *handlers.AuthHandler, authSvc *usecases.AuthService, metricsH *handlers.MetricsHandler, agentTypesH *handlers.AgentTypesHandler) {
     // ============================================
     // Health Check Endpoints
     // ============================================
@@ -56,6 +57,11 @@ func SetupRoutes(app *fiber.App, hub *usecases.Hub, taskH *handlers.TaskHandler,
     api.Get("/tasks/:id/agents", resH.GetTaskAgents)
     api.Get("/tasks/:id/proposals", resH.GetTaskProposals)
     api.Get("/tasks/:id/consensus", resH.GetTaskConsensus)
+    // Approval gate: only admins decide whether a winning proposal touches the main database
+    api.Post("/tasks/:id/approve", middleware.AuthMiddleware(authSvc), middleware.RequireRole(string(entities.RoleAdmin)), taskH.ApproveTask)
+    api.Post("/tasks/:id/reject", middleware.AuthMiddleware(authSvc), middleware.RequireRole(string(entities.RoleAdmin)), taskH.RejectTask)
+    api.Post("/tasks/:id/cancel", middleware.AuthMiddleware(authSvc), taskH.CancelTask)
+    api.Post("/tasks/:id/retry", middleware.AuthMiddleware(authSvc), taskH.RetryTask)
 
     // ============================================
     // Proposals
@@ -67,6 +73,7 @@ func SetupRoutes(app *fiber.App, hub *usecases.Hub, taskH *handlers.TaskHandler,
     // ============================================
     agents := api.Group("/agents")
     agents.Get("/", resH.ListAllAgents)
+    agents.Get("/types", agentTypesH.ListTypes)
     agents.Get("/:type/status", func(c *fiber.Ctx) error {
         return c.JSON(fiber.Map{"message": "Agent status - TODO", "type": c.Params("type")})
     })
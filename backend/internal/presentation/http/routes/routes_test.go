@@ -12,7 +12,7 @@ import (
 func TestRoutes_RootAnd404(t *testing.T) {
 	app := fiber.New()
 	// minimal handlers for wiring
-	SetupRoutes(app, nil, &handlers.TaskHandler{}, &handlers.ResultsHandler{})
+	SetupRoutes(app, nil, &handlers.TaskHandler{}, &handlers.ResultsHandler{}, &handlers.AuthHandler{}, nil, &handlers.MetricsHandler{}, &handlers.AgentTypesHandler{})
 
 	req := httptest.NewRequest("GET", "/api/v1/", nil)
 	resp, err := app.Test(req)
@@ -25,4 +25,8 @@ func TestRoutes_RootAnd404(t *testing.T) {
 	req = httptest.NewRequest("GET", "/does-not-exist", nil)
 	resp, _ = app.Test(req)
 	if resp.StatusCode != 404 { t.Fatalf("expected 404, got %d", resp.StatusCode) }
+
+	req = httptest.NewRequest("GET", "/api/v1/agents/types", nil)
+	resp, _ = app.Test(req)
+	if resp.StatusCode != 200 { t.Fatalf("expected 200 for agent types, got %d", resp.StatusCode) }
 }
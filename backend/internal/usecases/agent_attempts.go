@@ -0,0 +1,135 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+// proposeWithRetries runs the propose → benchmark loop of one agent on its fork.
+// Each attempt yields the agent's ranked candidates, which are benchmarked one by one in
+// rank order. When no candidate of an attempt is accepted (LLM output rejected, SQL error
+// on the fork, or no significant improvement), every candidate's feedback is handed back
+// to the agent, up to MaxAttempts proposal rounds in total. Every candidate of every
+// attempt is recorded under execID.
+// Returns the benchmarked candidates of the attempt whose best candidate measured the highest
+// improvement (the earliest one on ties, so an accepted attempt always wins); the error is only
+// returned when no candidate completed a benchmark. Revisions run on the restored fork too.
+// The fork is restored to its pre-task schema after every candidate, so each one is benchmarked
+// against the same baseline; a candidate whose statements cannot be undone fails and stops the loop.
+// Temporary proposal IDs are allocated upwards from tempID. phase is PhaseBenchmark once any
+// candidate ran on the fork, PhaseProposal otherwise.
+func (o *Orchestrator) proposeWithRetries(ctx context.Context, ag Agent, task *entities.Task, analysis AnalysisResult, forkID string, execID, tempID int64) (_ []*entities.OptimizationProposal, _ []*entities.BenchmarkResult, phase entities.ExecutionPhase, _ error) {
+	phase = entities.PhaseProposal
+	var (
+		best     []*entities.OptimizationProposal
+		bestRes  []*entities.BenchmarkResult
+		bestGain = -1.0
+		lastErr  error
+	)
+	nextID := tempID
+	for n := 1; n <= o.maxAttempts(); n++ {
+		if err := ctx.Err(); err != nil {
+			if lastErr == nil { lastErr = err }
+			break
+		}
+		cands, err := ag.ProposeOptimization(ctx, analysis, forkID)
+		if err != nil {
+			lastErr = err
+			att := &entities.AgentAttempt{AgentExecutionID: execID, Attempt: n, Outcome: entities.AttemptFailed, Feedback: "proposal rejected: " + err.Error(), CreatedAt: time.Now().UTC()}
+			o.recordAttempt(ctx, att)
+			analysis.PriorAttempts = append(analysis.PriorAttempts, agents.PriorAttempt{Attempt: n, Feedback: att.Feedback})
+			continue
+		}
+		var (
+			kept     []*entities.OptimizationProposal
+			keptRes  []*entities.BenchmarkResult
+			gain     float64
+			accepted bool
+			dirty    bool
+			prior    []agents.PriorAttempt
+		)
+		for i, prop := range cands {
+			rank := i + 1
+			prop.AgentExecutionID = execID
+			prop.ID = nextID
+			nextID++
+			if prop.EstimatedImpact.AgentRank == 0 { prop.EstimatedImpact.AgentRank = rank }
+			att := &entities.AgentAttempt{AgentExecutionID: execID, Attempt: n, Candidate: rank, ProposalType: prop.ProposalType, SQLCommands: prop.SQLCommands, CreatedAt: time.Now().UTC()}
+			fmt.Printf("      🔗 Prop AgentExecutionID=%d tempID=%d attempt=%d candidate=%d assigned\n", execID, prop.ID, n, rank)
+
+			o.Hygiene.Record(forkID, prop.SQLCommands)
+			phase = entities.PhaseBenchmark
+			res, err := ag.RunBenchmark(ctx, task, prop, forkID)
+			if rerr := o.resetFork(ctx, forkID); rerr != nil {
+				// the candidate's statements may still be on the fork: its numbers are not comparable
+				lastErr, dirty = rerr, true
+				att.Outcome = entities.AttemptFailed
+				att.Feedback = rerr.Error()
+			} else if err != nil {
+				lastErr = err
+				att.Outcome = entities.AttemptFailed
+				att.Feedback = "execution on fork failed: " + err.Error()
+			} else {
+				kept = append(kept, prop)
+				keptRes = append(keptRes, res...)
+				att.Feedback, att.ImprovementPct = benchmarkFeedback(prop, res)
+				if att.ImprovementPct > gain { gain = att.ImprovementPct }
+				att.Outcome = entities.AttemptNoImprovement
+				if att.Feedback == "" { att.Outcome = entities.AttemptAccepted; accepted = true }
+			}
+			o.recordAttempt(ctx, att)
+			if att.Outcome != entities.AttemptAccepted {
+				prior = append(prior, agents.PriorAttempt{Attempt: n, Candidate: rank, SQLCommands: prop.SQLCommands, Feedback: att.Feedback})
+			}
+			if dirty { break }
+		}
+		if len(kept) > 0 && gain > bestGain { best, bestRes, bestGain = kept, keptRes, gain }
+		if accepted || dirty { break }
+		analysis.PriorAttempts = append(analysis.PriorAttempts, prior...)
+	}
+	if len(best) == 0 {
+		if lastErr == nil { lastErr = errors.New("agent produced no proposal") }
+		return nil, nil, phase, lastErr
+	}
+	return best, bestRes, phase, nil
+}
+
+// benchmarkFeedback explains why a benchmarked proposal is not good enough, or returns ""
+// when it shows a significant improvement over the baseline. The improvement is measured
+// the same way the consensus engine scores performance.
+func benchmarkFeedback(p *entities.OptimizationProposal, res []*entities.BenchmarkResult) (string, float64) {
+	if reason := disqualification(p); reason != "" { return reason, 0 }
+	improve := (&ConsensusEngine{}).performanceScore(res)
+	if improve > 0 { return "", improve }
+	var base float64
+	for _, b := range res {
+		if b.QueryName == entities.QueryNameBaseline { base = b.RepresentativeTimeMS() }
+	}
+	return fmt.Sprintf("benchmark showed no significant improvement over the baseline (baseline %.2f ms)", base), 0
+}
+
+// resetFork undoes the statements of the candidate just benchmarked and opens a new journal,
+// so the next candidate starts from the pre-task schema. It runs even when ctx is cancelled.
+func (o *Orchestrator) resetFork(ctx context.Context, forkID string) error {
+	ctx = context.WithoutCancel(ctx)
+	if err := o.Hygiene.Restore(ctx, forkID); err != nil { return fmt.Errorf("fork restore failed: %w", err) }
+	return o.Hygiene.Begin(ctx, forkID)
+}
+
+func (o *Orchestrator) maxAttempts() int {
+	if o == nil || o.MaxAttempts < 1 { return 1 }
+	return o.MaxAttempts
+}
+
+// recordAttempt persists an attempt when an AttemptRepo is configured; failures only log.
+func (o *Orchestrator) recordAttempt(ctx context.Context, att *entities.AgentAttempt) {
+	if o == nil || o.AttemptRepo == nil { return }
+	if err := o.AttemptRepo.Create(ctx, att); err != nil {
+		fmt.Printf("Warning: failed to record attempt %d of execution %d: %v\n", att.Attempt, att.AgentExecutionID, err)
+	}
+}
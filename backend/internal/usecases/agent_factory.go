@@ -13,13 +13,13 @@ import (
 
 // AgentFactory crea instancias de agentes con las dependencias necesarias
 type AgentFactory struct {
-	MCPClient    *mcp.MCPClient
+	MCPClient    mcp.ForkProvider
 	AgentRepo    domainif.AgentExecutionRepository
 	Cfg          *cfgpkg.Config
 }
 
 // NewAgentFactory creates a new agent factory with required dependencies
-func NewAgentFactory(mcpClient *mcp.MCPClient, agentRepo domainif.AgentExecutionRepository, cfg *cfgpkg.Config) *AgentFactory {
+func NewAgentFactory(mcpClient mcp.ForkProvider, agentRepo domainif.AgentExecutionRepository, cfg *cfgpkg.Config) *AgentFactory {
 	return &AgentFactory{
 		MCPClient: mcpClient,
 		AgentRepo: agentRepo,
@@ -33,27 +33,12 @@ func (f *AgentFactory) CreateAgent(agentType values.AgentType) (agents.Agent, er
 		return nil, fmt.Errorf("agent factory not properly initialized")
 	}
 
-	// Determinar modelo según tipo de agente
-	var model string
-	switch agentType {
-	case values.AgentCerebro:
-		model = f.Cfg.VertexAI.ModelCerebro // gemini-2.5-pro
-		if model == "" {
-			model = "gemini-2.5-pro"
-		}
-	case values.AgentOperativo:
-		model = f.Cfg.VertexAI.ModelOperativo // gemini-2.5-flash
-		if model == "" {
-			model = "gemini-2.5-flash"
-		}
-	case values.AgentBulk:
-		model = f.Cfg.VertexAI.ModelBulk // gemini-2.0-flash
-		if model == "" {
-			model = "gemini-2.0-flash"
-		}
-	default:
+	// Determinar modelo según el tipo registrado (config override o default del kind)
+	kind, ok := agents.LookupKind(agentType)
+	if !ok {
 		return nil, fmt.Errorf("unknown agent type: %s", agentType)
 	}
+	model := kind.Model(f.Cfg)
 
 	// Crear cliente LLM para este agente
 	llmClient, err := llm.NewVertexClient(f.Cfg, model, nil)
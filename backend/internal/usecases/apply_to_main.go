@@ -0,0 +1,175 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// mainApplyTimeoutMs bounds one apply or rollback statement on the main database.
+const mainApplyTimeoutMs = 600000
+
+// applyBatchTag quotes the DO block that runs a transactional batch; statements using $afs_ quote tags are refused.
+const applyBatchTag = "$afs_apply$"
+
+var (
+	// ErrApplyBlocked: the pre-flight check found a lock the apply would queue behind.
+	ErrApplyBlocked = errors.New("apply blocked by pre-flight check")
+	// ErrApplyRegressed: the task query got significantly slower on main after the apply.
+	ErrApplyRegressed = errors.New("task query regressed after apply")
+)
+
+// ApplyOptions control how a winning proposal is applied to the main database.
+type ApplyOptions struct {
+	Enabled              bool          // ProcessTask applies the winner to main; off by default
+	RequireApproval      bool          // the task waits in awaiting_approval until an admin approves the apply
+	LockTimeout          time.Duration // lock_timeout of transactional statements; older conflicting transactions block the apply
+	ConcurrentIndexBytes int64         // CREATE INDEX on tables at least this large is rewritten to CONCURRENTLY
+	RegressionTolerance  float64       // significant slowdown of the median, as a fraction, tolerated before rolling back
+	Benchmark            agents.BenchmarkOptions
+}
+
+// DefaultApplyOptions: disabled, approval required, 5s lock timeout, CONCURRENTLY from 100MB, 5% regression tolerance.
+func DefaultApplyOptions() ApplyOptions {
+	return ApplyOptions{RequireApproval: true, LockTimeout: 5 * time.Second, ConcurrentIndexBytes: 100 << 20, RegressionTolerance: 0.05, Benchmark: agents.DefaultBenchmarkOptions()}
+}
+
+// ApplyOptionsFromConfig reads the apply settings, falling back to defaults.
+func ApplyOptionsFromConfig(cfg *cfgpkg.Config) ApplyOptions {
+	if cfg == nil { return DefaultApplyOptions() }
+	return ApplyOptions{
+		Enabled:              cfg.Apply.Enabled,
+		RequireApproval:      cfg.Apply.RequireApproval,
+		LockTimeout:          time.Duration(cfg.Apply.LockTimeoutMS) * time.Millisecond,
+		ConcurrentIndexBytes: int64(cfg.Apply.ConcurrentIndexMB) << 20,
+		RegressionTolerance:  cfg.Apply.RegressionTolerance,
+		Benchmark:            agents.BenchmarkOptionsFromConfig(cfg),
+	}.normalized()
+}
+
+func (o ApplyOptions) normalized() ApplyOptions {
+	def := DefaultApplyOptions()
+	if o.LockTimeout <= 0 { o.LockTimeout = def.LockTimeout }
+	if o.ConcurrentIndexBytes <= 0 { o.ConcurrentIndexBytes = def.ConcurrentIndexBytes }
+	if o.RegressionTolerance < 0 { o.RegressionTolerance = def.RegressionTolerance }
+	if o.Benchmark.MeasuredRuns <= 0 { o.Benchmark = def.Benchmark }
+	return o
+}
+
+// ApplyToMainDB aplica la propuesta ganadora en el servicio principal (main DB) vía MCP.
+// Runs the pre-flight check (locks, table sizes, CREATE INDEX rewritten to CONCURRENTLY), generates
+// the rollback script from a schema snapshot, times query on main, applies the statements (in one
+// transaction when the DDL allows it) and times query again. A failed apply or a significant
+// regression of the task query runs the rollback script and verifies the schema fingerprint.
+// The report is returned whenever the pre-flight started; its Status tells what happened to main.
+func (o *Orchestrator) ApplyToMainDB(ctx context.Context, mainService string, winning *entities.OptimizationProposal, query string) (*entities.ApplyReport, error) {
+	if o == nil || o.MCPClient == nil { return nil, errors.New("orchestrator not initialized") }
+	stmts := agents.ProposalStatements(winning)
+	if len(stmts) == 0 { return nil, errors.New("invalid winning proposal") }
+	if mainService == "" { return nil, errors.New("main service required") }
+	opts := o.Apply.normalized()
+	report := &entities.ApplyReport{StartedAt: time.Now().UTC()}
+
+	plan, err := agents.PlanApply(ctx, o.MCPClient, mainService, stmts, agents.PreflightOptions{
+		ConcurrentIndexBytes: opts.ConcurrentIndexBytes,
+		MaxLockWaitS:         opts.LockTimeout.Seconds(),
+	})
+	if err != nil { return finishApply(report, entities.ApplyFailed, err) }
+	report.Preflight = plan.Checks
+	for _, st := range plan.Statements { report.Statements = append(report.Statements, st.SQL) }
+	report.Transactional = plan.Transactional()
+	if plan.Blocked != "" { return finishApply(report, entities.ApplyBlocked, fmt.Errorf("%w: %s", ErrApplyBlocked, plan.Blocked)) }
+
+	before, err := agents.TakeSchemaSnapshot(ctx, o.MCPClient, mainService)
+	if err != nil { return finishApply(report, entities.ApplyFailed, err) }
+	report.RollbackScript = agents.RollbackScript(report.Statements, before)
+
+	verify := mcp.ReadOnlyQuery(query)
+	if verify {
+		stats, err := agents.MeasureQuery(ctx, o.MCPClient, mainService, query, opts.Benchmark)
+		if err != nil { return finishApply(report, entities.ApplyFailed, fmt.Errorf("pre-apply benchmark: %w", err)) }
+		report.Before = &stats
+	}
+
+	if err := o.executePlan(ctx, mainService, plan, opts.LockTimeout); err != nil {
+		return o.rollbackMain(ctx, mainService, report, before, err)
+	}
+
+	if verify {
+		stats, err := agents.MeasureQuery(ctx, o.MCPClient, mainService, query, opts.Benchmark)
+		if err != nil { return o.rollbackMain(ctx, mainService, report, before, fmt.Errorf("post-apply benchmark: %w", err)) }
+		report.After = &stats
+		report.Significance = agents.CompareRuns(*report.Before, stats, opts.Benchmark.Alpha)
+		report.Regressed = report.Significance != nil && report.Significance.Significant &&
+			stats.MedianMS > report.Before.MedianMS*(1+opts.RegressionTolerance)
+		if report.Regressed {
+			return o.rollbackMain(ctx, mainService, report, before,
+				fmt.Errorf("%w: median %.2fms -> %.2fms", ErrApplyRegressed, report.Before.MedianMS, stats.MedianMS))
+		}
+	}
+	return finishApply(report, entities.ApplyApplied, nil)
+}
+
+// executePlan runs the plan on main. Consecutive transactional statements run as one DO block,
+// so a batch applies atomically and each statement waits at most lockTimeout for its locks;
+// statements PostgreSQL refuses in a transaction (CREATE INDEX CONCURRENTLY) run on their own.
+func (o *Orchestrator) executePlan(ctx context.Context, mainService string, plan *agents.ApplyPlan, lockTimeout time.Duration) error {
+	for _, st := range plan.Statements {
+		if strings.Contains(st.SQL, "$afs_") { return fmt.Errorf("apply to %s: statement uses the reserved $afs_ quote tag", mainService) }
+	}
+	var batch []string
+	flush := func() error {
+		if len(batch) == 0 { return nil }
+		var b strings.Builder
+		fmt.Fprintf(&b, "DO %s BEGIN PERFORM set_config('lock_timeout', '%dms', true);", applyBatchTag, lockTimeout.Milliseconds())
+		for _, stmt := range batch { fmt.Fprintf(&b, " EXECUTE $afs_stmt$%s$afs_stmt$;", stmt) }
+		fmt.Fprintf(&b, " END %s", applyBatchTag)
+		batch = nil
+		if _, err := o.MCPClient.ExecuteQuery(ctx, mainService, b.String(), mainApplyTimeoutMs); err != nil {
+			return fmt.Errorf("apply to %s: %w", mainService, err)
+		}
+		return nil
+	}
+	for _, st := range plan.Statements {
+		if st.Transactional { batch = append(batch, st.SQL); continue }
+		if err := flush(); err != nil { return err }
+		if _, err := o.MCPClient.ExecuteQuery(ctx, mainService, st.SQL, mainApplyTimeoutMs); err != nil {
+			return fmt.Errorf("apply to %s: %s: %w", mainService, st.SQL, err)
+		}
+	}
+	return flush()
+}
+
+// rollbackMain runs the rollback script, even when ctx is already cancelled, and checks that the
+// schema matches its pre-apply fingerprint. The apply is reported failed when the rollback is not clean.
+func (o *Orchestrator) rollbackMain(ctx context.Context, mainService string, report *entities.ApplyReport, before *agents.SchemaSnapshot, cause error) (*entities.ApplyReport, error) {
+	rctx := context.WithoutCancel(ctx)
+	var problems []string
+	for _, stmt := range report.RollbackScript {
+		if _, err := o.MCPClient.ExecuteQuery(rctx, mainService, stmt, mainApplyTimeoutMs); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", stmt, err))
+		}
+	}
+	if now, err := agents.TakeSchemaSnapshot(rctx, o.MCPClient, mainService); err != nil {
+		problems = append(problems, err.Error())
+	} else if now.Fingerprint != before.Fingerprint {
+		problems = append(problems, "schema differs from its pre-apply fingerprint")
+	}
+	if len(problems) > 0 {
+		return finishApply(report, entities.ApplyFailed, fmt.Errorf("%w; rollback incomplete: %s", cause, strings.Join(problems, "; ")))
+	}
+	return finishApply(report, entities.ApplyRolledBack, cause)
+}
+
+func finishApply(report *entities.ApplyReport, status entities.ApplyStatus, err error) (*entities.ApplyReport, error) {
+	report.Status, report.FinishedAt = status, time.Now().UTC()
+	if err != nil { report.Error = err.Error() }
+	return report, err
+}
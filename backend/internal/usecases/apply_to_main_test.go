@@ -0,0 +1,78 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// mainMCP fakes the main service: the apply batch creates the index, the rollback drops it,
+// and the task query takes afterMs once the index exists.
+type mainMCP struct {
+	indexed  bool
+	afterMs  float64
+	executed []string
+}
+
+func (m *mainMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	switch {
+	case strings.Contains(sql, "pg_get_indexdef"):
+		rows := []map[string]any{{"kind": "table", "name": "public.orders", "def": ""}}
+		if m.indexed { rows = append(rows, map[string]any{"kind": "index", "name": "public.idx_status", "def": "CREATE INDEX idx_status ON public.orders USING btree (status)"}) }
+		return mcp.QueryResult{Rows: rows, RowCount: len(rows)}, nil
+	case strings.Contains(sql, "to_regclass"):
+		return mcp.QueryResult{}, nil
+	case strings.HasPrefix(sql, "DO "):
+		m.executed = append(m.executed, sql)
+		m.indexed = true
+	case strings.HasPrefix(sql, "DROP INDEX"):
+		m.executed = append(m.executed, sql)
+		m.indexed = false
+	case strings.HasPrefix(sql, "SELECT"):
+		if m.indexed { return mcp.QueryResult{ExecutionTimeMs: m.afterMs}, nil }
+		return mcp.QueryResult{ExecutionTimeMs: 10}, nil
+	}
+	return mcp.QueryResult{}, nil
+}
+
+func (m *mainMCP) CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error) { return "", nil }
+func (m *mainMCP) CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error) {
+	return "", nil
+}
+func (m *mainMCP) DeleteFork(ctx context.Context, serviceID string) error { return nil }
+
+func TestApplyToMainDB_VerifiesAndRollsBackRegressions(t *testing.T) {
+	winner := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX idx_status ON orders(status)", "ANALYZE orders"}}
+	query := "SELECT * FROM orders WHERE status = 'open'"
+	opts := DefaultApplyOptions()
+	opts.Benchmark = agents.BenchmarkOptions{WarmupRuns: 0, MeasuredRuns: 5, Alpha: 0.05, Timing: mcp.TimingClient}
+
+	m := &mainMCP{afterMs: 2}
+	orch := &Orchestrator{MCPClient: m, Apply: opts}
+	report, err := orch.ApplyToMainDB(context.Background(), "main", winner, query)
+	if err != nil || report.Status != entities.ApplyApplied {
+		t.Fatalf("expected the faster query to keep the apply, got %+v (%v)", report, err)
+	}
+	if !report.Transactional || len(m.executed) != 1 || !strings.Contains(m.executed[0], "set_config('lock_timeout', '5000ms', true)") {
+		t.Errorf("expected one transactional batch with a local lock_timeout, got %v", m.executed)
+	}
+	if len(report.RollbackScript) != 1 || report.RollbackScript[0] != "DROP INDEX CONCURRENTLY IF EXISTS idx_status" {
+		t.Errorf("unexpected rollback script %v", report.RollbackScript)
+	}
+
+	m = &mainMCP{afterMs: 40}
+	orch.MCPClient = m
+	report, err = orch.ApplyToMainDB(context.Background(), "main", winner, query)
+	if !errors.Is(err, ErrApplyRegressed) || report.Status != entities.ApplyRolledBack || !report.Regressed {
+		t.Fatalf("expected the regression to roll back, got %+v (%v)", report, err)
+	}
+	if m.indexed || report.After == nil || report.Significance == nil || !report.Significance.Significant {
+		t.Errorf("expected the index dropped and the comparison recorded, got indexed=%v %+v", m.indexed, report)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
 )
 
@@ -20,11 +21,12 @@ func TestBenchmarkRunner(t *testing.T) {
 	// 12 timings for 4 queries × 3 runs each; extra calls will be for applying SQL
 	m := &mockMCPRunner{times: []float64{12, 9, 15, 20, 22, 21, 30, 31, 29, 40, 39, 41}}
 	r := NewBenchmarkRunner(m)
+	r.Options = agents.BenchmarkOptions{WarmupRuns: 0, MeasuredRuns: 3}
 	prop := &entities.OptimizationProposal{ID: 1, SQLCommands: []string{"CREATE INDEX x ON orders(status)", "ANALYZE orders"}}
 	orig := "SELECT * FROM orders"
 	res, err := r.EvaluateProposal(context.Background(), prop, "fork-1", orig)
 	if err != nil { t.Fatalf("EvaluateProposal err: %v", err) }
-	if len(res) != 4 { t.Fatalf("expected 4 results, got %d", len(res)) }
+	if len(res) != 3 { t.Fatalf("expected 3 results, got %d", len(res)) }
 	// Validate first avg = (12+9+15)/3 = 12
 	if res[0].ExecutionTimeMS < 11.9 || res[0].ExecutionTimeMS > 12.1 {
 		t.Fatalf("unexpected avg for baseline: %v", res[0].ExecutionTimeMS)
@@ -3,7 +3,9 @@ package usecases
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
@@ -35,8 +37,9 @@ func (ce *ConsensusEngine) RunConsensus(ctx context.Context, taskID int64, propo
 }
 
 // Decide scores proposals given their benchmark results and criteria.
-// Assumption: proposals ordering maps to agent roles:
-//   0->cerebro, 1->operativo, 2->bulk (fallback operativo)
+// Every candidate is scored and ranked against all others. Scores are keyed by proposal ID and
+// carry the proposal's AgentExecutionID, so they stay with their agent whatever order the
+// proposals arrive in.
 func (ce *ConsensusEngine) Decide(ctx context.Context, proposals []*entities.OptimizationProposal, benchmarks []*entities.BenchmarkResult, criteria entities.ScoringCriteria) (*entities.ConsensusDecision, error) {
 	if len(proposals) == 0 {
 		return nil, errors.New("no proposals")
@@ -49,74 +52,116 @@ func (ce *ConsensusEngine) Decide(ctx context.Context, proposals []*entities.Opt
 		bmByProp[b.ProposalID] = append(bmByProp[b.ProposalID], b)
 	}
 
-	all := map[values.AgentType]entities.ProposalScore{}
-	type pair struct{ at values.AgentType; score entities.ProposalScore }
-	ordered := []pair{}
+	ordered := []entities.ProposalScore{}
+	notes := []string{}
 
-	for i, p := range proposals {
-		agentType := indexToAgentType(i)
+	for _, p := range proposals {
 		per := ce.performanceScore(bmByProp[p.ID])
-		stg := ce.storageScore(p)
+		stg := ce.storageScore(p, bmByProp[p.ID])
 		cpx := ce.complexityScore(p)
 		rk := ce.riskScore(p)
 		ts := entities.ProposalScore{
-			ProposalID:    p.ID,
-			Performance:   per,
-			Storage:       stg,
-			Complexity:    cpx,
-			Risk:          rk,
-			WeightedTotal: criteria.CalculateWeightedTotal(entities.ProposalScore{Performance: per, Storage: stg, Complexity: cpx, Risk: rk}),
+			ProposalID:       p.ID,
+			AgentExecutionID: p.AgentExecutionID,
+			Performance:      per,
+			Storage:          stg,
+			Complexity:       cpx,
+			Risk:             rk,
+			WeightedTotal:    criteria.CalculateWeightedTotal(entities.ProposalScore{Performance: per, Storage: stg, Complexity: cpx, Risk: rk}),
 		}
-		ordered = append(ordered, pair{agentType, ts})
+		if reason := disqualification(p); reason != "" {
+			ts.Disqualified = true
+			ts.DisqualifyReason = reason
+			ts.WeightedTotal = 0
+			notes = append(notes, fmt.Sprintf("proposal %d disqualified: %s", p.ID, reason))
+		}
+		ordered = append(ordered, ts)
 	}
 
-	// sort DESC by weighted_total, tie-break performance then storage
+	// sort DESC by weighted_total, tie-break performance then storage; disqualified always last
 	sort.Slice(ordered, func(i, j int) bool {
-		if ordered[i].score.WeightedTotal == ordered[j].score.WeightedTotal {
-			if ordered[i].score.Performance == ordered[j].score.Performance {
-				return ordered[i].score.Storage > ordered[j].score.Storage
+		if ordered[i].Disqualified != ordered[j].Disqualified {
+			return !ordered[i].Disqualified
+		}
+		if ordered[i].WeightedTotal == ordered[j].WeightedTotal {
+			if ordered[i].Performance == ordered[j].Performance {
+				return ordered[i].Storage > ordered[j].Storage
 			}
-			return ordered[i].score.Performance > ordered[j].score.Performance
+			return ordered[i].Performance > ordered[j].Performance
 		}
-		return ordered[i].score.WeightedTotal > ordered[j].score.WeightedTotal
+		return ordered[i].WeightedTotal > ordered[j].WeightedTotal
 	})
-	for i := range ordered { ordered[i].score.Rank = i+1 }
-
-	for _, pr := range ordered { all[pr.at] = pr.score }
+	byProposal := make(map[int64]entities.ProposalScore, len(ordered))
+	for i := range ordered {
+		ordered[i].Rank = i+1
+		byProposal[ordered[i].ProposalID] = ordered[i]
+	}
 
-	winnerID := ordered[0].score.ProposalID
 	dec := &entities.ConsensusDecision{
 		TaskID:            0,
-		WinningProposalID: &winnerID,
-		AllScores:         all,
+		ProposalScores:    byProposal,
 		DecisionRationale: "Selected highest weighted_total per criteria",
 		AppliedToMain:     false,
 		CreatedAt:         time.Now().UTC(),
 	}
+	if ordered[0].Disqualified {
+		dec.DecisionRationale = "No eligible proposal: all proposals were disqualified"
+	} else {
+		winnerID := ordered[0].ProposalID
+		dec.WinningProposalID = &winnerID
+	}
+	if len(notes) > 0 { dec.DecisionRationale += "; " + strings.Join(notes, "; ") }
 	return dec, nil
 }
 
+// performanceScore compares the baseline with the best post-apply result, using medians
+// when iteration statistics exist. Results whose significance test found no real
+// difference from the baseline are treated as noise and cannot earn a score.
 func (ce *ConsensusEngine) performanceScore(bms []*entities.BenchmarkResult) float64 {
 	if len(bms) == 0 { return 0 }
 	var baseline float64 = -1
 	best := 1e18
 	for _, b := range bms {
 		if b.QueryName == entities.QueryNameBaseline {
-			baseline = b.ExecutionTimeMS
-		} else if b.ExecutionTimeMS < best {
-			best = b.ExecutionTimeMS
+			baseline = b.RepresentativeTimeMS()
+		} else if b.IsNoise() {
+			continue
+		} else if t := b.RepresentativeTimeMS(); t < best {
+			best = t
 		}
 	}
-	if baseline <= 0 || best <= 0 { return 0 }
+	if baseline <= 0 || best <= 0 || best == 1e18 { return 0 }
 	improve := (baseline - best) / baseline * 100.0
 	if improve < 0 { improve = 0 }
 	if improve > 100 { improve = 100 }
 	return round2(improve)
 }
 
-func (ce *ConsensusEngine) storageScore(p *entities.OptimizationProposal) float64 {
+// disqualification returns why a proposal may not win, or "" when it is eligible.
+// query_rewrite proposals must carry a passing result-equivalence check from the benchmark stage.
+func disqualification(p *entities.OptimizationProposal) string {
+	if p.ProposalType != values.ProposalQueryRewrite { return "" }
+	eq := p.EstimatedImpact.Equivalence
+	if eq == nil { return "result equivalence not verified" }
+	if !eq.Equivalent {
+		if eq.Reason != "" { return "rewritten query is not equivalent: " + eq.Reason }
+		return "rewritten query is not equivalent"
+	}
+	return ""
+}
+
+// storageScore prefers the storage delta measured on the fork; the agent's
+// EstimatedImpact guess is only used when no benchmark carries a measurement.
+func (ce *ConsensusEngine) storageScore(p *entities.OptimizationProposal, bms []*entities.BenchmarkResult) float64 {
 	// Simple mapping: lower overhead → higher score
 	o := p.EstimatedImpact.StorageOverheadMB
+	measured := false
+	for _, b := range bms {
+		if mb, ok := b.MeasuredStorageMB(); ok {
+			if !measured || mb > o { o = mb }
+			measured = true
+		}
+	}
 	if o <= 0 { return 100 }
 	s := 100 - o
 	if s < 0 { s = 0 }
@@ -142,19 +187,6 @@ func (ce *ConsensusEngine) riskScore(p *entities.OptimizationProposal) float64 {
 	}
 }
 
-func indexToAgentType(i int) values.AgentType {
-	switch i {
-	case 0:
-		return values.AgentCerebro
-	case 1:
-		return values.AgentOperativo
-	case 2:
-		return values.AgentBulk
-	default:
-		return values.AgentOperativo
-	}
-}
-
 func stringsLower(s string) string {
 	b := []rune(s)
 	for i := range b {
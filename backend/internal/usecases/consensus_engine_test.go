@@ -2,6 +2,7 @@ package usecases
 
 import (
 	"context"
+	"strings"
 	"testing"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
@@ -12,11 +13,12 @@ func TestConsensus(t *testing.T) {
 	ce := NewConsensusEngine()
 	criteria := entities.ScoringCriteria{PerformanceWeight: 0.5, StorageWeight: 0.2, ComplexityWeight: 0.2, RiskWeight: 0.1}
 
-	// Proposals ordered: cerebro, operativo, bulk
-	p1 := &entities.OptimizationProposal{ID: 1, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 10, Risk: "low"}}
-	p2 := &entities.OptimizationProposal{ID: 2, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 40, Risk: "medium"}}
-	p3 := &entities.OptimizationProposal{ID: 3, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 50, Risk: "medium"}}
-	props := []*entities.OptimizationProposal{p1, p2, p3}
+	// One proposal per agent execution
+	p1 := &entities.OptimizationProposal{ID: 1, AgentExecutionID: 11, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 10, Risk: "low"}}
+	p2 := &entities.OptimizationProposal{ID: 2, AgentExecutionID: 12, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 40, Risk: "medium"}}
+	p3 := &entities.OptimizationProposal{ID: 3, AgentExecutionID: 13, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 50, Risk: "medium"}}
+	// Results arrive in whatever order the agents finished
+	props := []*entities.OptimizationProposal{p3, p1, p2}
 
 	// Benchmarks (baseline + best optimized) to hit target improvements
 	bms := []*entities.BenchmarkResult{
@@ -36,9 +38,9 @@ func TestConsensus(t *testing.T) {
 	if dec == nil { t.Fatalf("nil decision") }
 
 	// Extract scores
-	s1 := dec.AllScores[values.AgentCerebro]
-	s2 := dec.AllScores[values.AgentOperativo]
-	s3 := dec.AllScores[values.AgentBulk]
+	s1 := dec.ProposalScores[1]
+	s2 := dec.ProposalScores[2]
+	s3 := dec.ProposalScores[3]
 
 	// Expected totals from doc example (allow small rounding tolerance)
 	expect := func(got, want float64) {
@@ -50,8 +52,91 @@ func TestConsensus(t *testing.T) {
 	expect(s2.WeightedTotal, 78.5)
 	expect(s3.WeightedTotal, 66.5)
 
-	// Winner should be cerebro (rank 1)
-	if s1.Rank != 1 {
-		t.Fatalf("expected cerebro rank 1, got %d", s1.Rank)
+	// Winner should be proposal 1 (rank 1), still linked to its agent
+	if s1.Rank != 1 || s1.AgentExecutionID != 11 || s3.AgentExecutionID != 13 {
+		t.Fatalf("expected proposal 1 ranked first and every score linked to its agent, got %+v", dec.ProposalScores)
+	}
+}
+
+func TestConsensus_IgnoresInsignificantImprovements(t *testing.T) {
+	ce := NewConsensusEngine()
+	noise := &entities.Significance{Method: "mann_whitney_u_exact", PValue: 0.42, Alpha: 0.05, Significant: false}
+	real := &entities.Significance{Method: "mann_whitney_u_exact", PValue: 0.008, Alpha: 0.05, Significant: true}
+	bms := []*entities.BenchmarkResult{
+		// Mean is skewed by one outlier; the median says 100ms
+		{ProposalID: 1, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 180, Stats: entities.BenchmarkStats{Iterations: 5, MedianMS: 100}},
+		// Lower mean but the test could not tell it apart from the baseline
+		{ProposalID: 1, QueryName: entities.QueryNameTestFilter, ExecutionTimeMS: 95, Stats: entities.BenchmarkStats{Iterations: 5, MedianMS: 95, Significance: noise}},
+		{ProposalID: 1, QueryName: entities.QueryNameTestSort, ExecutionTimeMS: 60, Stats: entities.BenchmarkStats{Iterations: 5, MedianMS: 50, Significance: real}},
+	}
+	if got := ce.performanceScore(bms); got != 50 { t.Fatalf("expected 50 from medians of significant results, got %v", got) }
+
+	bms[2].Stats.Significance = noise
+	if got := ce.performanceScore(bms); got != 0 { t.Fatalf("expected 0 when every improvement is noise, got %v", got) }
+}
+
+func TestConsensus_StorageScoreUsesMeasuredDelta(t *testing.T) {
+	ce := NewConsensusEngine()
+	p := &entities.OptimizationProposal{ID: 1, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 1}}
+	// No measurement: fall back to the agent estimate
+	if got := ce.storageScore(p, nil); got != 99 { t.Fatalf("expected 99 from estimate, got %v", got) }
+	mb := int64(1024 * 1024)
+	bms := []*entities.BenchmarkResult{
+		{ProposalID: 1, QueryName: entities.QueryNameBaseline},
+		{ProposalID: 1, QueryName: entities.QueryNameTestLimit, Stats: entities.BenchmarkStats{Storage: &entities.StorageDelta{TotalBytesBefore: 80 * mb, TotalBytesAfter: 110 * mb}}},
+	}
+	if got := ce.storageScore(p, bms); got != 70 { t.Fatalf("expected 70 from 30MB measured delta, got %v", got) }
+	// Space freed by the proposal scores as zero overhead
+	bms[1].Stats.Storage = &entities.StorageDelta{TotalBytesBefore: 110 * mb, TotalBytesAfter: 80 * mb}
+	if got := ce.storageScore(p, bms); got != 100 { t.Fatalf("expected 100 when storage shrinks, got %v", got) }
+}
+
+func TestConsensus_DisqualifiesNonEquivalentRewrite(t *testing.T) {
+	ce := NewConsensusEngine()
+	criteria := entities.ScoringCriteria{PerformanceWeight: 0.5, StorageWeight: 0.2, ComplexityWeight: 0.2, RiskWeight: 0.1}
+	rewrite := &entities.OptimizationProposal{ID: 1, ProposalType: values.ProposalQueryRewrite, EstimatedImpact: entities.EstimatedImpact{Risk: "low",
+		Equivalence: &entities.ResultEquivalence{Equivalent: false, Reason: "result checksum mismatch"}}}
+	index := &entities.OptimizationProposal{ID: 2, ProposalType: values.ProposalIndex, EstimatedImpact: entities.EstimatedImpact{StorageOverheadMB: 20, Risk: "medium"}}
+	bms := []*entities.BenchmarkResult{
+		{ProposalID: 1, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+		{ProposalID: 1, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: 1},
+		{ProposalID: 2, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+		{ProposalID: 2, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: 60},
+	}
+	dec, err := ce.Decide(context.Background(), []*entities.OptimizationProposal{rewrite, index}, bms, criteria)
+	if err != nil { t.Fatalf("Decide err: %v", err) }
+	if dec.WinningProposalID == nil || *dec.WinningProposalID != 2 { t.Fatalf("expected index proposal to win, got %v", dec.WinningProposalID) }
+	s := dec.ProposalScores[1]
+	if !s.Disqualified || s.WeightedTotal != 0 || s.DisqualifyReason != "rewritten query is not equivalent: result checksum mismatch" { t.Fatalf("unexpected rewrite score: %+v", s) }
+	if !strings.Contains(dec.DecisionRationale, "proposal 1 disqualified") { t.Fatalf("reason not recorded in rationale: %s", dec.DecisionRationale) }
+
+	// Unverified rewrites are disqualified too; with no eligible proposal there is no winner
+	rewrite.EstimatedImpact.Equivalence = nil
+	dec, err = ce.Decide(context.Background(), []*entities.OptimizationProposal{rewrite}, bms, criteria)
+	if err != nil { t.Fatalf("Decide err: %v", err) }
+	if dec.WinningProposalID != nil { t.Fatalf("expected no winner, got %d", *dec.WinningProposalID) }
+}
+
+func TestConsensus_ComparesEveryCandidate(t *testing.T) {
+	ce := NewConsensusEngine()
+	criteria := entities.ScoringCriteria{PerformanceWeight: 0.5, StorageWeight: 0.2, ComplexityWeight: 0.2, RiskWeight: 0.1}
+	// Agent 10 proposes two candidates, agent 20 one; agent 10's second idea is the best.
+	props := []*entities.OptimizationProposal{
+		{ID: 1, AgentExecutionID: 10, EstimatedImpact: entities.EstimatedImpact{Risk: "low", AgentRank: 1}},
+		{ID: 2, AgentExecutionID: 10, EstimatedImpact: entities.EstimatedImpact{Risk: "low", AgentRank: 2}},
+		{ID: 3, AgentExecutionID: 20, EstimatedImpact: entities.EstimatedImpact{Risk: "low", AgentRank: 1}},
+	}
+	bms := []*entities.BenchmarkResult{}
+	for id, after := range map[int64]float64{1: 60, 2: 10, 3: 40} {
+		bms = append(bms,
+			&entities.BenchmarkResult{ProposalID: id, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+			&entities.BenchmarkResult{ProposalID: id, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: after})
+	}
+	dec, err := ce.Decide(context.Background(), props, bms, criteria)
+	if err != nil { t.Fatalf("Decide err: %v", err) }
+	if dec.WinningProposalID == nil || *dec.WinningProposalID != 2 { t.Fatalf("expected the second candidate to win, got %v", dec.WinningProposalID) }
+	if len(dec.ProposalScores) != 3 || dec.ProposalScores[1].Rank != 3 || dec.ProposalScores[3].Rank != 2 { t.Fatalf("unexpected per-proposal scores: %+v", dec.ProposalScores) }
+	if dec.ProposalScores[1].AgentExecutionID != 10 || dec.ProposalScores[2].AgentExecutionID != 10 || dec.ProposalScores[3].AgentExecutionID != 20 {
+		t.Fatalf("expected both candidates of agent 10 linked to it: %+v", dec.ProposalScores)
 	}
 }
@@ -9,8 +9,12 @@ const (
 	EventProposalSubmitted    = "proposal_submitted"
 	EventBenchmarkCompleted   = "benchmark_completed"
 	EventConsensusReached     = "consensus_reached"
+	EventAwaitingApproval     = "awaiting_approval"
+	EventApprovalRejected     = "approval_rejected"
 	EventOptimizationApplied  = "optimization_applied"
 	EventTaskCompleted        = "task_completed"
 	EventTaskFailed           = "task_failed"
+	EventTaskCancelled        = "task_cancelled"
+	EventTaskRetried          = "task_retried"
 	EventConnectionEstablished = "connection_established"
 )
@@ -0,0 +1,74 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+// ForkHygiene returns forks to their pre-task schema once an agent is done with them.
+// Begin fingerprints the fork before the agent runs, Record journals every statement the
+// agent's proposals apply, and Restore undoes them and verifies the fingerprint again.
+// Restore doubles as the ForkPool reset hook, so a fork that cannot be restored is
+// withheld from the pool instead of leaking one task's DDL into the next task's baseline.
+type ForkHygiene struct {
+	MCP mcpFullPort
+
+	mu    sync.Mutex
+	forks map[string]*forkJournal
+}
+
+type forkJournal struct {
+	before  *agents.SchemaSnapshot
+	applied []string
+}
+
+func NewForkHygiene(mcp mcpFullPort) *ForkHygiene {
+	return &ForkHygiene{MCP: mcp, forks: map[string]*forkJournal{}}
+}
+
+// Begin snapshots the fork schema; an unfinished journal for the fork is kept, so its
+// original baseline still applies.
+func (h *ForkHygiene) Begin(ctx context.Context, forkID string) error {
+	if h == nil { return nil }
+	if h.MCP == nil { return errors.New("fork hygiene: mcp client not initialized") }
+	h.mu.Lock()
+	_, open := h.forks[forkID]
+	h.mu.Unlock()
+	if open { return nil }
+	snap, err := agents.TakeSchemaSnapshot(ctx, h.MCP, forkID)
+	if err != nil { return err }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.forks == nil { h.forks = map[string]*forkJournal{} }
+	h.forks[forkID] = &forkJournal{before: snap}
+	return nil
+}
+
+// Record journals statements about to be applied on the fork.
+func (h *ForkHygiene) Record(forkID string, stmts []string) {
+	if h == nil { return }
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if j, ok := h.forks[forkID]; ok { j.applied = append(j.applied, stmts...) }
+}
+
+// Restore undoes the journaled statements and verifies the pre-task fingerprint.
+// The journal is closed only on success, so a failed restore can be retried.
+// Forks without an open journal are left untouched.
+func (h *ForkHygiene) Restore(ctx context.Context, forkID string) error {
+	if h == nil { return nil }
+	h.mu.Lock()
+	j, ok := h.forks[forkID]
+	var applied []string
+	if ok { applied = append(applied, j.applied...) }
+	h.mu.Unlock()
+	if !ok { return nil }
+	if err := agents.RestoreFork(ctx, h.MCP, forkID, j.before, applied); err != nil { return err }
+	h.mu.Lock()
+	delete(h.forks, forkID)
+	h.mu.Unlock()
+	return nil
+}
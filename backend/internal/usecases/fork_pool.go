@@ -0,0 +1,146 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoIsolatedFork is returned when an agent cannot get a fork that no other agent is using.
+var ErrNoIsolatedFork = errors.New("no isolated fork available")
+
+// ForkLease is an agent's exclusive hold on a fork for the duration of one task.
+type ForkLease struct {
+	ForkID string
+	Holder string // e.g. "task12-cerebro"
+	pooled bool   // pre-created fork returned to the pool on release; otherwise deleted
+}
+
+// ForkPool hands each agent an exclusive fork and takes it back when the agent is done.
+// With pre-created forks configured it leases them one at a time and never creates new ones;
+// otherwise every lease creates a fork through MCP and deletes it on release.
+// A fork is never leased twice at the same time: when none is free, or MCP hands back
+// a fork that is already leased, Acquire fails with ErrNoIsolatedFork instead of sharing it.
+type ForkPool struct {
+	MCP   mcpFullPort
+	Reset func(ctx context.Context, forkID string) error // optional; runs before a pre-created fork returns to the pool
+
+	mu     sync.Mutex
+	size   int // pre-created forks the pool was built with; 0 creates a fork per lease
+	idle   []string
+	leased map[string]string // fork ID -> holder
+}
+
+// NewForkPool creates a pool over the given pre-created forks; with none, forks are created per lease.
+func NewForkPool(mcp mcpFullPort, preCreated []string) *ForkPool {
+	p := &ForkPool{MCP: mcp, leased: map[string]string{}}
+	seen := map[string]bool{}
+	for _, id := range preCreated {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] { continue }
+		seen[id] = true
+		p.idle = append(p.idle, id)
+	}
+	p.size = len(p.idle)
+	return p
+}
+
+// Acquire leases an exclusive fork of parentService to holder.
+func (p *ForkPool) Acquire(ctx context.Context, parentService, holder string) (*ForkLease, error) {
+	if p == nil { return nil, errors.New("fork pool not initialized") }
+	p.mu.Lock()
+	if p.leased == nil { p.leased = map[string]string{} }
+	if p.preCreated() {
+		defer p.mu.Unlock()
+		if len(p.idle) == 0 {
+			return nil, fmt.Errorf("%w for %s: %d of %d pre-created forks are leased", ErrNoIsolatedFork, holder, len(p.leased), p.size)
+		}
+		id := p.idle[0]
+		p.idle = p.idle[1:]
+		p.leased[id] = holder
+		return &ForkLease{ForkID: id, Holder: holder, pooled: true}, nil
+	}
+	p.mu.Unlock()
+
+	if p.MCP == nil { return nil, errors.New("fork pool: mcp client not initialized") }
+	id, err := p.MCP.CreateFork(ctx, parentService, holder)
+	if err != nil { return nil, err }
+	return p.leaseCreated(parentService, holder, id)
+}
+
+// AcquireAt leases a new fork of parentService as it was at the given moment. Pre-created forks
+// hold the current data, so point-in-time forks are always created and deleted on release.
+func (p *ForkPool) AcquireAt(ctx context.Context, parentService, holder string, at time.Time) (*ForkLease, error) {
+	if p == nil { return nil, errors.New("fork pool not initialized") }
+	if p.MCP == nil { return nil, errors.New("fork pool: mcp client not initialized") }
+	id, err := p.MCP.CreateForkAtTimestamp(ctx, parentService, holder, at)
+	if err != nil { return nil, err }
+	return p.leaseCreated(parentService, holder, id)
+}
+
+// leaseCreated records a lease on a fork created through MCP.
+func (p *ForkPool) leaseCreated(parentService, holder, id string) (*ForkLease, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.leased == nil { p.leased = map[string]string{} }
+	if id == "" || id == parentService {
+		return nil, fmt.Errorf("%w for %s: mcp returned the parent service instead of a fork", ErrNoIsolatedFork, holder)
+	}
+	if other, ok := p.leased[id]; ok {
+		// The provider reused a fork that another agent holds; it is not ours to delete.
+		return nil, fmt.Errorf("%w for %s: fork %s is already leased to %s", ErrNoIsolatedFork, holder, id, other)
+	}
+	p.leased[id] = holder
+	return &ForkLease{ForkID: id, Holder: holder}, nil
+}
+
+// Release ends a lease. Created forks are deleted; pre-created forks are reset and returned
+// to the pool. A pre-created fork whose reset fails is withheld from the pool, so no later
+// task starts from a contaminated baseline.
+func (p *ForkPool) Release(ctx context.Context, lease *ForkLease) error {
+	if p == nil || lease == nil { return nil }
+	p.mu.Lock()
+	holder, ok := p.leased[lease.ForkID]
+	p.mu.Unlock()
+	if !ok || holder != lease.Holder { return nil }
+
+	var err error
+	if lease.pooled {
+		if p.Reset != nil { err = p.Reset(ctx, lease.ForkID) }
+	} else if p.MCP != nil {
+		err = p.MCP.DeleteFork(ctx, lease.ForkID)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.leased, lease.ForkID)
+	if lease.pooled {
+		if err != nil { return fmt.Errorf("reset fork %s: %w (fork withheld from the pool)", lease.ForkID, err) }
+		p.idle = append(p.idle, lease.ForkID)
+	}
+	return err
+}
+
+// ReleaseAll releases every lease, returning the first error.
+func (p *ForkPool) ReleaseAll(ctx context.Context, leases []*ForkLease) error {
+	var first error
+	for _, l := range leases {
+		if err := p.Release(ctx, l); err != nil && first == nil { first = err }
+	}
+	return first
+}
+
+// Available reports how many forks can be leased right now; -1 when forks are created on demand.
+func (p *ForkPool) Available() int {
+	if p == nil { return 0 }
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.preCreated() { return -1 }
+	return len(p.idle)
+}
+
+// preCreated reports whether the pool manages a fixed set of forks.
+func (p *ForkPool) preCreated() bool { return p.size > 0 }
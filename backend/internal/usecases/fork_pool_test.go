@@ -0,0 +1,151 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+// forkMCP creates forks named after the request, or always returns sameID when set.
+type forkMCP struct {
+	sameID  string
+	created int
+	deleted []string
+	at      []time.Time
+}
+func (m *forkMCP) CreateFork(ctx context.Context, parent, name string) (string, error) {
+	m.created++
+	if m.sameID != "" { return m.sameID, nil }
+	return fmt.Sprintf("svc-%d", m.created), nil
+}
+func (m *forkMCP) CreateForkAtTimestamp(ctx context.Context, parent, name string, at time.Time) (string, error) {
+	m.created++
+	m.at = append(m.at, at)
+	return fmt.Sprintf("pitr-%d", m.created), nil
+}
+func (m *forkMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	return mcp.QueryResult{}, nil
+}
+func (m *forkMCP) DeleteFork(ctx context.Context, serviceID string) error {
+	m.deleted = append(m.deleted, serviceID)
+	return nil
+}
+
+func TestForkPool_PreCreatedLeasesAreExclusive(t *testing.T) {
+	ctx := context.Background()
+	var reset []string
+	pool := NewForkPool(&forkMCP{}, []string{"a", "b", "a"})
+	pool.Reset = func(ctx context.Context, id string) error {
+		reset = append(reset, id)
+		if id == "b" { return errors.New("schema drift") }
+		return nil
+	}
+	if pool.Available() != 2 { t.Fatalf("expected 2 distinct forks, got %d", pool.Available()) }
+	l1, err := pool.Acquire(ctx, "main", "task1-cerebro")
+	if err != nil { t.Fatalf("acquire err: %v", err) }
+	l2, err := pool.Acquire(ctx, "main", "task1-operativo")
+	if err != nil || l2.ForkID == l1.ForkID { t.Fatalf("expected a second, different fork: %v %+v", err, l2) }
+	if _, err := pool.Acquire(ctx, "main", "task1-bulk"); !errors.Is(err, ErrNoIsolatedFork) { t.Fatalf("expected ErrNoIsolatedFork, got %v", err) }
+
+	if err := pool.Release(ctx, l1); err != nil { t.Fatalf("release err: %v", err) }
+	if err := pool.Release(ctx, l2); err == nil { t.Fatalf("expected failed reset to be reported") }
+	if len(reset) != 2 || pool.Available() != 1 { t.Fatalf("expected only the reset fork back in the pool: reset=%v available=%d", reset, pool.Available()) }
+	if err := pool.Release(ctx, l1); err != nil || pool.Available() != 1 { t.Fatalf("double release should be a no-op: %v", err) }
+}
+
+func TestForkPool_RefusesSharedCreatedFork(t *testing.T) {
+	ctx := context.Background()
+	m := &forkMCP{sameID: "gwb579t287"}
+	pool := NewForkPool(m, nil)
+	l1, err := pool.Acquire(ctx, "main", "task1-cerebro")
+	if err != nil { t.Fatalf("acquire err: %v", err) }
+	if _, err := pool.Acquire(ctx, "main", "task1-operativo"); !errors.Is(err, ErrNoIsolatedFork) { t.Fatalf("expected ErrNoIsolatedFork for a reused fork, got %v", err) }
+	if err := pool.Release(ctx, l1); err != nil { t.Fatalf("release err: %v", err) }
+	if len(m.deleted) != 1 || m.deleted[0] != "gwb579t287" { t.Fatalf("expected the created fork to be deleted once, got %v", m.deleted) }
+
+	m = &forkMCP{}
+	pool = NewForkPool(m, nil)
+	a, _ := pool.Acquire(ctx, "main", "x")
+	b, _ := pool.Acquire(ctx, "main", "y")
+	if a == nil || b == nil || a.ForkID == b.ForkID || pool.Available() != -1 { t.Fatalf("expected distinct created forks: %+v %+v", a, b) }
+}
+
+func TestForkPool_AcquireAtCreatesPointInTimeForks(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2025, 11, 5, 18, 30, 0, 0, time.UTC)
+	m := &forkMCP{}
+	pool := NewForkPool(m, []string{"a"})
+	l, err := pool.AcquireAt(ctx, "main", "task1-cerebro", at)
+	if err != nil || l.ForkID != "pitr-1" || len(m.at) != 1 || !m.at[0].Equal(at) { t.Fatalf("expected a fork created at %v: %+v %v", at, l, err) }
+	if pool.Available() != 1 { t.Fatalf("point-in-time leases must not consume pre-created forks, available=%d", pool.Available()) }
+	if err := pool.Release(ctx, l); err != nil || len(m.deleted) != 1 || m.deleted[0] != "pitr-1" { t.Fatalf("expected the point-in-time fork to be deleted: %v %v", err, m.deleted) }
+}
+
+// hygieneMCP reports idx_x in the catalog while it exists; the agent creates it during its benchmark.
+type hygieneMCP struct {
+	forkMCP
+	hasIndex bool
+}
+func (m *hygieneMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error) {
+	if strings.Contains(sql, "FROM pg_class c JOIN pg_namespace") {
+		rows := []map[string]any{{"kind": "table", "name": "public.orders", "def": ""}}
+		if m.hasIndex { rows = append(rows, map[string]any{"kind": "index", "name": "public.idx_x", "def": "CREATE INDEX idx_x ON public.orders USING btree (status)"}) }
+		return mcp.QueryResult{Rows: rows}, nil
+	}
+	if sql == "DROP INDEX IF EXISTS idx_x" { m.hasIndex = false }
+	return mcp.QueryResult{}, nil
+}
+
+type indexingAgent struct{ m *hygieneMCP }
+func (a *indexingAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
+	return agents.AnalysisResult{Task: task}, nil
+}
+func (a *indexingAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{{ProposalType: "index", SQLCommands: []string{"CREATE INDEX idx_x ON orders(status)"}}}, nil
+}
+func (a *indexingAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	a.m.hasIndex = true
+	return []*entities.BenchmarkResult{{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 1}}, nil
+}
+
+func TestOrchestrator_RestoresForkAfterAgent(t *testing.T) {
+	m := &hygieneMCP{}
+	orch := &Orchestrator{MCPClient: m, Hygiene: NewForkHygiene(m)}
+	if _, _, _, err := orch.ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT 1"}, []agents.Agent{&indexingAgent{m: m}}, []string{"fork1"}, []int64{1}); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if m.hasIndex { t.Fatalf("expected the proposal index to be dropped after the agent finished") }
+	if len(orch.Hygiene.forks) != 0 { t.Fatalf("journal should be closed after a verified restore") }
+}
+
+// rivalIndexAgent proposes two candidates creating the same index; a fork left dirty by the first fails the second.
+type rivalIndexAgent struct{ m *hygieneMCP }
+func (a *rivalIndexAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
+	return agents.AnalysisResult{Task: task}, nil
+}
+func (a *rivalIndexAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{
+		{ProposalType: "index", SQLCommands: []string{"CREATE INDEX idx_x ON orders(status)"}},
+		{ProposalType: "index", SQLCommands: []string{"CREATE INDEX idx_x ON orders(status) WHERE status = 'open'"}},
+	}, nil
+}
+func (a *rivalIndexAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	if a.m.hasIndex { return nil, errors.New(`relation "idx_x" already exists`) }
+	a.m.hasIndex = true
+	return []*entities.BenchmarkResult{{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 1}}, nil
+}
+
+func TestOrchestrator_RestoresForkBetweenCandidates(t *testing.T) {
+	m := &hygieneMCP{}
+	orch := &Orchestrator{MCPClient: m, Hygiene: NewForkHygiene(m)}
+	props, _, _, err := orch.ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT 1"}, []agents.Agent{&rivalIndexAgent{m: m}}, []string{"fork1"}, []int64{1})
+	if err != nil || len(props) != 2 { t.Fatalf("expected both candidates benchmarked on a clean fork, got %d (%v)", len(props), err) }
+	if m.hasIndex || len(orch.Hygiene.forks) != 0 { t.Fatalf("expected the fork restored and the journal closed") }
+}
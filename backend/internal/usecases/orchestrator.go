@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
 )
@@ -26,88 +27,170 @@ type Orchestrator struct {
 	AgentFactory interface{}
 	MCPClient    mcpFullPort
 	Config       interface{}
+	MaxAttempts  int                             // proposal attempts per agent; <= 1 disables revision
+	AttemptRepo  domainif.AgentAttemptRepository // optional; records every attempt when set
+	Forks        *ForkPool                       // optional; defaults to creating one fork per agent through MCPClient
+	Hygiene      *ForkHygiene                    // optional; restores each fork's schema after its agent finishes
+	Apply        ApplyOptions                    // how ApplyToMainDB verifies and rolls back; zero value uses defaults
+
+	forksOnce sync.Once
 }
 
 func NewOrchestrator() *Orchestrator { return &Orchestrator{} }
 
+// ForkPool returns the configured pool, or one that creates a fork per lease through MCPClient.
+func (o *Orchestrator) ForkPool() *ForkPool {
+	o.forksOnce.Do(func() {
+		if o.Forks == nil { o.Forks = NewForkPool(o.MCPClient, nil) }
+	})
+	return o.Forks
+}
+
+// agentTimeout bounds each agent's analysis and, separately, its propose → benchmark loop.
+const agentTimeout = 10 * time.Minute
+
+// AgentRun is the outcome of one agent in a parallel run: the phase it reached, why it failed,
+// how long it took and the LLM tokens it used.
+type AgentRun struct {
+	Phase        entities.ExecutionPhase
+	Err          error // nil when the agent delivered
+	Duration     time.Duration
+	InputTokens  int
+	OutputTokens int
+}
+
+// measure starts the run of ag in phase; finish records its error, duration and token usage.
+func measure(ag Agent, phase entities.ExecutionPhase) (run *AgentRun, finish func(error)) {
+	start := time.Now()
+	in, out := agents.TokenUsage(ag)
+	run = &AgentRun{Phase: phase}
+	return run, func(err error) {
+		in2, out2 := agents.TokenUsage(ag)
+		run.Err, run.Duration = err, time.Since(start)
+		run.InputTokens, run.OutputTokens = in2-in, out2-out
+	}
+}
+
+// then merges the run of the agent's next phase: its phase and error win, time and tokens add up.
+func (r *AgentRun) then(next *AgentRun) *AgentRun {
+	if r == nil { return next }
+	if next == nil { return r }
+	return &AgentRun{Phase: next.Phase, Err: next.Err, Duration: r.Duration + next.Duration, InputTokens: r.InputTokens + next.InputTokens, OutputTokens: r.OutputTokens + next.OutputTokens}
+}
+
 // ExecuteAgentsInParallel ejecuta N agentes en paralelo con fork IDs reales y recopila propuestas y benchmarks.
-// Errores parciales son tolerados (si al menos uno entrega resultados).
-func (o *Orchestrator) ExecuteAgentsInParallel(ctx context.Context, task *entities.Task, ags []Agent, forkIDs []string, agentExecIDs []int64) ([]*entities.OptimizationProposal, []*entities.BenchmarkResult, error) {
+// Cada agente puede aportar varias propuestas candidatas; se devuelven agrupadas por agente, en orden de ranking.
+// Errores parciales son tolerados (si al menos uno entrega resultados); runs dice, por agente, qué pasó.
+func (o *Orchestrator) ExecuteAgentsInParallel(ctx context.Context, task *entities.Task, ags []Agent, forkIDs []string, agentExecIDs []int64) ([]*entities.OptimizationProposal, []*entities.BenchmarkResult, []*AgentRun, error) {
+	if len(ags) != len(agentExecIDs) { return nil, nil, nil, errors.New("agents and agentExecIDs count mismatch") }
+	analyses, runs, err := o.AnalyzeInParallel(ctx, task, ags, forkIDs)
+	if err != nil { return nil, nil, runs, err }
+	props, benches, proposeRuns, err := o.ProposeInParallel(ctx, task, ags, analyses, forkIDs, agentExecIDs)
+	for i := range runs { runs[i] = runs[i].then(proposeRuns[i]) }
+	return props, benches, runs, err
+}
+
+// AnalyzeInParallel runs the analysis phase of every agent on its fork. The result of an agent
+// whose analysis failed is nil; the error is only returned when every agent failed.
+// runs holds each agent's outcome, aligned with ags.
+func (o *Orchestrator) AnalyzeInParallel(ctx context.Context, task *entities.Task, ags []Agent, forkIDs []string) ([]*AnalysisResult, []*AgentRun, error) {
 	if task == nil { return nil, nil, errors.New("task is required") }
 	if len(ags) == 0 { return nil, nil, errors.New("no agents provided") }
 	if len(ags) != len(forkIDs) { return nil, nil, errors.New("agents and forkIDs count mismatch") }
-	if len(ags) != len(agentExecIDs) { return nil, nil, errors.New("agents and agentExecIDs count mismatch") }
 
 	var wg sync.WaitGroup
-	propCh := make(chan *entities.OptimizationProposal, len(ags))
-	benchCh := make(chan []*entities.BenchmarkResult, len(ags))
-	errCh := make(chan error, len(ags))
-
+	analyses := make([]*AnalysisResult, len(ags))
+	runs := make([]*AgentRun, len(ags))
 	for i, a := range ags {
 		wg.Add(1)
-		go func(idx int, ag Agent, forkID string, execID int64) {
+		go func(idx int, ag Agent, forkID string) {
 			defer wg.Done()
-			// Timeout por agente: 10 minutos
-			aCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+			aCtx, cancel := context.WithTimeout(ctx, agentTimeout)
 			defer cancel()
-
+			run, finish := measure(ag, entities.PhaseAnalysis)
+			runs[idx] = run
 			// Usar fork ID real en todas las operaciones del agente
 			analysis, err := ag.AnalyzeTask(aCtx, task, forkID)
-			if err != nil { errCh <- err; return }
-			prop, err := ag.ProposeOptimization(aCtx, analysis, forkID)
-			if err != nil { errCh <- err; return }
-			// Asignar agent_execution_id
-			prop.AgentExecutionID = execID
-			// Asignar ID temporal para vincular benchmarks (será reemplazado por DB)
-			prop.ID = int64(idx + 1000) // offset para evitar colisiones
-			fmt.Printf("      🔗 Prop AgentExecutionID=%d tempID=%d assigned\n", execID, prop.ID)
-			res, err := ag.RunBenchmark(aCtx, prop, forkID)
-			if err != nil { errCh <- err; return }
-			propCh <- prop
-			benchCh <- res
-		}(i, a, forkIDs[i], agentExecIDs[i])
+			finish(err)
+			if err == nil { analyses[idx] = &analysis }
+		}(i, a, forkIDs[i])
+	}
+	wg.Wait()
+
+	for _, a := range analyses {
+		if a != nil { return analyses, runs, nil }
 	}
+	return nil, runs, firstError(runs)
+}
 
+// ProposeInParallel runs the propose → benchmark loop of every agent with an analysis on its fork
+// and collects the proposals, grouped by agent in rank order. Agents with a nil analysis are skipped
+// and get a nil run; the error is only returned when no agent produced a proposal.
+func (o *Orchestrator) ProposeInParallel(ctx context.Context, task *entities.Task, ags []Agent, analyses []*AnalysisResult, forkIDs []string, agentExecIDs []int64) ([]*entities.OptimizationProposal, []*entities.BenchmarkResult, []*AgentRun, error) {
+	if task == nil { return nil, nil, nil, errors.New("task is required") }
+	if len(ags) != len(analyses) { return nil, nil, nil, errors.New("agents and analyses count mismatch") }
+	if len(ags) != len(forkIDs) { return nil, nil, nil, errors.New("agents and forkIDs count mismatch") }
+	if len(ags) != len(agentExecIDs) { return nil, nil, nil, errors.New("agents and agentExecIDs count mismatch") }
+
+	var wg sync.WaitGroup
+	props := make([][]*entities.OptimizationProposal, len(ags))
+	benches := make([][]*entities.BenchmarkResult, len(ags))
+	runs := make([]*AgentRun, len(ags))
+	for i, a := range ags {
+		if analyses[i] == nil { continue }
+		wg.Add(1)
+		go func(idx int, ag Agent, analysis AnalysisResult, forkID string, execID int64) {
+			defer wg.Done()
+			aCtx, cancel := context.WithTimeout(ctx, agentTimeout)
+			defer cancel()
+			run, finish := measure(ag, entities.PhaseProposal)
+			runs[idx] = run
+
+			// Fingerprint del fork antes del agente; al terminar se deshace lo aplicado
+			if err := o.Hygiene.Begin(aCtx, forkID); err != nil { finish(err); return }
+			defer o.restoreFork(context.WithoutCancel(ctx), forkID)
+
+			// IDs temporales para vincular benchmarks (serán reemplazados por DB), un bloque de 1000 por agente
+			var err error
+			props[idx], benches[idx], run.Phase, err = o.proposeWithRetries(aCtx, ag, task, analysis, forkID, execID, int64((idx+1)*1000))
+			finish(err)
+		}(i, a, *analyses[i], forkIDs[i], agentExecIDs[i])
+	}
 	wg.Wait()
-	close(propCh)
-	close(benchCh)
-	close(errCh)
 
 	var proposals []*entities.OptimizationProposal
 	var benchmarks []*entities.BenchmarkResult
-	for p := range propCh { proposals = append(proposals, p) }
-	for bs := range benchCh { benchmarks = append(benchmarks, bs...) }
+	for i := range ags {
+		proposals = append(proposals, props[i]...)
+		benchmarks = append(benchmarks, benches[i]...)
+	}
 	// si todos fallaron, devolver error
-	if len(proposals) == 0 {
-		var firstErr error
-		for e := range errCh { firstErr = e; break }
-		if firstErr == nil { firstErr = errors.New("all agents failed") }
-		return nil, nil, firstErr
+	if len(proposals) == 0 { return nil, nil, runs, firstError(runs) }
+	return proposals, benchmarks, runs, nil
+}
+
+func firstError(runs []*AgentRun) error {
+	for _, r := range runs {
+		if r != nil && r.Err != nil { return r.Err }
+	}
+	return errors.New("all agents failed")
+}
+
+// restoreFork runs the hygiene step for a fork; failures only log, the fork pool withholds dirty forks.
+func (o *Orchestrator) restoreFork(ctx context.Context, forkID string) {
+	if err := o.Hygiene.Restore(ctx, forkID); err != nil {
+		fmt.Printf("Warning: fork %s not restored: %v\n", forkID, err)
 	}
-	return proposals, benchmarks, nil
 }
 
 // mcpFullPort abstracts MCP operations needed for fork management, query execution and cleanup.
 type mcpFullPort interface {
 	CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error)
+	CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error)
 	ExecuteQuery(ctx context.Context, serviceID, sql string, timeoutMs int) (mcp.QueryResult, error)
 	DeleteFork(ctx context.Context, serviceID string) error
 }
 
-// ApplyToMainDB aplica la propuesta ganadora en el servicio principal (main DB) vía MCP.
-func (o *Orchestrator) ApplyToMainDB(ctx context.Context, mainService string, winning *entities.OptimizationProposal) error {
-	if o == nil || o.MCPClient == nil { return errors.New("orchestrator not initialized") }
-	if winning == nil || len(winning.SQLCommands) == 0 { return errors.New("invalid winning proposal") }
-	if mainService == "" { return errors.New("main service required") }
-	for _, stmt := range winning.SQLCommands {
-		if stmt == "" { continue }
-		if _, err := o.MCPClient.ExecuteQuery(ctx, mainService, stmt, 600000); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // CleanupForks elimina forks usados durante la ejecución.
 func (o *Orchestrator) CleanupForks(ctx context.Context, forkIDs []string) error {
 	if o == nil || o.MCPClient == nil { return errors.New("orchestrator not initialized") }
@@ -129,7 +212,7 @@ func (o *Orchestrator) ExecuteTask(ctx context.Context, task *entities.Task, ags
 	for i := range ags {
 		agentExecIDs[i] = int64(i + 1)
 	}
-	props, benches, err := o.ExecuteAgentsInParallel(ctx, task, ags, forkIDs, agentExecIDs)
+	props, benches, _, err := o.ExecuteAgentsInParallel(ctx, task, ags, forkIDs, agentExecIDs)
 	if err != nil { return nil, err }
 	// Scoring criteria por defecto
 	criteria := entities.ScoringCriteria{PerformanceWeight: 0.5, StorageWeight: 0.2, ComplexityWeight: 0.2, RiskWeight: 0.1}
@@ -143,7 +226,7 @@ func (o *Orchestrator) ExecuteTask(ctx context.Context, task *entities.Task, ags
 		}
 	}
 	if winner == nil { return nil, errors.New("winner not found") }
-	if err := o.ApplyToMainDB(ctx, mainService, winner); err != nil { return nil, err }
+	if _, err := o.ApplyToMainDB(ctx, mainService, winner, task.TargetQuery); err != nil { return nil, err }
 	// Cleanup forks
 	if err := o.CleanupForks(ctx, forkIDs); err != nil { return nil, err }
 	return dec, nil
@@ -25,18 +25,18 @@ func (a *e2eAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID
 	return agents.AnalysisResult{Insights: []string{"e2e"}}, nil
 }
 
-func (a *e2eAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) (*entities.OptimizationProposal, error) {
-	return &entities.OptimizationProposal{
+func (a *e2eAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{{
 		ID:           a.id,
 		SQLCommands:  []string{"CREATE INDEX idx ON orders(status)", "ANALYZE orders"},
 		EstimatedImpact: entities.EstimatedImpact{
 			StorageOverheadMB: 1,
 			Risk:              "low",
 		},
-	}, nil
+	}}, nil
 }
 
-func (a *e2eAgent) RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+func (a *e2eAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
 	// Baseline 100, optimized = a.best → improvement varies per agent
 	return []*entities.BenchmarkResult{
 		{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
@@ -56,6 +56,14 @@ func (m *e2eMCP) ExecuteQuery(ctx context.Context, serviceID, sql string, timeou
 	return mcp.QueryResult{ExecutionTimeMs: 1}, nil
 }
 
+func (m *e2eMCP) CreateFork(ctx context.Context, parentServiceID, forkName string) (string, error) {
+	return "fork-" + forkName, nil
+}
+
+func (m *e2eMCP) CreateForkAtTimestamp(ctx context.Context, parentServiceID, forkName string, at time.Time) (string, error) {
+	return "pitr-" + forkName, nil
+}
+
 func (m *e2eMCP) DeleteFork(ctx context.Context, serviceID string) error {
 	m.delCalls++
 	return nil
@@ -141,7 +149,7 @@ func TestOrchestratorComplete(t *testing.T) {
 	// Phase 1: Parallel agent execution
 	parallelStart := time.Now()
 	// Execute agents in parallel
-	props, benches, err := orch.ExecuteAgentsInParallel(context.Background(), task, []agents.Agent{ag1, ag2, ag3}, []string{"fork1", "fork2", "fork3"}, []int64{1, 2, 3})
+	props, benches, _, err := orch.ExecuteAgentsInParallel(context.Background(), task, []agents.Agent{ag1, ag2, ag3}, []string{"fork1", "fork2", "fork3"}, []int64{1, 2, 3})
 	parallelDuration := time.Since(parallelStart)
 	report.ParallelExecDuration = parallelDuration
 	
@@ -164,7 +172,7 @@ func TestOrchestratorComplete(t *testing.T) {
 		}
 	}
 	if winner != nil {
-		applyErr := orch.ApplyToMainDB(context.Background(), "main-svc", winner)
+		_, applyErr := orch.ApplyToMainDB(context.Background(), "main-svc", winner, task.TargetQuery)
 		if applyErr != nil && consensusErr == nil {
 			err = applyErr
 		}
@@ -205,11 +213,12 @@ func TestOrchestratorComplete(t *testing.T) {
 	report.WinnerID = *dec.WinningProposalID
 	report.Status = "COMPLETED"
 
-	// Validation 3: Winner is proposal 1 (best performance)
-	if *dec.WinningProposalID != 1 {
+	// Validation 3: Winner is ag1's proposal (best performance); the orchestrator
+	// re-keys proposals with temporary IDs (1000 + agent index) before benchmarking.
+	if *dec.WinningProposalID != 1000 {
 		report.ValidationPassed = false
-		report.ValidationMsg = fmt.Sprintf("Expected winner proposal 1, got %d", *dec.WinningProposalID)
-		t.Errorf("expected winner proposal 1, got %v", *dec.WinningProposalID)
+		report.ValidationMsg = fmt.Sprintf("Expected winner proposal 1000, got %d", *dec.WinningProposalID)
+		t.Errorf("expected winner proposal 1000, got %v", *dec.WinningProposalID)
 	}
 
 	// Validation 4: Apply operations executed
@@ -238,23 +247,20 @@ func TestOrchestratorComplete(t *testing.T) {
 	report.ProposalsCount = 3
 	report.BenchmarksCount = 6 // 2 per agent
 
-	// Calculate agent metrics from ConsensusDecision.AllScores
-	if dec != nil && dec.AllScores != nil {
-		agentIndex := int64(1)
-		for _, score := range dec.AllScores {
-			report.AgentScores[agentIndex] = score.WeightedTotal
-			report.AgentImprovements[agentIndex] = score.ImprovementPct
-			agentIndex++
+	// Calculate agent metrics from ConsensusDecision.ProposalScores (best-ranked proposal of each agent)
+	if dec != nil && dec.ProposalScores != nil {
+		best := map[int64]entities.ProposalScore{}
+		for _, score := range dec.ProposalScores {
+			if b, ok := best[score.AgentExecutionID]; !ok || score.Rank < b.Rank { best[score.AgentExecutionID] = score }
+		}
+		for execID, score := range best {
+			report.AgentScores[execID] = score.WeightedTotal
+			report.AgentImprovements[execID] = score.ImprovementPct
 		}
 		
-		// Winner score from AllScores
+		// Winner score from ProposalScores
 		if dec.WinningProposalID != nil {
-			for _, score := range dec.AllScores {
-				if score.ProposalID == *dec.WinningProposalID {
-					report.WinnerScore = score.WeightedTotal
-					break
-				}
-			}
+			report.WinnerScore = dec.ProposalScores[*dec.WinningProposalID].WeightedTotal
 		}
 	} else {
 		// Fallback for mock data
@@ -279,8 +285,8 @@ func TestOrchestratorComplete(t *testing.T) {
 		t.Errorf("expected 3 proposals, got %d", report.ProposalsCount)
 	}
 
-	if report.WinnerID != 1 {
-		t.Errorf("winner should be proposal 1 with best performance")
+	if report.WinnerID != 1000 {
+		t.Errorf("winner should be ag1's proposal (1000) with best performance")
 	}
 
 	t.Logf("\n✅ E2E TEST PASSED")
@@ -3,6 +3,7 @@ package usecases
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
@@ -13,17 +14,17 @@ type mockAgentOK struct{ id int64 }
 func (m *mockAgentOK) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
 	return agents.AnalysisResult{Insights: []string{"ok"}}, nil
 }
-func (m *mockAgentOK) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) (*entities.OptimizationProposal, error) {
-	return &entities.OptimizationProposal{ID: m.id, SQLCommands: []string{"SELECT 1"}}, nil
+func (m *mockAgentOK) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{{ID: m.id, SQLCommands: []string{"SELECT 1"}}}, nil
 }
-func (m *mockAgentOK) RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+func (m *mockAgentOK) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
 	return []*entities.BenchmarkResult{{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 1}}, nil
 }
 
 type mockAgentFail struct{}
 func (m *mockAgentFail) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) { return agents.AnalysisResult{}, errors.New("fail") }
-func (m *mockAgentFail) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) (*entities.OptimizationProposal, error) { return nil, errors.New("fail") }
-func (m *mockAgentFail) RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) { return nil, errors.New("fail") }
+func (m *mockAgentFail) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) { return nil, errors.New("fail") }
+func (m *mockAgentFail) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) { return nil, errors.New("fail") }
 
 func TestOrchestratorParallel(t *testing.T) {
 	orch := NewOrchestrator()
@@ -32,8 +33,128 @@ func TestOrchestratorParallel(t *testing.T) {
 	ag3 := &mockAgentFail{}
 
 	task := &entities.Task{Type: entities.TaskTypeQueryOptimization, TargetQuery: "SELECT * FROM orders"}
-	props, benches, err := orch.ExecuteAgentsInParallel(context.Background(), task, []agents.Agent{ag1, ag2, ag3}, []string{"fork1", "fork2", "fork3"}, []int64{1, 2, 3})
+	props, benches, runs, err := orch.ExecuteAgentsInParallel(context.Background(), task, []agents.Agent{ag1, ag2, ag3}, []string{"fork1", "fork2", "fork3"}, []int64{1, 2, 3})
 	if err != nil { t.Fatalf("unexpected err (partial failures allowed): %v", err) }
 	if len(props) != 2 { t.Fatalf("expected 2 proposals, got %d", len(props)) }
 	if len(benches) != 2 { t.Fatalf("expected 2 benchmark result sets flattened, got %d", len(benches)) }
+	if len(runs) != 3 || runs[0].Err != nil || runs[0].Phase != entities.PhaseBenchmark {
+		t.Fatalf("expected the first agent to reach the benchmark, got %+v", runs)
+	}
+	if runs[2].Err == nil || runs[2].Phase != entities.PhaseAnalysis {
+		t.Errorf("expected the failing agent to stop at analysis with its error, got %+v", runs[2])
+	}
+}
+
+// revisingAgent fails on the fork first, then benchmarks without improvement, then improves.
+type revisingAgent struct {
+	seen  [][]agents.PriorAttempt
+	bench int
+}
+func (m *revisingAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
+	return agents.AnalysisResult{Task: task}, nil
+}
+func (m *revisingAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	m.seen = append(m.seen, analysis.PriorAttempts)
+	sql := []string{"CREATE INDEX ON ordrs(status)", "CREATE INDEX ON orders(id)", "CREATE INDEX ON orders(status)"}[len(m.seen)-1]
+	return []*entities.OptimizationProposal{{ProposalType: "index", SQLCommands: []string{sql}}}, nil
+}
+func (m *revisingAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	m.bench++
+	if m.bench == 1 { return nil, errors.New(`relation "ordrs" does not exist`) }
+	after := 100.0
+	if m.bench == 3 { after = 10 }
+	return []*entities.BenchmarkResult{
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: after},
+	}, nil
+}
+
+type memAttemptRepo struct{ attempts []*entities.AgentAttempt }
+func (r *memAttemptRepo) Create(ctx context.Context, a *entities.AgentAttempt) error {
+	if err := a.Validate(); err != nil { return err }
+	r.attempts = append(r.attempts, a)
+	return nil
+}
+func (r *memAttemptRepo) GetByAgentExecutionID(ctx context.Context, execID int) ([]*entities.AgentAttempt, error) { return r.attempts, nil }
+
+func TestOrchestrator_RevisesFailedProposals(t *testing.T) {
+	repo := &memAttemptRepo{}
+	orch := &Orchestrator{MaxAttempts: 3, AttemptRepo: repo}
+	ag := &revisingAgent{}
+	task := &entities.Task{Type: entities.TaskTypeQueryOptimization, TargetQuery: "SELECT * FROM orders WHERE status='x'"}
+	props, benches, _, err := orch.ExecuteAgentsInParallel(context.Background(), task, []agents.Agent{ag}, []string{"fork1"}, []int64{7})
+	if err != nil { t.Fatalf("unexpected err: %v", err) }
+	if len(props) != 1 || props[0].SQLCommands[0] != "CREATE INDEX ON orders(status)" || len(benches) != 2 { t.Fatalf("expected the revised proposal, got %+v", props) }
+	if len(ag.seen) != 3 || len(ag.seen[0]) != 0 || len(ag.seen[2]) != 2 { t.Fatalf("unexpected feedback history: %+v", ag.seen) }
+	if !strings.Contains(ag.seen[1][0].Feedback, `relation "ordrs" does not exist`) || !strings.Contains(ag.seen[2][1].Feedback, "no significant improvement") {
+		t.Fatalf("feedback not handed back to the agent: %+v", ag.seen[2])
+	}
+	want := []entities.AttemptOutcome{entities.AttemptFailed, entities.AttemptNoImprovement, entities.AttemptAccepted}
+	if len(repo.attempts) != 3 { t.Fatalf("expected 3 recorded attempts, got %d", len(repo.attempts)) }
+	for i, a := range repo.attempts {
+		if a.AgentExecutionID != 7 || a.Attempt != i+1 || a.Outcome != want[i] { t.Fatalf("unexpected attempt %d: %+v", i, a) }
+	}
+	if repo.attempts[2].ImprovementPct != 90 { t.Fatalf("expected 90%% improvement, got %v", repo.attempts[2].ImprovementPct) }
+}
+
+// regressingAgent improves without significance first, then proposes a slower revision.
+type regressingAgent struct{ revisingAgent }
+func (m *regressingAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	m.bench++
+	if m.bench == 2 { return nil, errors.New("deadlock detected") }
+	return []*entities.BenchmarkResult{
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: 100 + float64(m.bench)},
+	}, nil
+}
+
+func TestOrchestrator_KeepsBestBenchmarkedAttempt(t *testing.T) {
+	ag := &revisingAgent{}
+	orch := &Orchestrator{MaxAttempts: 2}
+	props, _, _, err := orch.ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT 1"}, []agents.Agent{ag}, []string{"fork1"}, []int64{1})
+	if err != nil || len(props) != 1 || props[0].SQLCommands[0] != "CREATE INDEX ON orders(id)" { t.Fatalf("expected the non-improving attempt to be kept: %v %+v", err, props) }
+
+	// Neither benchmarked attempt improves: a later revision does not replace the earlier one
+	orch.MaxAttempts = 3
+	props, _, _, err = orch.ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT 1"}, []agents.Agent{&regressingAgent{}}, []string{"fork1"}, []int64{1})
+	if err != nil || len(props) != 1 || props[0].SQLCommands[0] != "CREATE INDEX ON ordrs(status)" { t.Fatalf("expected the first benchmarked attempt to be kept: %v %+v", err, props) }
+
+	ag = &revisingAgent{}
+	if _, _, _, err := (&Orchestrator{}).ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT 1"}, []agents.Agent{ag}, []string{"fork1"}, []int64{1}); err == nil || len(ag.seen) != 1 {
+		t.Fatalf("single attempt mode should not retry: err=%v attempts=%d", err, len(ag.seen))
+	}
+}
+
+// rankedAgent proposes an index and a rewrite; the rewrite fails on the fork.
+type rankedAgent struct{}
+func (m *rankedAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
+	return agents.AnalysisResult{Task: task}, nil
+}
+func (m *rankedAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{
+		{ProposalType: "index", SQLCommands: []string{"CREATE INDEX ON orders(status)"}},
+		{ProposalType: "composite_index", SQLCommands: []string{"CREATE INDEX ON orders(status, id)"}},
+		{ProposalType: "query_rewrite", SQLCommands: []string{"SELECT id FROM orders"}},
+	}, nil
+}
+func (m *rankedAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	if proposal.ProposalType == "query_rewrite" { return nil, errors.New("syntax error") }
+	return []*entities.BenchmarkResult{
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 100},
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: 20},
+	}, nil
+}
+
+func TestOrchestrator_BenchmarksEveryCandidate(t *testing.T) {
+	repo := &memAttemptRepo{}
+	orch := &Orchestrator{MaxAttempts: 2, AttemptRepo: repo}
+	props, benches, _, err := orch.ExecuteAgentsInParallel(context.Background(), &entities.Task{TargetQuery: "SELECT * FROM orders"}, []agents.Agent{&rankedAgent{}}, []string{"fork1"}, []int64{7})
+	if err != nil { t.Fatalf("unexpected err: %v", err) }
+	if len(props) != 2 || len(benches) != 4 { t.Fatalf("expected both benchmarked candidates, got %d proposals / %d results", len(props), len(benches)) }
+	if props[0].ID == props[1].ID || props[0].AgentExecutionID != 7 || props[1].EstimatedImpact.AgentRank != 2 { t.Fatalf("candidates not tagged: %+v %+v", props[0], props[1]) }
+	if len(repo.attempts) != 3 { t.Fatalf("expected one record per candidate, got %d", len(repo.attempts)) }
+	for i, a := range repo.attempts {
+		if a.Attempt != 1 || a.Candidate != i+1 { t.Fatalf("unexpected attempt record %d: %+v", i, a) }
+	}
+	if repo.attempts[2].Outcome != entities.AttemptFailed { t.Fatalf("expected failed rewrite, got %+v", repo.attempts[2]) }
 }
@@ -10,13 +10,18 @@ import (
 	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
 )
 
+// agentCreator is the subset of AgentFactory the router needs.
+type agentCreator interface {
+	CreateAgent(agentType values.AgentType) (agents.Agent, error)
+}
+
 // Router selects appropriate agents for a given task based on rules.
 type Router struct {
-	Factory   *AgentFactory
+	Factory   agentCreator
 	Rationale string
 }
 
-func NewRouter(factory *AgentFactory) *Router { return &Router{Factory: factory} }
+func NewRouter(factory agentCreator) *Router { return &Router{Factory: factory} }
 
 // SelectAgents applies routing rules and returns agent instances.
 func (r *Router) SelectAgents(ctx context.Context, task *entities.Task) ([]agents.Agent, error) {
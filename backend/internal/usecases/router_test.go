@@ -13,15 +13,15 @@ type fakeAgent struct{}
 func (f *fakeAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
 	return agents.AnalysisResult{}, nil
 }
-func (f *fakeAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) (*entities.OptimizationProposal, error) {
-	return &entities.OptimizationProposal{}, nil
+func (f *fakeAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	return []*entities.OptimizationProposal{{}}, nil
 }
-func (f *fakeAgent) RunBenchmark(ctx context.Context, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+func (f *fakeAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
 	return nil, nil
 }
 
 type mockFactory struct{}
-func (m *mockFactory) New(t values.AgentType) (agents.Agent, error) { return &fakeAgent{}, nil }
+func (m *mockFactory) CreateAgent(t values.AgentType) (agents.Agent, error) { return &fakeAgent{}, nil }
 
 func TestRouter_SimpleQuery(t *testing.T) {
 	factory := &mockFactory{}
@@ -0,0 +1,103 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+var (
+	ErrTaskNotFound            = errors.New("task not found")
+	ErrTaskNotAwaitingApproval = errors.New("task is not awaiting approval")
+	ErrApplyDisabled           = errors.New("applying to main is disabled")
+)
+
+// ApproveTask records an admin's approval of a task in awaiting_approval and moves it back to
+// in_progress, so a second approval or a rejection is refused. The apply itself can take minutes:
+// the caller queues the task and ProcessTask resumes its waiting apply stage.
+func (p *TaskProcessor) ApproveTask(ctx context.Context, taskID int64, approver string) (*entities.Task, error) {
+	if !p.orchestrator.Apply.Enabled { return nil, ErrApplyDisabled }
+	p.approvals.Lock()
+	defer p.approvals.Unlock()
+	task, err := p.awaitingTask(ctx, taskID)
+	if err != nil { return nil, err }
+	if _, _, err := p.approvedWinner(ctx, task); err != nil { return nil, err }
+	recordApproval(task, "approved", approver, "")
+	task.Status = entities.TaskStatusInProgress
+	if err := p.taskRepo.Update(ctx, task); err != nil { return nil, fmt.Errorf("failed to update task status: %w", err) }
+	return task, nil
+}
+
+// applyApproved applies the winner of an approved task to main and completes the task. It runs
+// from the queue like the rest of the pipeline, so an apply cut short by a crash is resumed.
+func (p *TaskProcessor) applyApproved(ctx context.Context, run *pipelineRun) error {
+	task := run.task
+	if task.Status != entities.TaskStatusInProgress {
+		task.Status = entities.TaskStatusInProgress
+		if err := p.saveTask(ctx, task); err != nil { return fmt.Errorf("failed to update task status: %w", err) }
+	}
+	decision, winner, err := p.approvedWinner(ctx, task)
+	if err != nil { return err }
+	var applied applyCheckpoint
+	if err := p.stage(ctx, run, entities.StageApply, &applied, func() error { return p.applyStage(ctx, task, decision, winner, &applied) }); err != nil { return err }
+	return p.stage(ctx, run, entities.StageCleanup, nil, func() error { return p.completeTask(ctx, task, decision) })
+}
+
+// RejectTask records an admin's rejection: main is left untouched and the task completes
+// with its decision not applied.
+func (p *TaskProcessor) RejectTask(ctx context.Context, taskID int64, approver, reason string) (*entities.Task, error) {
+	p.approvals.Lock()
+	defer p.approvals.Unlock()
+	task, err := p.awaitingTask(ctx, taskID)
+	if err != nil { return nil, err }
+	decision, err := p.consensusRepo.GetByTaskID(ctx, int(taskID))
+	if err != nil { return nil, fmt.Errorf("consensus decision of task %d: %w", taskID, err) }
+	recordApproval(task, "rejected", approver, reason)
+	run, err := p.loadRun(ctx, task)
+	if err != nil { return nil, err }
+	var rejected applyCheckpoint
+	p.stage(ctx, run, entities.StageApply, &rejected, func() error { rejected.Status = "rejected"; return nil })
+	if err := p.stage(ctx, run, entities.StageCleanup, nil, func() error { return p.completeTask(ctx, task, decision) }); err != nil { return nil, err }
+	p.broadcastEvent(EventApprovalRejected, map[string]interface{}{
+		"task_id":     taskID,
+		"rejected_by": approver,
+		"reason":      reason,
+	})
+	return task, nil
+}
+
+func (p *TaskProcessor) awaitingTask(ctx context.Context, taskID int64) (*entities.Task, error) {
+	task, err := p.taskRepo.GetByID(ctx, int(taskID))
+	if err != nil || task == nil { return nil, ErrTaskNotFound }
+	if task.Status != entities.TaskStatusAwaitingApproval { return nil, fmt.Errorf("%w: status is %s", ErrTaskNotAwaitingApproval, task.Status) }
+	return task, nil
+}
+
+// approvedWinner loads the task's decision and its winning proposal, which must have statements to apply.
+func (p *TaskProcessor) approvedWinner(ctx context.Context, task *entities.Task) (*entities.ConsensusDecision, *entities.OptimizationProposal, error) {
+	decision, err := p.consensusRepo.GetByTaskID(ctx, int(task.ID))
+	if err != nil || decision == nil { return nil, nil, fmt.Errorf("consensus decision of task %d not found: %v", task.ID, err) }
+	if decision.WinningProposalID == nil { return nil, nil, fmt.Errorf("task %d has no winning proposal", task.ID) }
+	winner, err := p.proposalRepo.GetByID(ctx, int(*decision.WinningProposalID))
+	if err != nil || winner == nil { return nil, nil, fmt.Errorf("winning proposal %d not found: %v", *decision.WinningProposalID, err) }
+	if len(agents.ProposalStatements(winner)) == 0 { return nil, nil, fmt.Errorf("winning proposal %d has nothing to apply", winner.ID) }
+	return decision, winner, nil
+}
+
+// approved reports whether an admin approved applying the task's winner.
+func approved(task *entities.Task) bool {
+	approval, _ := task.Metadata["approval"].(map[string]interface{})
+	return approval != nil && approval["status"] == "approved"
+}
+
+// recordApproval keeps who decided, when and why in metadata.approval.
+func recordApproval(task *entities.Task, status, by, reason string) {
+	if task.Metadata == nil { task.Metadata = map[string]interface{}{} }
+	approval := map[string]interface{}{"status": status, "by": by, "at": time.Now().UTC().Format(time.RFC3339)}
+	if reason != "" { approval["reason"] = reason }
+	task.Metadata["approval"] = approval
+}
@@ -0,0 +1,96 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/mcp"
+)
+
+type memConsensusRepo struct{ byTask map[int]*entities.ConsensusDecision }
+
+func (r *memConsensusRepo) Create(ctx context.Context, d *entities.ConsensusDecision) error {
+	r.byTask[int(d.TaskID)] = d
+	return nil
+}
+func (r *memConsensusRepo) GetByTaskID(ctx context.Context, taskID int) (*entities.ConsensusDecision, error) {
+	if d, ok := r.byTask[taskID]; ok { return d, nil }
+	return nil, errors.New("not found")
+}
+func (r *memConsensusRepo) Update(ctx context.Context, d *entities.ConsensusDecision) error {
+	r.byTask[int(d.TaskID)] = d
+	return nil
+}
+
+type memProposalRepo struct{ byID map[int]*entities.OptimizationProposal }
+
+func (r *memProposalRepo) Create(ctx context.Context, p *entities.OptimizationProposal) error {
+	p.ID = int64(len(r.byID) + 1)
+	r.byID[int(p.ID)] = p
+	return nil
+}
+func (r *memProposalRepo) GetByID(ctx context.Context, id int) (*entities.OptimizationProposal, error) {
+	if p, ok := r.byID[id]; ok { return p, nil }
+	return nil, errors.New("not found")
+}
+func (r *memProposalRepo) GetByAgentExecutionID(ctx context.Context, execID int) ([]*entities.OptimizationProposal, error) {
+	return nil, nil
+}
+func (r *memProposalRepo) Update(ctx context.Context, p *entities.OptimizationProposal) error { return nil }
+
+func newApprovalProcessor(m *mainMCP, status entities.TaskStatus) (*TaskProcessor, *mockTaskRepo, *memConsensusRepo) {
+	winnerID := int64(7)
+	tasks := &mockTaskRepo{byID: map[int]*entities.Task{1: {
+		ID: 1, Type: entities.TaskTypeIndexTuning, TargetQuery: "SELECT * FROM orders WHERE status = 'open'", Status: status,
+	}}}
+	decisions := &memConsensusRepo{byTask: map[int]*entities.ConsensusDecision{1: {TaskID: 1, WinningProposalID: &winnerID}}}
+	proposals := &memProposalRepo{byID: map[int]*entities.OptimizationProposal{7: {ID: 7, SQLCommands: []string{"CREATE INDEX idx_status ON orders(status)"}}}}
+	opts := DefaultApplyOptions()
+	opts.Enabled = true
+	opts.Benchmark = agents.BenchmarkOptions{MeasuredRuns: 5, Alpha: 0.05, Timing: mcp.TimingClient}
+	orch := &Orchestrator{MCPClient: m, Apply: opts}
+	p := NewTaskProcessor(tasks, nil, proposals, nil, decisions, orch, nil, nil, nil, "main")
+	return p, tasks, decisions
+}
+
+func TestTaskProcessor_ApproveAppliesOnce(t *testing.T) {
+	m := &mainMCP{afterMs: 2}
+	p, tasks, decisions := newApprovalProcessor(m, entities.TaskStatusAwaitingApproval)
+	ctx := context.Background()
+
+	task, err := p.ApproveTask(ctx, 1, "dba@example.com")
+	if err != nil { t.Fatalf("ApproveTask: %v", err) }
+	if task.Status != entities.TaskStatusInProgress || task.Metadata["approval"].(map[string]interface{})["by"] != "dba@example.com" {
+		t.Fatalf("expected the approval recorded and the task claimed, got %+v", task)
+	}
+	if _, err := p.ApproveTask(ctx, 1, "other@example.com"); !errors.Is(err, ErrTaskNotAwaitingApproval) {
+		t.Errorf("expected a second approval to be refused, got %v", err)
+	}
+	if err := p.ProcessTask(ctx, 1); err != nil { t.Fatalf("ProcessTask: %v", err) }
+	if !m.indexed || !decisions.byTask[1].AppliedToMain || tasks.byID[1].Status != entities.TaskStatusCompleted {
+		t.Errorf("expected the winner applied and the task completed, got indexed=%v decision=%+v", m.indexed, decisions.byTask[1])
+	}
+}
+
+func TestTaskProcessor_RejectLeavesMainUntouched(t *testing.T) {
+	m := &mainMCP{}
+	p, tasks, decisions := newApprovalProcessor(m, entities.TaskStatusAwaitingApproval)
+
+	task, err := p.RejectTask(context.Background(), 1, "dba@example.com", "peak hours")
+	if err != nil { t.Fatalf("RejectTask: %v", err) }
+	approval := task.Metadata["approval"].(map[string]interface{})
+	if approval["status"] != "rejected" || approval["reason"] != "peak hours" || tasks.byID[1].Status != entities.TaskStatusCompleted {
+		t.Errorf("expected a completed task with the rejection recorded, got %+v", task)
+	}
+	if len(m.executed) != 0 || decisions.byTask[1].AppliedToMain {
+		t.Errorf("expected nothing applied to main, got %v", m.executed)
+	}
+
+	p, _, _ = newApprovalProcessor(m, entities.TaskStatusInProgress)
+	if _, err := p.ApproveTask(context.Background(), 1, "dba@example.com"); !errors.Is(err, ErrTaskNotAwaitingApproval) {
+		t.Errorf("expected tasks not awaiting approval to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,80 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+var (
+	// ErrTaskCancelled is the cancellation cause of the context of a cancelled task.
+	ErrTaskCancelled = errors.New("task cancelled")
+	// ErrTaskNotCancellable: the task already completed, failed or was cancelled.
+	ErrTaskNotCancellable = errors.New("task cannot be cancelled")
+)
+
+// runningTask is the context of a task being processed on this server.
+type runningTask struct{ cancel context.CancelCauseFunc }
+
+// CancelTask cancels a pending, in_progress or awaiting_approval task. A task being processed on
+// this server has its context cancelled: MCP queries and LLM calls stop, its forks are released
+// and the pipeline stops without touching the status. The cancelled status is final.
+func (p *TaskProcessor) CancelTask(ctx context.Context, taskID int64, by string) (*entities.Task, error) {
+	p.approvals.Lock()
+	defer p.approvals.Unlock()
+	p.runningMu.Lock()
+	defer p.runningMu.Unlock()
+	task, err := p.taskRepo.GetByID(ctx, int(taskID))
+	if err != nil || task == nil { return nil, ErrTaskNotFound }
+	if !task.CanTransitionTo(entities.TaskStatusCancelled) { return nil, fmt.Errorf("%w: status is %s", ErrTaskNotCancellable, task.Status) }
+
+	running := p.running[taskID]
+	if running != nil { running.cancel(ErrTaskCancelled) }
+	previous := task.Status
+	task.Status = entities.TaskStatusCancelled
+	if task.Metadata == nil { task.Metadata = map[string]interface{}{} }
+	task.Metadata["cancellation"] = map[string]interface{}{"by": by, "at": time.Now().UTC().Format(time.RFC3339), "previous_status": string(previous)}
+	if err := p.taskRepo.Update(ctx, task); err != nil { return nil, fmt.Errorf("failed to update task status: %w", err) }
+	p.broadcastEvent(EventTaskCancelled, map[string]interface{}{
+		"task_id":         taskID,
+		"cancelled_by":    by,
+		"previous_status": previous,
+		"was_running":     running != nil,
+	})
+	return task, nil
+}
+
+// track registers the context of a task being processed so CancelTask can stop it; the returned
+// func unregisters it.
+func (p *TaskProcessor) track(ctx context.Context, taskID int64) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	rt := &runningTask{cancel: cancel}
+	p.runningMu.Lock()
+	if p.running == nil { p.running = map[int64]*runningTask{} }
+	p.running[taskID] = rt
+	p.runningMu.Unlock()
+	return ctx, func() {
+		p.runningMu.Lock()
+		if p.running[taskID] == rt { delete(p.running, taskID) }
+		p.runningMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// saveTask persists a status change of the pipeline. Once the task context is cancelled the
+// status is left alone: CancelTask already wrote cancelled, or the worker is stopping and the
+// queue retries the task.
+func (p *TaskProcessor) saveTask(ctx context.Context, task *entities.Task) error {
+	p.runningMu.Lock()
+	defer p.runningMu.Unlock()
+	if ctx.Err() != nil { return context.Cause(ctx) }
+	return p.taskRepo.Update(ctx, task)
+}
+
+// cancelled reports whether ctx was cancelled by CancelTask (here or, through the queue heartbeat, on another server).
+func cancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrTaskCancelled)
+}
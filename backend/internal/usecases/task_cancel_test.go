@@ -0,0 +1,51 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+func TestTaskProcessor_CancelStopsTheRunningPipeline(t *testing.T) {
+	p, tasks, _ := newApprovalProcessor(&mainMCP{}, entities.TaskStatusInProgress)
+	ctx, untrack := p.track(context.Background(), 1)
+	defer untrack()
+
+	task, err := p.CancelTask(context.Background(), 1, "dba@example.com")
+	if err != nil { t.Fatalf("CancelTask: %v", err) }
+	if !cancelled(ctx) || task.Metadata["cancellation"].(map[string]interface{})["previous_status"] != "in_progress" {
+		t.Fatalf("expected the task context cancelled and the cancellation recorded, got cause=%v %+v", context.Cause(ctx), task.Metadata)
+	}
+
+	// The pipeline unwinding afterwards must not overwrite the final status.
+	running := &entities.Task{ID: 1, Status: entities.TaskStatusInProgress}
+	if err := p.failTask(ctx, running, errors.New("agent execution failed: context canceled")); err == nil {
+		t.Error("expected failTask to return the pipeline error")
+	}
+	if err := p.completeTask(ctx, running, &entities.ConsensusDecision{}); err == nil {
+		t.Error("expected completing a cancelled task to be refused")
+	}
+	if got := tasks.byID[1].Status; got != entities.TaskStatusCancelled {
+		t.Errorf("expected the task to stay cancelled, got %s", got)
+	}
+	if err := p.ProcessTask(context.Background(), 1); err != nil || tasks.byID[1].Status != entities.TaskStatusCancelled {
+		t.Errorf("expected a queue retry to leave the cancelled task alone, got %v %s", err, tasks.byID[1].Status)
+	}
+	if _, err := p.CancelTask(context.Background(), 1, "dba@example.com"); !errors.Is(err, ErrTaskNotCancellable) {
+		t.Errorf("expected a second cancel to be refused, got %v", err)
+	}
+}
+
+func TestTaskProcessor_CancelAwaitingApprovalLeavesMainUntouched(t *testing.T) {
+	m := &mainMCP{}
+	p, tasks, decisions := newApprovalProcessor(m, entities.TaskStatusAwaitingApproval)
+	if _, err := p.CancelTask(context.Background(), 1, "dba@example.com"); err != nil { t.Fatalf("CancelTask: %v", err) }
+	if _, err := p.ApproveTask(context.Background(), 1, "dba@example.com"); !errors.Is(err, ErrTaskNotAwaitingApproval) {
+		t.Errorf("expected a cancelled task not to be approvable, got %v", err)
+	}
+	if len(m.executed) != 0 || decisions.byTask[1].AppliedToMain || tasks.byID[1].Status != entities.TaskStatusCancelled {
+		t.Errorf("expected nothing applied and the task cancelled, got %v %s", m.executed, tasks.byID[1].Status)
+	}
+}
@@ -0,0 +1,162 @@
+package usecases
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+// ErrTaskNotRetryable: only failed tasks can be retried.
+var ErrTaskNotRetryable = errors.New("task cannot be retried")
+
+// errStageWaiting stops the pipeline without failing it: the apply stage waits for an admin approval.
+var errStageWaiting = errors.New("stage waiting for approval")
+
+// pipelineRun is the state of one run of a task's pipeline. Stages completed by an earlier run
+// are not run again; their results are rebuilt from the checkpoint outputs.
+type pipelineRun struct {
+	task        *entities.Task
+	checkpoints map[entities.PipelineStage]*entities.TaskStage
+	agents      []Agent
+	forks       []forkCheckpoint
+	leases      []*ForkLease
+	analyses    []*AnalysisResult
+	proposals   []*entities.OptimizationProposal
+	benchmarks  []*entities.BenchmarkResult
+	decision    *entities.ConsensusDecision
+}
+
+// Stage outputs, stored as the checkpoint of each stage.
+type (
+	routingCheckpoint struct {
+		Agents []values.AgentType `json:"agents"`
+	}
+	forkCheckpoint struct {
+		AgentType   values.AgentType `json:"agent_type"`
+		ExecutionID int64            `json:"execution_id"`
+		ForkID      string           `json:"fork_id"`
+	}
+	analysisCheckpoint struct {
+		ExecutionID int64               `json:"execution_id"`
+		Insights    []string            `json:"insights"`
+		Issues      []string            `json:"issues"`
+		Focus       []string            `json:"focus_areas"`
+		Plan        *agents.PlanContext `json:"plan,omitempty"`
+	}
+	// proposalCheckpoint keeps the proposals with their temporary IDs; the benchmark stage stores them.
+	proposalCheckpoint struct {
+		Proposals  []*entities.OptimizationProposal `json:"proposals"`
+		Benchmarks []*entities.BenchmarkResult      `json:"benchmarks"`
+	}
+	// benchmarkCheckpoint maps temporary proposal IDs to stored ones; a failed run keeps its
+	// progress so a retry does not store a proposal or benchmark twice.
+	benchmarkCheckpoint struct {
+		ProposalIDs     map[int64]int64 `json:"proposal_ids"`
+		SavedBenchmarks int             `json:"saved_benchmarks"`
+	}
+	consensusCheckpoint struct {
+		DecisionID        int64  `json:"decision_id"`
+		WinningProposalID *int64 `json:"winning_proposal_id"`
+	}
+	applyCheckpoint struct {
+		Status string `json:"status"` // skipped, awaiting_approval, rejected or the apply report status
+	}
+)
+
+// loadRun reads the task's checkpoints; without a stage repository every run starts from scratch.
+func (p *TaskProcessor) loadRun(ctx context.Context, task *entities.Task) (*pipelineRun, error) {
+	run := &pipelineRun{task: task, checkpoints: map[entities.PipelineStage]*entities.TaskStage{}}
+	if p.Stages == nil { return run, nil }
+	stages, err := p.Stages.GetByTaskID(ctx, int(task.ID))
+	if err != nil { return nil, fmt.Errorf("failed to load checkpoints of task %d: %w", task.ID, err) }
+	for _, s := range stages { run.checkpoints[s.Stage] = s }
+	return run, nil
+}
+
+func (r *pipelineRun) done(stage entities.PipelineStage) bool {
+	cp := r.checkpoints[stage]
+	return cp != nil && cp.Status == entities.StageDone
+}
+
+// resumeStage is the first stage a retry runs.
+func (r *pipelineRun) resumeStage() entities.PipelineStage {
+	for _, stage := range entities.PipelineStages {
+		if !r.done(stage) { return stage }
+	}
+	return entities.StageCleanup
+}
+
+// stage runs fn as the given stage unless a checkpoint says it is done. The checkpoint output is
+// decoded into out first, so a completed stage only restores its results and a failed one resumes
+// from the progress it kept. fn fills out, which is stored whatever the outcome.
+func (p *TaskProcessor) stage(ctx context.Context, run *pipelineRun, stage entities.PipelineStage, out interface{}, fn func() error) error {
+	cp := run.checkpoints[stage]
+	if cp != nil && out != nil && len(cp.Output) > 0 {
+		if err := json.Unmarshal(cp.Output, out); err != nil { return fmt.Errorf("invalid %s checkpoint: %w", stage, err) }
+	}
+	if cp != nil && cp.Status == entities.StageDone { return nil }
+	if cp == nil {
+		cp = &entities.TaskStage{TaskID: run.task.ID, Stage: stage}
+		run.checkpoints[stage] = cp
+	}
+	cp.Status, cp.Attempts, cp.Error, cp.StartedAt, cp.FinishedAt = entities.StageRunning, cp.Attempts+1, "", time.Now().UTC(), nil
+	p.saveStage(ctx, cp)
+
+	err := fn()
+	now := time.Now().UTC()
+	cp.FinishedAt = &now
+	switch {
+	case errors.Is(err, errStageWaiting):
+		cp.Status = entities.StageWaiting
+	case err != nil:
+		cp.Status, cp.Error = entities.StageFailed, err.Error()
+	default:
+		cp.Status = entities.StageDone
+	}
+	if out != nil {
+		raw, mErr := json.Marshal(out)
+		if mErr != nil { fmt.Printf("Warning: failed to encode %s checkpoint of task %d: %v\n", stage, run.task.ID, mErr) }
+		cp.Output = raw
+	}
+	p.saveStage(ctx, cp)
+	return err
+}
+
+// saveStage persists a checkpoint, even once the task context is cancelled; failures only log,
+// the stage then runs again on a retry.
+func (p *TaskProcessor) saveStage(ctx context.Context, cp *entities.TaskStage) {
+	if p.Stages == nil { return }
+	if err := p.Stages.Save(context.WithoutCancel(ctx), cp); err != nil {
+		fmt.Printf("Warning: failed to save %s checkpoint of task %d: %v\n", cp.Stage, cp.TaskID, err)
+	}
+}
+
+// RetryTask moves a failed task back to pending and records the retry in metadata.retry; the
+// caller queues it. The pipeline then resumes from the first stage that did not complete.
+func (p *TaskProcessor) RetryTask(ctx context.Context, taskID int64, by string) (*entities.Task, error) {
+	p.approvals.Lock()
+	defer p.approvals.Unlock()
+	task, err := p.taskRepo.GetByID(ctx, int(taskID))
+	if err != nil || task == nil { return nil, ErrTaskNotFound }
+	if !task.CanTransitionTo(entities.TaskStatusPending) { return nil, fmt.Errorf("%w: status is %s", ErrTaskNotRetryable, task.Status) }
+	run, err := p.loadRun(ctx, task)
+	if err != nil { return nil, err }
+
+	resume := run.resumeStage()
+	task.Status = entities.TaskStatusPending
+	if task.Metadata == nil { task.Metadata = map[string]interface{}{} }
+	task.Metadata["retry"] = map[string]interface{}{"by": by, "at": time.Now().UTC().Format(time.RFC3339), "resume_from": string(resume)}
+	if err := p.taskRepo.Update(ctx, task); err != nil { return nil, fmt.Errorf("failed to update task status: %w", err) }
+	p.broadcastEvent(EventTaskRetried, map[string]interface{}{
+		"task_id":     taskID,
+		"retried_by":  by,
+		"resume_from": resume,
+	})
+	return task, nil
+}
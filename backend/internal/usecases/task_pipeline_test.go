@@ -0,0 +1,161 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
+)
+
+type memStageRepo struct{ byStage map[entities.PipelineStage]entities.TaskStage }
+
+func (r *memStageRepo) Save(ctx context.Context, s *entities.TaskStage) error {
+	r.byStage[s.Stage] = *s
+	return nil
+}
+func (r *memStageRepo) GetByTaskID(ctx context.Context, taskID int) ([]*entities.TaskStage, error) {
+	var out []*entities.TaskStage
+	for _, stage := range entities.PipelineStages {
+		if s, ok := r.byStage[stage]; ok { out = append(out, &s) }
+	}
+	return out, nil
+}
+
+type memExecRepo struct{ byID map[int]*entities.AgentExecution }
+
+func (r *memExecRepo) Create(ctx context.Context, e *entities.AgentExecution) error {
+	e.ID = int64(len(r.byID) + 1)
+	r.byID[int(e.ID)] = e
+	return nil
+}
+func (r *memExecRepo) GetByID(ctx context.Context, id int) (*entities.AgentExecution, error) {
+	if e, ok := r.byID[id]; ok { return e, nil }
+	return nil, errors.New("not found")
+}
+func (r *memExecRepo) GetByTaskID(ctx context.Context, taskID int) ([]*entities.AgentExecution, error) { return nil, nil }
+func (r *memExecRepo) List(ctx context.Context) ([]*entities.AgentExecution, error)                   { return nil, nil }
+func (r *memExecRepo) Update(ctx context.Context, e *entities.AgentExecution) error                    { return nil }
+
+// flakyBenchRepo fails the benchmark insert numbered failOn (1-based) once.
+type flakyBenchRepo struct {
+	calls, failOn int
+	saved         []*entities.BenchmarkResult
+}
+
+func (r *flakyBenchRepo) Create(ctx context.Context, b *entities.BenchmarkResult) error {
+	r.calls++
+	if r.calls == r.failOn { return errors.New("connection reset") }
+	r.saved = append(r.saved, b)
+	return nil
+}
+func (r *flakyBenchRepo) GetByProposalID(ctx context.Context, proposalID int) ([]*entities.BenchmarkResult, error) {
+	return nil, nil
+}
+
+// countingAgent proposes one index and counts its LLM-backed calls.
+type countingAgent struct{ analyzed, proposed int32 }
+
+func (a *countingAgent) AnalyzeTask(ctx context.Context, task *entities.Task, forkID string) (agents.AnalysisResult, error) {
+	atomic.AddInt32(&a.analyzed, 1)
+	return agents.AnalysisResult{Insights: []string{"seq scan on orders"}, Task: task}, nil
+}
+func (a *countingAgent) ProposeOptimization(ctx context.Context, analysis agents.AnalysisResult, forkID string) ([]*entities.OptimizationProposal, error) {
+	atomic.AddInt32(&a.proposed, 1)
+	return []*entities.OptimizationProposal{{ProposalType: values.ProposalIndex, SQLCommands: []string{"CREATE INDEX idx_status ON orders(status)"}}}, nil
+}
+func (a *countingAgent) RunBenchmark(ctx context.Context, task *entities.Task, proposal *entities.OptimizationProposal, forkID string) ([]*entities.BenchmarkResult, error) {
+	return []*entities.BenchmarkResult{
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameBaseline, ExecutionTimeMS: 10},
+		{ProposalID: proposal.ID, QueryName: entities.QueryNameTestLimit, ExecutionTimeMS: 2},
+	}, nil
+}
+
+type singleAgentFactory struct{ agent Agent }
+
+func (f *singleAgentFactory) CreateAgent(values.AgentType) (agents.Agent, error) { return f.agent, nil }
+
+func TestTaskProcessor_RetryResumesFromTheFailedStage(t *testing.T) {
+	tasks := &mockTaskRepo{byID: map[int]*entities.Task{1: {ID: 1, Type: entities.TaskTypeQueryOptimization, TargetQuery: "SELECT * FROM orders WHERE status = 'open'", Status: entities.TaskStatusPending}}}
+	proposals := &memProposalRepo{byID: map[int]*entities.OptimizationProposal{}}
+	benches := &flakyBenchRepo{failOn: 2}
+	decisions := &memConsensusRepo{byTask: map[int]*entities.ConsensusDecision{}}
+	stages := &memStageRepo{byStage: map[entities.PipelineStage]entities.TaskStage{}}
+	pool := NewForkPool(nil, []string{"f1", "f2", "f3"})
+	orch := &Orchestrator{Forks: pool}
+	p := NewTaskProcessor(tasks, &memExecRepo{byID: map[int]*entities.AgentExecution{}}, proposals, benches, decisions, orch, NewConsensusEngine(), nil, nil, "main")
+	agent := &countingAgent{}
+	p.agentFactory = &singleAgentFactory{agent: agent}
+	p.Stages = stages
+	ctx := context.Background()
+
+	if err := p.ProcessTask(ctx, 1); err == nil { t.Fatal("expected the benchmark insert failure to fail the task") }
+	if tasks.byID[1].Status != entities.TaskStatusFailed || stages.byStage[entities.StageBenchmark].Status != entities.StageFailed {
+		t.Fatalf("expected a failed task with a failed benchmark stage, got %s %+v", tasks.byID[1].Status, stages.byStage[entities.StageBenchmark])
+	}
+	if stages.byStage[entities.StageProposal].Status != entities.StageDone || pool.Available() != 3 {
+		t.Fatalf("expected the proposal stage checkpointed and the forks released, got %+v free=%d", stages.byStage[entities.StageProposal], pool.Available())
+	}
+
+	if _, err := p.RetryTask(ctx, 2, "dba@example.com"); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected an unknown task to be refused, got %v", err)
+	}
+	task, err := p.RetryTask(ctx, 1, "dba@example.com")
+	if err != nil { t.Fatalf("RetryTask: %v", err) }
+	if task.Status != entities.TaskStatusPending || task.Metadata["retry"].(map[string]interface{})["resume_from"] != "benchmark" {
+		t.Fatalf("expected a pending task resuming from the benchmark stage, got %s %+v", task.Status, task.Metadata)
+	}
+	if _, err := p.RetryTask(ctx, 1, "dba@example.com"); !errors.Is(err, ErrTaskNotRetryable) {
+		t.Errorf("expected a pending task not to be retryable, got %v", err)
+	}
+
+	if err := p.ProcessTask(ctx, 1); err != nil { t.Fatalf("ProcessTask after retry: %v", err) }
+	if tasks.byID[1].Status != entities.TaskStatusCompleted {
+		t.Fatalf("expected the retried task completed, got %s", tasks.byID[1].Status)
+	}
+	if agent.analyzed != 3 || agent.proposed != 3 {
+		t.Errorf("expected the agents not to run again, got %d analyses and %d proposals", agent.analyzed, agent.proposed)
+	}
+	if len(proposals.byID) != 3 || len(benches.saved) != 6 {
+		t.Errorf("expected every proposal and benchmark stored once, got %d proposals and %d benchmarks", len(proposals.byID), len(benches.saved))
+	}
+	for _, b := range benches.saved {
+		if proposals.byID[int(b.ProposalID)] == nil { t.Errorf("benchmark linked to unknown proposal %d", b.ProposalID) }
+	}
+	winner := decisions.byTask[1].WinningProposalID
+	if winner == nil || proposals.byID[int(*winner)] == nil || stages.byStage[entities.StageCleanup].Status != entities.StageDone {
+		t.Errorf("expected a stored winner and every stage done, got %v %+v", winner, stages.byStage)
+	}
+}
+
+func TestTaskProcessor_RecordsFailedAgentExecutions(t *testing.T) {
+	tasks := &mockTaskRepo{byID: map[int]*entities.Task{1: {ID: 1, Type: entities.TaskTypeQueryOptimization, TargetQuery: "SELECT * FROM orders", Status: entities.TaskStatusPending}}}
+	execs := &memExecRepo{byID: map[int]*entities.AgentExecution{}}
+	orch := &Orchestrator{Forks: NewForkPool(nil, []string{"f1", "f2", "f3"})}
+	p := NewTaskProcessor(tasks, execs, nil, nil, nil, orch, NewConsensusEngine(), nil, nil, "main")
+	p.agentFactory = &singleAgentFactory{agent: &mockAgentFail{}}
+
+	if err := p.ProcessTask(context.Background(), 1); err == nil { t.Fatal("expected the task to fail when every agent fails") }
+	if len(execs.byID) == 0 { t.Fatal("expected agent executions to be recorded") }
+	for _, e := range execs.byID {
+		if e.Status != entities.ExecutionFailed || e.ErrorMsg != "fail" || e.Phase != entities.PhaseAnalysis || e.CompletedAt == nil {
+			t.Errorf("expected a failed execution stopped at analysis, got %+v", e)
+		}
+	}
+}
+
+func TestTaskProcessor_DecideReusesDecisionWithoutWinner(t *testing.T) {
+	// Every proposal was disqualified and the run crashed before the consensus checkpoint was saved.
+	existing := &entities.ConsensusDecision{ID: 3, TaskID: 1}
+	decisions := &memConsensusRepo{byTask: map[int]*entities.ConsensusDecision{1: existing}}
+	p := NewTaskProcessor(nil, nil, nil, nil, decisions, &Orchestrator{}, NewConsensusEngine(), nil, nil, "main")
+	run := &pipelineRun{task: &entities.Task{ID: 1}, proposals: []*entities.OptimizationProposal{{ID: 5}}}
+
+	if err := p.decide(context.Background(), run); err != nil { t.Fatalf("decide: %v", err) }
+	if run.decision != existing || decisions.byTask[1] != existing {
+		t.Errorf("expected the stored decision reused, got %+v", run.decision)
+	}
+}
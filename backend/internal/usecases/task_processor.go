@@ -2,12 +2,15 @@ package usecases
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/tuusuario/afs-challenge/internal/domain/entities"
 	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
 	"github.com/tuusuario/afs-challenge/internal/domain/values"
+	"github.com/tuusuario/afs-challenge/internal/infrastructure/agents"
 )
 
 // EventType representa el tipo de evento WebSocket
@@ -18,8 +21,8 @@ type EventType = string
 // 2. Crear forks
 // 3. Ejecutar agentes en paralelo
 // 4. Consenso
-// 5. Aplicar solución
-// 6. Limpiar forks
+// 5. Limpiar forks
+// 6. Aplicar solución (opcionalmente tras aprobación humana)
 type TaskProcessor struct {
 	taskRepo      domainif.TaskRepository
 	agentExecRepo domainif.AgentExecutionRepository
@@ -29,8 +32,12 @@ type TaskProcessor struct {
 	orchestrator  *Orchestrator
 	consensus     *ConsensusEngine
 	hub           *Hub
-	agentFactory  *AgentFactory
+	agentFactory  agentCreator
 	mainService   string
+	Stages        domainif.TaskStageRepository // opcional; guarda un checkpoint por etapa para que una tarea fallida se reanude
+	approvals     sync.Mutex // serializa aprobar/rechazar/cancelar para que un ganador se aplique como mucho una vez
+	runningMu     sync.Mutex // protege running y ordena las escrituras de estado frente a CancelTask
+	running       map[int64]*runningTask
 }
 
 func NewTaskProcessor(
@@ -45,7 +52,7 @@ func NewTaskProcessor(
 	agentFactory *AgentFactory,
 	mainService string,
 ) *TaskProcessor {
-	return &TaskProcessor{
+	p := &TaskProcessor{
 		taskRepo:      taskRepo,
 		agentExecRepo: agentExecRepo,
 		proposalRepo:  proposalRepo,
@@ -54,22 +61,55 @@ func NewTaskProcessor(
 		orchestrator:  orchestrator,
 		consensus:     consensus,
 		hub:           hub,
-		agentFactory:  agentFactory,
 		mainService:   mainService,
 	}
+	if agentFactory != nil {
+		p.agentFactory = agentFactory
+	}
+	return p
 }
 
-// ProcessTask ejecuta el flujo completo de procesamiento de una tarea
+// ProcessTask ejecuta el flujo completo de procesamiento de una tarea.
+// El contexto de la tarea se registra para que CancelTask pueda detenerla; una tarea
+// cancelada no devuelve error (no hay nada que reintentar).
 func (p *TaskProcessor) ProcessTask(ctx context.Context, taskID int64) error {
+	ctx, untrack := p.track(ctx, taskID)
+	defer untrack()
+	err := p.processTask(ctx, taskID)
+	if cancelled(ctx) {
+		return nil
+	}
+	return err
+}
+
+func (p *TaskProcessor) processTask(ctx context.Context, taskID int64) error {
 	// 1. Obtener tarea
 	task, err := p.taskRepo.GetByID(ctx, int(taskID))
 	if err != nil || task == nil {
 		return fmt.Errorf("task not found: %w", err)
 	}
+	// Un reintento de la cola no reprocesa una tarea que ya terminó, fue cancelada o espera aprobación
+	switch task.Status {
+	case entities.TaskStatusCompleted, entities.TaskStatusCancelled, entities.TaskStatusAwaitingApproval:
+		return nil
+	}
+	// Checkpoints de una ejecución anterior: las etapas ya completadas no se repiten
+	run, err := p.loadRun(ctx, task)
+	if err != nil {
+		return err
+	}
+	// Una tarea aprobada solo retoma la etapa de aplicación que esperaba la aprobación
+	if approved(task) && !run.done(entities.StageApply) {
+		err = p.applyApproved(ctx, run)
+		if err != nil && task.Status != entities.TaskStatusFailed {
+			return p.failTask(ctx, task, err)
+		}
+		return err
+	}
 
 	// 2. Actualizar estado a "in_progress" (routing)
 	task.Status = entities.TaskStatusInProgress
-	if err := p.taskRepo.Update(ctx, task); err != nil {
+	if err := p.saveTask(ctx, task); err != nil {
 		return fmt.Errorf("failed to update task status: %w", err)
 	}
 
@@ -78,119 +118,359 @@ func (p *TaskProcessor) ProcessTask(ctx context.Context, taskID int64) error {
 		"status":  "routing",
 	})
 
-	// 3. Asignar agentes (3 agentes especializados)
-	agentTypes := []values.AgentType{
-		values.AgentCerebro,   // gemini-2.5-pro (Planner/QA)
-		values.AgentOperativo, // gemini-2.5-flash (Generator)
-		values.AgentBulk,      // gemini-2.0-flash (Bulk ops)
+	defer func() {
+		// Liberar también en retornos anticipados; sin cancelación para que el reset del fork no se corte
+		if err := p.orchestrator.ForkPool().ReleaseAll(context.WithoutCancel(ctx), run.leases); err != nil {
+			fmt.Printf("Warning: failed to release forks: %v\n", err)
+		}
+	}()
+	err = p.runPipeline(ctx, run)
+	if errors.Is(err, errStageWaiting) {
+		return nil
 	}
+	if err != nil && task.Status != entities.TaskStatusFailed {
+		return p.failTask(ctx, task, err)
+	}
+	return err
+}
 
-	var agentInstances []Agent
-	var forkIDs []string
-	var agentExecutionIDs []int64
+// runPipeline ejecuta las etapas de la tarea en orden; cada una guarda su checkpoint.
+func (p *TaskProcessor) runPipeline(ctx context.Context, run *pipelineRun) error {
+	task := run.task
 
-	// 4. Crear agentes y forks reales via MCP
-	for _, agentType := range agentTypes {
+	// 3. Asignar agentes (3 agentes especializados)
+	var routing routingCheckpoint
+	if err := p.stage(ctx, run, entities.StageRouting, &routing, func() error {
+		routing.Agents = []values.AgentType{
+			values.AgentCerebro,   // gemini-2.5-pro (Planner/QA)
+			values.AgentOperativo, // gemini-2.5-flash (Generator)
+			values.AgentBulk,      // gemini-2.0-flash (Bulk ops)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if p.agentFactory == nil {
+		return errors.New("agent factory not configured")
+	}
+	for _, agentType := range routing.Agents {
 		fmt.Printf("      🔨 Creating agent: %s\n", agentType)
 		agent, err := p.agentFactory.CreateAgent(agentType)
 		if err != nil {
 			return fmt.Errorf("failed to create agent %s: %w", agentType, err)
 		}
-		agentInstances = append(agentInstances, agent)
+		run.agents = append(run.agents, agent)
+	}
+
+	// 4. Reservar un fork exclusivo por agente. Si una ejecución anterior falló antes de terminar
+	// las propuestas, sus forks ya se liberaron: se reservan de nuevo para las mismas agent_executions.
+	if run.done(entities.StageForks) && (!run.done(entities.StageAnalysis) || !run.done(entities.StageProposal)) {
+		run.checkpoints[entities.StageForks].Status = entities.StageRunning
+	}
+	if err := p.stage(ctx, run, entities.StageForks, &run.forks, func() error {
+		return p.leaseForks(ctx, run, routing.Agents)
+	}); err != nil {
+		return err
+	}
+	forkIDs := make([]string, len(run.forks))
+	execIDs := make([]int64, len(run.forks))
+	for i, f := range run.forks {
+		forkIDs[i], execIDs[i] = f.ForkID, f.ExecutionID
+	}
+	if len(run.forks) != len(run.agents) {
+		return fmt.Errorf("forks checkpoint has %d agents, routing %d", len(run.forks), len(run.agents))
+	}
 
-		// Crear fork real usando MCP Client
-		forkName := fmt.Sprintf("fork-%s-task%d", agentType, taskID)
-		forkID, err := p.orchestrator.MCPClient.CreateFork(ctx, p.mainService, forkName)
+	// 5. Análisis de cada agente en su fork
+	var analyses []analysisCheckpoint
+	if err := p.stage(ctx, run, entities.StageAnalysis, &analyses, func() error {
+		p.broadcastEvent(EventAnalysisCompleted, map[string]interface{}{
+			"task_id": task.ID,
+			"status":  "executing",
+			"agents":  len(run.agents),
+		})
+		results, runs, err := p.orchestrator.AnalyzeInParallel(ctx, task, run.agents, forkIDs)
+		p.recordAgentRuns(ctx, execIDs, runs, false)
 		if err != nil {
-			return fmt.Errorf("failed to create fork for %s: %w", agentType, err)
+			return fmt.Errorf("agent analysis failed: %w", err)
 		}
-		forkIDs = append(forkIDs, forkID)
-
-		// Crear registro de agent_execution en DB
-		agentExec := &entities.AgentExecution{
-			TaskID:     int64(taskID),
-			AgentType:  agentType,
-			ForkID:     forkID,
-			Status:     "running",
-			StartedAt:  time.Now().UTC(),
+		run.analyses = results
+		for i, a := range results {
+			if a == nil {
+				continue
+			}
+			analyses = append(analyses, analysisCheckpoint{ExecutionID: execIDs[i], Insights: a.Insights, Issues: a.Issues, Focus: a.Focus, Plan: a.Plan})
 		}
-		if err := p.agentExecRepo.Create(ctx, agentExec); err != nil {
-			return fmt.Errorf("failed to create agent execution record: %w", err)
+		return nil
+	}); err != nil {
+		return err
+	}
+	if run.analyses == nil {
+		run.analyses = make([]*AnalysisResult, len(run.agents))
+		for _, a := range analyses {
+			for i, execID := range execIDs {
+				if execID == a.ExecutionID {
+					run.analyses[i] = &AnalysisResult{Insights: a.Insights, Issues: a.Issues, Focus: a.Focus, Task: task, Plan: a.Plan}
+				}
+			}
 		}
-		fmt.Printf("      ✅ Created agent_execution ID=%d for task=%d agent=%s\n", agentExec.ID, taskID, agentType)
-		agentExecutionIDs = append(agentExecutionIDs, agentExec.ID)
-
-		p.broadcastEvent(EventForkCreated, map[string]interface{}{
-			"task_id":    taskID,
-			"agent_type": agentType,
-			"fork_id":    forkID,
-			"execution_id": agentExec.ID,
-		})
 	}
 
-	// 5. Ejecutar agentes en paralelo usando orchestrator
-	p.broadcastEvent(EventAnalysisCompleted, map[string]interface{}{
-		"task_id": taskID,
-		"status":  "executing",
-		"agents":  len(agentInstances),
+	// 6. Propuestas y benchmarks de cada agente en su fork (con revisiones)
+	var proposed proposalCheckpoint
+	if err := p.stage(ctx, run, entities.StageProposal, &proposed, func() error {
+		props, benches, runs, err := p.orchestrator.ProposeInParallel(ctx, task, run.agents, run.analyses, forkIDs, execIDs)
+		p.recordAgentRuns(ctx, execIDs, runs, true)
+		if err != nil {
+			return fmt.Errorf("agent execution failed: %w", err)
+		}
+		proposed = proposalCheckpoint{Proposals: props, Benchmarks: benches}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// 7. Liberar forks (los creados se eliminan, los del pool se resetean y vuelven al pool); ninguna etapa posterior los usa
+	if err := p.orchestrator.ForkPool().ReleaseAll(context.WithoutCancel(ctx), run.leases); err != nil {
+		// Log error pero no fallar el proceso
+		fmt.Printf("Warning: failed to release forks: %v\n", err)
+	}
+	run.leases = nil
+
+	// 8. Guardar propuestas y benchmarks (IDs temporales -> IDs de DB)
+	saved := benchmarkCheckpoint{ProposalIDs: map[int64]int64{}}
+	if err := p.stage(ctx, run, entities.StageBenchmark, &saved, func() error {
+		return p.saveResults(ctx, run, proposed, &saved)
+	}); err != nil {
+		return err
+	}
+	run.proposals, run.benchmarks = proposed.Proposals, proposed.Benchmarks
+	for _, prop := range run.proposals {
+		prop.ID = saved.ProposalIDs[prop.ID]
+	}
+	for _, bench := range run.benchmarks {
+		if newID, ok := saved.ProposalIDs[bench.ProposalID]; ok {
+			bench.ProposalID = newID
+		}
+	}
+
+	// 9. Ejecutar consenso y guardar la decisión
+	var agreed consensusCheckpoint
+	if err := p.stage(ctx, run, entities.StageConsensus, &agreed, func() error {
+		if err := p.decide(ctx, run); err != nil {
+			return err
+		}
+		agreed = consensusCheckpoint{DecisionID: run.decision.ID, WinningProposalID: run.decision.WinningProposalID}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if run.decision == nil {
+		decision, err := p.consensusRepo.GetByTaskID(ctx, int(task.ID))
+		if err != nil || decision == nil {
+			return fmt.Errorf("consensus decision of task %d not found: %v", task.ID, err)
+		}
+		run.decision = decision
+	}
+
+	// 10. Aplicar solución ganadora en main (opcional: APPLY_TO_MAIN), con comprobaciones previas, verificación y rollback.
+	// Con APPLY_REQUIRE_APPROVAL la tarea queda en awaiting_approval hasta que un admin la apruebe o rechace.
+	var applied applyCheckpoint
+	if err := p.stage(ctx, run, entities.StageApply, &applied, func() error {
+		winner := p.applicableWinner(run.decision, run.proposals)
+		if winner == nil {
+			applied.Status = "skipped"
+			return nil
+		}
+		if p.orchestrator.Apply.RequireApproval {
+			task.Status = entities.TaskStatusAwaitingApproval
+			if err := p.saveTask(ctx, task); err != nil {
+				return fmt.Errorf("failed to update task status: %w", err)
+			}
+			p.broadcastEvent(EventAwaitingApproval, map[string]interface{}{
+				"task_id":     task.ID,
+				"proposal_id": winner.ID,
+				"statements":  agents.ProposalStatements(winner),
+			})
+			applied.Status = string(entities.TaskStatusAwaitingApproval)
+			return errStageWaiting
+		}
+		return p.applyStage(ctx, task, run.decision, winner, &applied)
+	}); err != nil {
+		return err
+	}
+
+	// 11. Marcar tarea como completada
+	return p.stage(ctx, run, entities.StageCleanup, nil, func() error {
+		return p.completeTask(ctx, task, run.decision)
 	})
+}
 
-	// 6. Ejecutar agentes en paralelo usando orchestrator con forks reales
-	proposals, benchmarks, err := p.orchestrator.ExecuteAgentsInParallel(ctx, task, agentInstances, forkIDs, agentExecutionIDs)
+// leaseForks reserva un fork exclusivo por agente y registra su agent_execution. Los agentes con una
+// ejecución de una corrida anterior la conservan y solo reciben un fork nuevo. Con metadata.fork_at los
+// forks se crean desde la base tal como estaba en ese momento (PITR), para reproducir un incidente pasado.
+func (p *TaskProcessor) leaseForks(ctx context.Context, run *pipelineRun, agentTypes []values.AgentType) error {
+	task := run.task
+	forkAt, pointInTime, err := task.ForkAt()
 	if err != nil {
-		task.Status = entities.TaskStatusFailed
-		p.taskRepo.Update(ctx, task)
-		p.broadcastEvent(EventTaskFailed, map[string]interface{}{
-			"task_id": taskID,
-			"error":   err.Error(),
-		})
-		return fmt.Errorf("agent execution failed: %w", err)
+		return err
+	}
+	// Sin forks aislados la tarea no arranca: agentes compartiendo fork contaminan sus benchmarks entre sí
+	forks := p.orchestrator.ForkPool()
+	if free := forks.Available(); !pointInTime && free >= 0 && free < len(agentTypes) {
+		return fmt.Errorf("%w: %d agents need forks, %d free", ErrNoIsolatedFork, len(agentTypes), free)
 	}
 
-	// 7. Guardar propuestas primero (para obtener IDs autogenerados por DB)
-	proposalIDMap := make(map[int64]int64) // old ID -> new ID
-	for i, prop := range proposals {
-		oldID := prop.ID
-		if err := p.proposalRepo.Create(ctx, prop); err != nil {
+	for i, agentType := range agentTypes {
+		if i == len(run.forks) {
+			run.forks = append(run.forks, forkCheckpoint{AgentType: agentType})
+		}
+		f := &run.forks[i]
+
+		// Fork exclusivo para este agente (creado vía MCP o tomado del pool pre-creado)
+		forkName := fmt.Sprintf("fork-%s-task%d", agentType, task.ID)
+		var lease *ForkLease
+		if pointInTime {
+			lease, err = forks.AcquireAt(ctx, p.mainService, forkName, forkAt)
+		} else {
+			lease, err = forks.Acquire(ctx, p.mainService, forkName)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lease fork for %s: %w", agentType, err)
+		}
+		run.leases = append(run.leases, lease)
+		f.ForkID = lease.ForkID
+
+		// Crear (o reutilizar) el registro de agent_execution en DB
+		if err := p.recordExecution(ctx, task.ID, f); err != nil {
+			return err
+		}
+
+		event := map[string]interface{}{
+			"task_id":      task.ID,
+			"agent_type":   agentType,
+			"fork_id":      f.ForkID,
+			"execution_id": f.ExecutionID,
+		}
+		if pointInTime {
+			event["fork_at"] = forkAt.Format(time.RFC3339)
+		}
+		p.broadcastEvent(EventForkCreated, event)
+	}
+	return nil
+}
+
+// recordExecution crea la agent_execution de un fork, o apunta una existente a su fork nuevo.
+func (p *TaskProcessor) recordExecution(ctx context.Context, taskID int64, f *forkCheckpoint) error {
+	if f.ExecutionID != 0 {
+		exec, err := p.agentExecRepo.GetByID(ctx, int(f.ExecutionID))
+		if err != nil || exec == nil {
+			return fmt.Errorf("agent execution %d not found: %v", f.ExecutionID, err)
+		}
+		exec.ForkID = f.ForkID
+		if err := p.agentExecRepo.Update(ctx, exec); err != nil {
+			return fmt.Errorf("failed to update agent execution record: %w", err)
+		}
+		return nil
+	}
+	agentExec := &entities.AgentExecution{
+		TaskID:    taskID,
+		AgentType: f.AgentType,
+		ForkID:    f.ForkID,
+		Status:    entities.ExecutionRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if err := p.agentExecRepo.Create(ctx, agentExec); err != nil {
+		return fmt.Errorf("failed to create agent execution record: %w", err)
+	}
+	fmt.Printf("      ✅ Created agent_execution ID=%d for task=%d agent=%s\n", agentExec.ID, taskID, f.AgentType)
+	f.ExecutionID = agentExec.ID
+	return nil
+}
+
+// saveResults guarda las propuestas (para obtener sus IDs de DB) y después sus benchmarks, apuntando a
+// los IDs guardados; lo que una corrida anterior ya guardó no se repite.
+func (p *TaskProcessor) saveResults(ctx context.Context, run *pipelineRun, proposed proposalCheckpoint, saved *benchmarkCheckpoint) error {
+	if saved.ProposalIDs == nil {
+		saved.ProposalIDs = map[int64]int64{}
+	}
+	for _, prop := range proposed.Proposals {
+		if _, ok := saved.ProposalIDs[prop.ID]; ok {
+			continue
+		}
+		row := *prop
+		if err := p.proposalRepo.Create(ctx, &row); err != nil {
 			return fmt.Errorf("failed to save proposal: %w", err)
 		}
-		proposalIDMap[oldID] = prop.ID
-		
+		saved.ProposalIDs[prop.ID] = row.ID
+
 		p.broadcastEvent(EventProposalSubmitted, map[string]interface{}{
-			"task_id":     taskID,
-			"proposal_id": prop.ID,
-			"type":        prop.ProposalType,
-			"agent_index": i,
+			"task_id":            run.task.ID,
+			"proposal_id":        row.ID,
+			"type":               row.ProposalType,
+			"agent_execution_id": row.AgentExecutionID,
+			"agent_rank":         row.EstimatedImpact.AgentRank,
 		})
 	}
 
-	// 8. Actualizar proposal_id en benchmarks y guardarlos
-	for _, bench := range benchmarks {
-		// Mapear el ID temporal al ID real de la DB
-		if newID, ok := proposalIDMap[bench.ProposalID]; ok {
-			bench.ProposalID = newID
+	// Mapear el ID temporal de cada benchmark al ID real de la DB
+	for i := saved.SavedBenchmarks; i < len(proposed.Benchmarks); i++ {
+		row := *proposed.Benchmarks[i]
+		if newID, ok := saved.ProposalIDs[row.ProposalID]; ok {
+			row.ProposalID = newID
 		}
-		if err := p.benchmarkRepo.Create(ctx, bench); err != nil {
+		if err := p.benchmarkRepo.Create(ctx, &row); err != nil {
 			return fmt.Errorf("failed to save benchmark: %w", err)
 		}
+		saved.SavedBenchmarks = i + 1
 	}
 
-	// 9. Ejecutar consenso
 	p.broadcastEvent(EventBenchmarkCompleted, map[string]interface{}{
-		"task_id":   taskID,
-		"proposals": len(proposals),
+		"task_id":   run.task.ID,
+		"proposals": len(proposed.Proposals),
 	})
+	return nil
+}
 
-	// Marcar agent_executions como completados
-	for _, execID := range agentExecutionIDs {
-		exec, err := p.agentExecRepo.GetByID(ctx, int(execID))
-		if err == nil && exec != nil {
-			exec.Status = entities.ExecutionCompleted
+// recordAgentRuns añade el resultado de una fase a la ejecución de cada agente: fase alcanzada, tiempo y
+// tokens. Un agente que falló queda failed con su error; con final los demás quedan completados.
+// Los agentes sin corrida (omitidos en esta fase) conservan su ejecución tal cual.
+func (p *TaskProcessor) recordAgentRuns(ctx context.Context, execIDs []int64, runs []*AgentRun, final bool) {
+	ctx = context.WithoutCancel(ctx)
+	for i, r := range runs {
+		if r == nil {
+			continue
+		}
+		exec, err := p.agentExecRepo.GetByID(ctx, int(execIDs[i]))
+		if err != nil || exec == nil {
+			fmt.Printf("Warning: agent execution %d not found: %v\n", execIDs[i], err)
+			continue
+		}
+		exec.Phase = r.Phase
+		exec.DurationMS += r.Duration.Milliseconds()
+		exec.InputTokens += r.InputTokens
+		exec.OutputTokens += r.OutputTokens
+		exec.Status, exec.ErrorMsg, exec.CompletedAt = entities.ExecutionRunning, "", nil
+		if r.Err != nil || final {
 			now := time.Now().UTC()
-			exec.CompletedAt = &now
-			p.agentExecRepo.Update(ctx, exec)
+			exec.Status, exec.CompletedAt = entities.ExecutionCompleted, &now
+			if r.Err != nil {
+				exec.Status, exec.ErrorMsg = entities.ExecutionFailed, r.Err.Error()
+			}
+		}
+		if err := p.agentExecRepo.Update(ctx, exec); err != nil {
+			fmt.Printf("Warning: failed to record outcome of agent execution %d: %v\n", exec.ID, err)
 		}
 	}
+}
+
+// decide ejecuta el consenso, guarda el desglose de scores de cada propuesta y guarda la decisión.
+// Una decisión guardada por una corrida anterior se reutiliza, tenga o no ganador: una tarea nunca tiene dos.
+func (p *TaskProcessor) decide(ctx context.Context, run *pipelineRun) error {
+	if existing, err := p.consensusRepo.GetByTaskID(ctx, int(run.task.ID)); err == nil && existing != nil {
+		run.decision = existing
+		return nil
+	}
 
 	criteria := entities.ScoringCriteria{
 		PerformanceWeight: 0.5,
@@ -198,23 +478,14 @@ func (p *TaskProcessor) ProcessTask(ctx context.Context, taskID int64) error {
 		ComplexityWeight:  0.2,
 		RiskWeight:        0.1,
 	}
-
-	decision, err := p.consensus.Decide(ctx, proposals, benchmarks, criteria)
+	decision, err := p.consensus.Decide(ctx, run.proposals, run.benchmarks, criteria)
 	if err != nil {
-		task.Status = entities.TaskStatusFailed
-		p.taskRepo.Update(ctx, task)
-		p.broadcastEvent(EventTaskFailed, map[string]interface{}{
-			"task_id": taskID,
-			"error":   fmt.Sprintf("consensus failed: %v", err),
-		})
 		return fmt.Errorf("consensus failed: %w", err)
 	}
 
-	// Actualizar proposals con score_breakdown calculado por consenso
-	scoreAgentTypes := []values.AgentType{values.AgentCerebro, values.AgentOperativo, values.AgentBulk}
-	for i, prop := range proposals {
-		agentType := scoreAgentTypes[i]
-		if score, ok := decision.AllScores[agentType]; ok {
+	// Actualizar proposals con score_breakdown calculado por consenso (cada candidata con su propio score)
+	for _, prop := range run.proposals {
+		if score, ok := decision.ProposalScores[prop.ID]; ok {
 			prop.EstimatedImpact.ScoreBreakdown = map[string]float64{
 				"performance":    score.Performance,
 				"storage":        score.Storage,
@@ -228,66 +499,93 @@ func (p *TaskProcessor) ProcessTask(ctx context.Context, taskID int64) error {
 		}
 	}
 
-	// 9. Guardar decisión de consenso
-	decision.TaskID = int64(task.ID)
+	decision.TaskID = run.task.ID
 	if err := p.consensusRepo.Create(ctx, decision); err != nil {
 		return fmt.Errorf("failed to save consensus decision: %w", err)
 	}
+	run.decision = decision
 
 	p.broadcastEvent(EventConsensusReached, map[string]interface{}{
-		"task_id":             taskID,
+		"task_id":             run.task.ID,
 		"winning_proposal_id": decision.WinningProposalID,
 	})
+	return nil
+}
 
-	// 10. Aplicar solución ganadora (COMENTADO por ahora para evitar cambios en DB real)
-	/*
-	var winner *entities.OptimizationProposal
-	if decision.WinningProposalID != nil {
-		for _, p := range proposals {
-			if p.ID == *decision.WinningProposalID {
-				winner = p
-				break
-			}
-		}
+// applyStage aplica el ganador y registra el resultado del apply en la salida de la etapa.
+func (p *TaskProcessor) applyStage(ctx context.Context, task *entities.Task, decision *entities.ConsensusDecision, winner *entities.OptimizationProposal, applied *applyCheckpoint) error {
+	*applied = applyCheckpoint{}
+	err := p.applyWinner(ctx, task, decision, winner)
+	if decision.ApplyReport != nil {
+		applied.Status = string(decision.ApplyReport.Status)
 	}
+	return err
+}
 
-	if winner != nil {
-		if err := p.orchestrator.ApplyToMainDB(ctx, p.mainService, winner); err != nil {
-			task.Status = entities.TaskStatusFailed
-			p.taskRepo.Update(ctx, task)
-			p.broadcastEvent(EventTaskFailed, map[string]interface{}{
-				"task_id": taskID,
-				"error":   fmt.Sprintf("failed to apply optimization: %v", err),
-			})
-			return fmt.Errorf("failed to apply optimization: %w", err)
-		}
+// applicableWinner devuelve la propuesta ganadora cuando aplicar en main está habilitado y tiene sentencias que aplicar.
+func (p *TaskProcessor) applicableWinner(decision *entities.ConsensusDecision, proposals []*entities.OptimizationProposal) *entities.OptimizationProposal {
+	if !p.orchestrator.Apply.Enabled || decision == nil || decision.WinningProposalID == nil { return nil }
+	for _, prop := range proposals {
+		if prop.ID == *decision.WinningProposalID && len(agents.ProposalStatements(prop)) > 0 { return prop }
 	}
-	*/
+	return nil
+}
 
-	// 11. Limpiar forks
-	if err := p.orchestrator.CleanupForks(ctx, forkIDs); err != nil {
-		// Log error pero no fallar el proceso
-		fmt.Printf("Warning: failed to cleanup forks: %v\n", err)
+// applyWinner ejecuta el pipeline de apply en main y guarda su reporte con la decisión.
+// Solo un apply failed hace fallar la tarea: blocked o rolled_back dejan main como estaba.
+func (p *TaskProcessor) applyWinner(ctx context.Context, task *entities.Task, decision *entities.ConsensusDecision, winner *entities.OptimizationProposal) error {
+	report, applyErr := p.orchestrator.ApplyToMainDB(ctx, p.mainService, winner, task.TargetQuery)
+	if report == nil { return p.failTask(ctx, task, fmt.Errorf("failed to apply optimization: %w", applyErr)) }
+	decision.ApplyReport = report
+	decision.AppliedToMain = report.Status == entities.ApplyApplied
+	if err := p.consensusRepo.Update(ctx, decision); err != nil {
+		fmt.Printf("Warning: failed to save apply report for task %d: %v\n", task.ID, err)
 	}
+	p.broadcastEvent(EventOptimizationApplied, map[string]interface{}{
+		"task_id":     task.ID,
+		"proposal_id": winner.ID,
+		"status":      report.Status,
+		"statements":  report.Statements,
+		"regressed":   report.Regressed,
+		"error":       report.Error,
+	})
+	if report.Status == entities.ApplyFailed {
+		return p.failTask(ctx, task, fmt.Errorf("failed to apply optimization: %w", applyErr))
+	}
+	return nil
+}
 
-	// 12. Marcar tarea como completada
+// completeTask marca la tarea como completada y lo anuncia.
+func (p *TaskProcessor) completeTask(ctx context.Context, task *entities.Task, decision *entities.ConsensusDecision) error {
 	now := time.Now().UTC()
 	task.Status = entities.TaskStatusCompleted
 	task.CompletedAt = &now
-	if err := p.taskRepo.Update(ctx, task); err != nil {
+	if err := p.saveTask(ctx, task); err != nil {
 		return fmt.Errorf("failed to update task status: %w", err)
 	}
 
 	p.broadcastEvent(EventTaskCompleted, map[string]interface{}{
-		"task_id":             taskID,
+		"task_id":             task.ID,
 		"status":              "completed",
 		"winning_proposal_id": decision.WinningProposalID,
 		"completed_at":        now.Format(time.RFC3339),
 	})
-
 	return nil
 }
 
+// failTask marca la tarea como fallida, lo anuncia y devuelve err. Una tarea cancelada conserva su estado.
+func (p *TaskProcessor) failTask(ctx context.Context, task *entities.Task, err error) error {
+	task.Status = entities.TaskStatusFailed
+	if p.saveTask(ctx, task) != nil && ctx.Err() != nil {
+		return err
+	}
+	p.broadcastEvent(EventTaskFailed, map[string]interface{}{
+		"task_id": task.ID,
+		"error":   err.Error(),
+	})
+	return err
+}
+
 func (p *TaskProcessor) broadcastEvent(eventType EventType, payload map[string]interface{}) {
 	if p.hub != nil {
 		p.hub.Broadcast(Event{
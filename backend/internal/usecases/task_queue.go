@@ -0,0 +1,221 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	cfgpkg "github.com/tuusuario/afs-challenge/internal/config"
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+	domainif "github.com/tuusuario/afs-challenge/internal/domain/interfaces"
+)
+
+// maxRetryBackoff caps the wait before a failed job is retried.
+const maxRetryBackoff = 5 * time.Minute
+
+// WorkerPoolOptions size the pool that processes queued tasks.
+type WorkerPoolOptions struct {
+	Workers      int           // concurrent tasks per server
+	Lease        time.Duration // a job whose worker stops heartbeating for this long is recovered
+	PollInterval time.Duration // how often an idle worker looks for due jobs
+	MaxAttempts  int           // attempts per task before it is left failed
+}
+
+// DefaultWorkerPoolOptions: 2 workers, 60s lease, 1s poll, 3 attempts.
+func DefaultWorkerPoolOptions() WorkerPoolOptions {
+	return WorkerPoolOptions{Workers: 2, Lease: 60 * time.Second, PollInterval: time.Second, MaxAttempts: 3}
+}
+
+// WorkerPoolOptionsFromConfig reads the queue settings, falling back to defaults.
+func WorkerPoolOptionsFromConfig(cfg *cfgpkg.Config) WorkerPoolOptions {
+	if cfg == nil { return DefaultWorkerPoolOptions() }
+	return WorkerPoolOptions{
+		Workers:      cfg.Queue.Workers,
+		Lease:        time.Duration(cfg.Queue.LeaseS) * time.Second,
+		PollInterval: time.Duration(cfg.Queue.PollMS) * time.Millisecond,
+		MaxAttempts:  cfg.Queue.MaxAttempts,
+	}.normalized()
+}
+
+func (o WorkerPoolOptions) normalized() WorkerPoolOptions {
+	def := DefaultWorkerPoolOptions()
+	if o.Workers <= 0 { o.Workers = def.Workers }
+	if o.Lease <= 0 { o.Lease = def.Lease }
+	if o.PollInterval <= 0 { o.PollInterval = def.PollInterval }
+	if o.MaxAttempts <= 0 { o.MaxAttempts = def.MaxAttempts }
+	return o
+}
+
+// WorkerPool processes tasks from the durable TaskQueue. Each worker claims a job, keeps its
+// lease alive with heartbeats while run processes the task, and completes or fails the job;
+// failed jobs are retried with exponential backoff. Jobs of workers that died (expired leases)
+// and pending or in_progress tasks without a job (or approved ones whose job finished) are recovered
+// on Start and every lease period.
+type WorkerPool struct {
+	queue domainif.TaskQueue
+	run   func(ctx context.Context, taskID int64) error
+	opts  WorkerPoolOptions
+	id    string
+	wake  chan struct{}
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewWorkerPool creates a pool running run (TaskProcessor.ProcessTask) for every claimed task.
+func NewWorkerPool(queue domainif.TaskQueue, run func(ctx context.Context, taskID int64) error, opts WorkerPoolOptions) *WorkerPool {
+	host, _ := os.Hostname()
+	if host == "" { host = "worker" }
+	return &WorkerPool{
+		queue: queue,
+		run:   run,
+		opts:  opts.normalized(),
+		id:    fmt.Sprintf("%s-%d-%04x", host, os.Getpid(), rand.Intn(1<<16)),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Start recovers orphaned jobs and tasks, then launches the workers and the periodic reaper.
+func (w *WorkerPool) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil { return errors.New("worker pool already started") }
+	n, err := w.queue.Recover(ctx, w.opts.MaxAttempts)
+	if err != nil { return fmt.Errorf("recover task queue: %w", err) }
+	if n > 0 { fmt.Printf("Task queue: recovered %d orphaned task(s)\n", n) }
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	for i := 0; i < w.opts.Workers; i++ {
+		w.wg.Add(1)
+		go w.worker(ctx, fmt.Sprintf("%s/%d", w.id, i))
+	}
+	w.wg.Add(1)
+	go w.reaper(ctx)
+	return nil
+}
+
+// Stop cancels the running tasks and waits for the workers to return their jobs.
+func (w *WorkerPool) Stop() {
+	w.mu.Lock()
+	cancel := w.cancel
+	w.mu.Unlock()
+	if cancel == nil { return }
+	cancel()
+	w.wg.Wait()
+}
+
+// Enqueue adds a task to the queue and wakes an idle worker.
+func (w *WorkerPool) Enqueue(ctx context.Context, taskID int64) error {
+	if err := w.queue.Enqueue(ctx, taskID, w.opts.MaxAttempts); err != nil { return err }
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (w *WorkerPool) worker(ctx context.Context, workerID string) {
+	defer w.wg.Done()
+	for ctx.Err() == nil {
+		job, err := w.queue.Claim(ctx, workerID, w.opts.Lease)
+		if err != nil && ctx.Err() == nil { fmt.Printf("Warning: worker %s failed to claim a job: %v\n", workerID, err) }
+		if job != nil { w.runJob(ctx, workerID, job); continue }
+		select {
+		case <-ctx.Done():
+		case <-w.wake:
+		case <-time.After(w.opts.PollInterval):
+		}
+	}
+}
+
+// runJob processes one claimed job. The job context is cancelled when the lease is lost, so the
+// task stops instead of racing the worker that took it over, and when the task is cancelled
+// through another server.
+func (w *WorkerPool) runJob(ctx context.Context, workerID string, job *entities.TaskJob) {
+	jctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	lost := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.opts.Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				// Keeps heartbeating while a cancelled task winds down (fork cleanup)
+				err := w.queue.Heartbeat(ctx, job.ID, workerID, w.opts.Lease)
+				if errors.Is(err, entities.ErrJobLeaseLost) { close(lost); cancel(err); return }
+				if errors.Is(err, entities.ErrJobCancelled) { cancel(ErrTaskCancelled); continue }
+				if err != nil && ctx.Err() == nil { fmt.Printf("Warning: heartbeat of job %d failed: %v\n", job.ID, err) }
+			}
+		}
+	}()
+
+	err := w.process(jctx, job.TaskID)
+	close(done)
+	select {
+	case <-lost:
+		fmt.Printf("Warning: worker %s lost the lease of task %d\n", workerID, job.TaskID)
+		return
+	default:
+	}
+
+	// Bookkeeping must reach the queue even while the pool is stopping.
+	bctx := context.WithoutCancel(ctx)
+	if err == nil {
+		err = w.queue.Complete(bctx, job.ID, workerID)
+		if err != nil { fmt.Printf("Warning: failed to complete job of task %d: %v\n", job.TaskID, err) }
+		return
+	}
+	retryAfter := RetryBackoff(job.Attempts)
+	if ctx.Err() != nil { retryAfter, err = 0, fmt.Errorf("worker stopped: %w", err) }
+	if ferr := w.queue.Fail(bctx, job.ID, workerID, err.Error(), retryAfter); ferr != nil {
+		fmt.Printf("Warning: failed to record the failure of task %d: %v\n", job.TaskID, ferr)
+	}
+}
+
+// process runs the task, turning a panic into an error so the job is retried.
+func (w *WorkerPool) process(ctx context.Context, taskID int64) (err error) {
+	defer func() {
+		if r := recover(); r != nil { err = fmt.Errorf("panic processing task %d: %v", taskID, r) }
+	}()
+	return w.run(ctx, taskID)
+}
+
+// reaper recovers expired leases once per lease period.
+func (w *WorkerPool) reaper(ctx context.Context) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.opts.Lease)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := w.queue.Recover(ctx, w.opts.MaxAttempts); err != nil && ctx.Err() == nil {
+				fmt.Printf("Warning: task queue recovery failed: %v\n", err)
+			} else if n > 0 {
+				select {
+				case w.wake <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// RetryBackoff is the wait before retrying a job that failed its attempt-th attempt: 5s doubling up to 5m.
+func RetryBackoff(attempt int) time.Duration {
+	if attempt < 1 { attempt = 1 }
+	if attempt > 7 { return maxRetryBackoff }
+	d := 5 * time.Second << (attempt - 1)
+	if d > maxRetryBackoff { return maxRetryBackoff }
+	return d
+}
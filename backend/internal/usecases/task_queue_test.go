@@ -0,0 +1,170 @@
+package usecases
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tuusuario/afs-challenge/internal/domain/entities"
+)
+
+// memTaskQueue is an in-memory TaskQueue with the same lease semantics as task_jobs.
+type memTaskQueue struct {
+	mu        sync.Mutex
+	jobs      map[int64]*entities.TaskJob
+	cancelled map[int64]bool // tasks cancelled through another server
+}
+
+func newMemTaskQueue() *memTaskQueue { return &memTaskQueue{jobs: map[int64]*entities.TaskJob{}} }
+
+func (q *memTaskQueue) Enqueue(ctx context.Context, taskID int64, maxAttempts int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.jobs[taskID] = &entities.TaskJob{ID: taskID, TaskID: taskID, Status: entities.JobQueued, MaxAttempts: maxAttempts}
+	return nil
+}
+
+func (q *memTaskQueue) Claim(ctx context.Context, workerID string, lease time.Duration) (*entities.TaskJob, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, j := range q.jobs {
+		if j.Status != entities.JobQueued || time.Now().Before(j.RunAfter) { continue }
+		until := time.Now().Add(lease)
+		j.Status, j.LockedBy, j.LeaseUntil = entities.JobRunning, workerID, &until
+		j.Attempts++
+		cp := *j
+		return &cp, nil
+	}
+	return nil, nil
+}
+
+func (q *memTaskQueue) owned(jobID int64, workerID string) (*entities.TaskJob, error) {
+	j := q.jobs[jobID]
+	if j == nil || j.Status != entities.JobRunning || j.LockedBy != workerID { return nil, entities.ErrJobLeaseLost }
+	return j, nil
+}
+
+func (q *memTaskQueue) Heartbeat(ctx context.Context, jobID int64, workerID string, lease time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.owned(jobID, workerID)
+	if err != nil { return err }
+	until := time.Now().Add(lease)
+	j.LeaseUntil = &until
+	if q.cancelled[j.TaskID] { return entities.ErrJobCancelled }
+	return nil
+}
+
+func (q *memTaskQueue) Complete(ctx context.Context, jobID int64, workerID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.owned(jobID, workerID)
+	if err != nil { return err }
+	j.Status, j.LockedBy = entities.JobDone, ""
+	return nil
+}
+
+func (q *memTaskQueue) Fail(ctx context.Context, jobID int64, workerID, reason string, retryAfter time.Duration) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, err := q.owned(jobID, workerID)
+	if err != nil { return err }
+	j.Status, j.LockedBy, j.LastError, j.RunAfter = entities.JobQueued, "", reason, time.Now()
+	if j.Attempts >= j.MaxAttempts { j.Status = entities.JobFailed }
+	return nil
+}
+
+func (q *memTaskQueue) Recover(ctx context.Context, maxAttempts int) (int, error) { return 0, nil }
+
+func (q *memTaskQueue) job(taskID int64) entities.TaskJob {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.jobs[taskID]
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) { t.Fatalf("timed out waiting for %s", what) }
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorkerPool_RetriesUntilMaxAttempts(t *testing.T) {
+	q := newMemTaskQueue()
+	var mu sync.Mutex
+	runs := map[int64]int{}
+	run := func(ctx context.Context, taskID int64) error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs[taskID]++
+		if taskID == 1 && runs[taskID] < 2 { return errors.New("transient") }
+		if taskID == 2 { panic("always broken") }
+		return nil
+	}
+	pool := NewWorkerPool(q, run, WorkerPoolOptions{Workers: 2, Lease: time.Second, PollInterval: 5 * time.Millisecond, MaxAttempts: 3})
+	if err := pool.Start(context.Background()); err != nil { t.Fatal(err) }
+	defer pool.Stop()
+	pool.Enqueue(context.Background(), 1)
+	pool.Enqueue(context.Background(), 2)
+
+	// RetryBackoff would delay the retries by seconds; the fake queue makes them due at once.
+	waitFor(t, "both jobs to finish", func() bool {
+		return q.job(1).Status == entities.JobDone && q.job(2).Status == entities.JobFailed
+	})
+	if j := q.job(1); j.Attempts != 2 { t.Errorf("expected task 1 done on its second attempt, got %+v", j) }
+	if j := q.job(2); j.Attempts != 3 || j.LastError == "" { t.Errorf("expected task 2 failed after 3 attempts with the panic recorded, got %+v", j) }
+}
+
+func TestWorkerPool_LostLeaseCancelsTheTask(t *testing.T) {
+	q := newMemTaskQueue()
+	cancelled := make(chan struct{})
+	run := func(ctx context.Context, taskID int64) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}
+	pool := NewWorkerPool(q, run, WorkerPoolOptions{Workers: 1, Lease: 30 * time.Millisecond, PollInterval: 5 * time.Millisecond, MaxAttempts: 1})
+	if err := pool.Start(context.Background()); err != nil { t.Fatal(err) }
+	defer pool.Stop()
+	pool.Enqueue(context.Background(), 1)
+	waitFor(t, "the job to be claimed", func() bool { return q.job(1).Status == entities.JobRunning })
+
+	// Another server's recovery took the job over: the next heartbeat is refused.
+	q.mu.Lock()
+	q.jobs[1].LockedBy = "other-worker"
+	q.mu.Unlock()
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the task context cancelled once the lease was lost")
+	}
+	if j := q.job(1); j.Status != entities.JobRunning || j.LockedBy != "other-worker" {
+		t.Errorf("expected the job left to its new owner, got %+v", j)
+	}
+}
+
+func TestWorkerPool_RemoteCancelStopsTheTask(t *testing.T) {
+	q := newMemTaskQueue()
+	q.cancelled = map[int64]bool{1: true}
+	run := func(ctx context.Context, taskID int64) error {
+		<-ctx.Done()
+		if !cancelled(ctx) { return ctx.Err() }
+		return nil
+	}
+	pool := NewWorkerPool(q, run, WorkerPoolOptions{Workers: 1, Lease: 30 * time.Millisecond, PollInterval: 5 * time.Millisecond, MaxAttempts: 3})
+	if err := pool.Start(context.Background()); err != nil { t.Fatal(err) }
+	defer pool.Stop()
+	pool.Enqueue(context.Background(), 1)
+	waitFor(t, "the cancelled job to complete", func() bool { return q.job(1).Status == entities.JobDone })
+	if j := q.job(1); j.Attempts != 1 { t.Errorf("expected a cancelled task not to be retried, got %+v", j) }
+}
+
+func TestRetryBackoff(t *testing.T) {
+	if RetryBackoff(1) != 5*time.Second || RetryBackoff(3) != 20*time.Second || RetryBackoff(20) != maxRetryBackoff {
+		t.Errorf("unexpected backoff: %v %v %v", RetryBackoff(1), RetryBackoff(3), RetryBackoff(20))
+	}
+}
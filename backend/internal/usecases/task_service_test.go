@@ -22,6 +22,10 @@ func (m *mockTaskRepo) Create(ctx context.Context, task *entities.Task) error {
 	m.byID[int(task.ID)] = task
 	return nil
 }
+func (m *mockTaskRepo) Delete(ctx context.Context, id int) error {
+	delete(m.byID, id)
+	return nil
+}
 func (m *mockTaskRepo) GetByID(ctx context.Context, id int) (*entities.Task, error) {
 	if m.byID == nil { return nil, errors.New("not found") }
 	t, ok := m.byID[id]
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Per-result iteration statistics (warmup/iterations, median, p95, stddev, min, max,
+-- raw samples) and the significance test against the baseline. NULL for legacy rows.
+ALTER TABLE benchmark_results ADD COLUMN IF NOT EXISTS stats JSONB;
+
+-- +goose Down
+ALTER TABLE benchmark_results DROP COLUMN IF EXISTS stats;
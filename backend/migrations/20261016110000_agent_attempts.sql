@@ -0,0 +1,18 @@
+-- +goose Up
+-- One row per proposal attempt of an agent execution. Failed and non-improving
+-- attempts keep the feedback that was handed back to the agent for its next try.
+CREATE TABLE IF NOT EXISTS agent_attempts (
+    id                 SERIAL PRIMARY KEY,
+    agent_execution_id INTEGER NOT NULL REFERENCES agent_executions(id) ON DELETE CASCADE,
+    attempt            INTEGER NOT NULL,
+    proposal_type      VARCHAR(50),
+    sql_commands       TEXT[] NOT NULL DEFAULT '{}',
+    outcome            VARCHAR(20) NOT NULL,
+    feedback           TEXT,
+    improvement_pct    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    created_at         TIMESTAMP DEFAULT NOW(),
+    UNIQUE (agent_execution_id, attempt)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS agent_attempts;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Agents propose a ranked list of candidates per attempt; each candidate gets its own row.
+ALTER TABLE agent_attempts ADD COLUMN IF NOT EXISTS candidate INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE agent_attempts DROP CONSTRAINT IF EXISTS agent_attempts_agent_execution_id_attempt_key;
+ALTER TABLE agent_attempts ADD CONSTRAINT agent_attempts_execution_attempt_candidate_key UNIQUE (agent_execution_id, attempt, candidate);
+
+-- +goose Down
+ALTER TABLE agent_attempts DROP CONSTRAINT IF EXISTS agent_attempts_execution_attempt_candidate_key;
+ALTER TABLE agent_attempts DROP COLUMN IF EXISTS candidate;
+ALTER TABLE agent_attempts ADD CONSTRAINT agent_attempts_agent_execution_id_attempt_key UNIQUE (agent_execution_id, attempt);
@@ -0,0 +1,7 @@
+-- +goose Up
+-- How the winning proposal was applied to the main database: pre-flight checks,
+-- executed statements, rollback script and the before/after benchmark. NULL when not applied.
+ALTER TABLE consensus_decisions ADD COLUMN IF NOT EXISTS apply_report JSONB;
+
+-- +goose Down
+ALTER TABLE consensus_decisions DROP COLUMN IF EXISTS apply_report;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Durable task queue: one job per task, claimed by workers with
+-- SELECT ... FOR UPDATE SKIP LOCKED and held through a heartbeated lease.
+CREATE TABLE IF NOT EXISTS task_jobs (
+    id           SERIAL PRIMARY KEY,
+    task_id      INTEGER NOT NULL UNIQUE REFERENCES tasks(id) ON DELETE CASCADE,
+    status       VARCHAR(20) NOT NULL DEFAULT 'queued',
+    attempts     INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 3,
+    run_after    TIMESTAMP NOT NULL DEFAULT NOW(),
+    locked_by    TEXT,
+    lease_until  TIMESTAMP,
+    heartbeat_at TIMESTAMP,
+    last_error   TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at   TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_jobs_claim ON task_jobs (run_after, id) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS idx_task_jobs_lease ON task_jobs (lease_until) WHERE status = 'running';
+
+-- +goose Down
+DROP TABLE IF EXISTS task_jobs;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- Checkpoints of the task pipeline: one row per task and stage with the stage's output,
+-- so a failed task resumes from its last successful stage (POST /api/v1/tasks/:id/retry).
+CREATE TABLE IF NOT EXISTS task_stages (
+    id          SERIAL PRIMARY KEY,
+    task_id     INTEGER NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    stage       VARCHAR(20) NOT NULL,
+    status      VARCHAR(20) NOT NULL,
+    attempts    INTEGER NOT NULL DEFAULT 1,
+    output      JSONB,
+    error       TEXT,
+    started_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    UNIQUE (task_id, stage)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS task_stages;
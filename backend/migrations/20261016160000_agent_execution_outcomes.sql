@@ -0,0 +1,13 @@
+-- +goose Up
+-- What each agent actually did: the phase it reached (and failed in, when status is failed),
+-- the time it spent and the LLM tokens it used, summed over the retries of its task.
+ALTER TABLE agent_executions ADD COLUMN IF NOT EXISTS phase VARCHAR(20);
+ALTER TABLE agent_executions ADD COLUMN IF NOT EXISTS duration_ms BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE agent_executions ADD COLUMN IF NOT EXISTS input_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE agent_executions ADD COLUMN IF NOT EXISTS output_tokens INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE agent_executions DROP COLUMN IF EXISTS output_tokens;
+ALTER TABLE agent_executions DROP COLUMN IF EXISTS input_tokens;
+ALTER TABLE agent_executions DROP COLUMN IF EXISTS duration_ms;
+ALTER TABLE agent_executions DROP COLUMN IF EXISTS phase;
@@ -0,0 +1,49 @@
+-- +goose Up
+-- all_scores was keyed by agent type, guessed from the order the proposals arrived in, and held one
+-- proposal per agent. It is now keyed by proposal ID; each score links to its agent through
+-- agent_execution_id and uses the snake_case field names of the other JSON columns.
+UPDATE consensus_decisions cd
+SET all_scores = COALESCE((
+    SELECT jsonb_object_agg(s.value->>'ProposalID', jsonb_build_object(
+        'proposal_id', (s.value->>'ProposalID')::bigint,
+        'agent_execution_id', COALESCE(op.agent_execution_id, 0),
+        'performance_score', s.value->'Performance',
+        'storage_score', s.value->'Storage',
+        'complexity_score', s.value->'Complexity',
+        'risk_score', s.value->'Risk',
+        'weighted_total', s.value->'WeightedTotal',
+        'rank', s.value->'Rank',
+        'improvement_pct', s.value->'ImprovementPct',
+        'storage_overhead_mb', s.value->'StorageOverheadMB',
+        'disqualified', COALESCE(s.value->'Disqualified', 'false'::jsonb),
+        'disqualify_reason', COALESCE(s.value->'DisqualifyReason', '""'::jsonb)))
+    FROM jsonb_each(cd.all_scores) s
+    LEFT JOIN optimization_proposals op ON op.id = (s.value->>'ProposalID')::bigint
+    WHERE s.value ? 'ProposalID'
+), '{}'::jsonb)
+WHERE EXISTS (SELECT 1 FROM jsonb_each(cd.all_scores) s WHERE s.value ? 'ProposalID');
+
+-- +goose Down
+-- Back to one score per agent type: the best-ranked proposal of each agent.
+UPDATE consensus_decisions cd
+SET all_scores = COALESCE((
+    SELECT jsonb_object_agg(best.agent_type, best.score)
+    FROM (
+        SELECT DISTINCT ON (ae.agent_type) ae.agent_type, jsonb_build_object(
+            'ProposalID', s.value->'proposal_id',
+            'Performance', s.value->'performance_score',
+            'Storage', s.value->'storage_score',
+            'Complexity', s.value->'complexity_score',
+            'Risk', s.value->'risk_score',
+            'WeightedTotal', s.value->'weighted_total',
+            'Rank', s.value->'rank',
+            'ImprovementPct', s.value->'improvement_pct',
+            'StorageOverheadMB', s.value->'storage_overhead_mb',
+            'Disqualified', s.value->'disqualified',
+            'DisqualifyReason', COALESCE(s.value->'disqualify_reason', '""'::jsonb)) AS score
+        FROM jsonb_each(cd.all_scores) s
+        JOIN agent_executions ae ON ae.id = (s.value->>'agent_execution_id')::bigint
+        ORDER BY ae.agent_type, (s.value->>'rank')::int
+    ) best
+), '{}'::jsonb)
+WHERE EXISTS (SELECT 1 FROM jsonb_each(cd.all_scores) s WHERE s.value ? 'proposal_id');
@@ -83,6 +83,8 @@ export default function TaskDetailPage() {
     'optimization_applied',
     'task_completed',
     'task_failed',
+    'task_cancelled',
+    'task_retried',
   ])
 
   useEffect(() => {
@@ -102,6 +104,8 @@ export default function TaskDetailPage() {
         case 'analysis_completed':
         case 'task_completed':
         case 'task_failed':
+        case 'task_cancelled':
+        case 'task_retried':
           qc.invalidateQueries({ queryKey: ['task', taskId] })
           qc.invalidateQueries({ queryKey: ['agentsByTask', taskId] })
           break
@@ -254,6 +258,12 @@ export default function TaskDetailPage() {
                       <span>{new Date(a.completed_at).toLocaleTimeString('es-ES')}</span>
                     </div>
                   )}
+                  {a.phase && (
+                    <div className="flex items-center">
+                      <span className="font-medium mr-2">🧭 Fase:</span>
+                      <span>{a.phase} · {((a.duration_ms ?? 0) / 1000).toFixed(1)}s · {(a.input_tokens ?? 0) + (a.output_tokens ?? 0)} tokens</span>
+                    </div>
+                  )}
                   {a.error && (
                     <div className="bg-red-50 border border-red-200 text-red-800 px-2 py-1 rounded text-xs mt-2">
                       ❌ {a.error}
@@ -54,6 +54,8 @@ export default function TaskListPage() {
       completed: 'bg-green-100 text-green-800 border-green-200',
       failed: 'bg-red-100 text-red-800 border-red-200',
       in_progress: 'bg-blue-100 text-blue-800 border-blue-200',
+      awaiting_approval: 'bg-purple-100 text-purple-800 border-purple-200',
+      cancelled: 'bg-gray-200 text-gray-700 border-gray-300',
       pending: 'bg-yellow-100 text-yellow-800 border-yellow-200'
     }
     return colors[status] || 'bg-gray-100 text-gray-800 border-gray-200'
@@ -64,6 +66,8 @@ export default function TaskListPage() {
       completed: '✅',
       failed: '❌',
       in_progress: '⏳',
+      awaiting_approval: '🛂',
+      cancelled: '🚫',
       pending: '⏸️'
     }
     return icons[status] || '📋'
@@ -95,6 +99,8 @@ export default function TaskListPage() {
               <option value="completed">Completed</option>
               <option value="failed">Failed</option>
               <option value="in_progress">In Progress</option>
+              <option value="awaiting_approval">Awaiting Approval</option>
+              <option value="cancelled">Cancelled</option>
               <option value="pending">Pending</option>
             </select>
           </div>
@@ -14,6 +14,10 @@ export type AgentExecution = {
   started_at: string
   completed_at?: string | null
   error?: string
+  phase?: 'analysis' | 'proposal' | 'benchmark' | ''
+  duration_ms?: number
+  input_tokens?: number
+  output_tokens?: number
 }
 
 export async function listAgents() {